@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+)
+
+// baselineFinding is a known violation of a rule and how many times it
+// occurs in a document of a file. Line numbers and messages are left out, as
+// messages quote sizes and names, so that unrelated edits moving a finding
+// around or changing its figures don't make it look new.
+type baselineFinding struct {
+	File     string `json:"file"`
+	Rule     string `json:"rule"`
+	Document int    `json:"document"`
+	Count    int    `json:"count"`
+}
+
+type baselineFile struct {
+	Findings []baselineFinding `json:"findings"`
+}
+
+type baselineKey struct {
+	file     string
+	rule     string
+	document int
+}
+
+// baseline suppresses known findings, counting them down as they are seen, so
+// a file gaining another copy of a known violation still reports one.
+type baseline struct {
+	known    map[baselineKey]int
+	findings map[baselineKey]int // everything seen this run
+}
+
+// loadBaseline reads the baseline at path. An empty path gives a baseline
+// that suppresses nothing, and so does a missing file when update is set, as
+// the first run of -update-baseline creates it.
+func loadBaseline(path string, update bool) (*baseline, error) {
+	b := &baseline{known: make(map[baselineKey]int), findings: make(map[baselineKey]int)}
+	if path == "" {
+		return b, nil
+	}
+	data, err := os.ReadFile(path)
+	if update && errors.Is(err, fs.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f baselineFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing baseline %s: %w", path, err)
+	}
+	for _, finding := range f.Findings {
+		b.known[baselineKey{finding.File, finding.Rule, finding.Document}] += finding.Count
+	}
+	return b, nil
+}
+
+// suppressed records v as found in file and reports whether the baseline
+// already accounts for it.
+func (b *baseline) suppressed(file string, v yamlmin.Violation) bool {
+	key := baselineKey{file, v.Rule, v.Document}
+	b.findings[key]++
+	if b.known[key] > 0 {
+		b.known[key]--
+		return true
+	}
+	return false
+}
+
+// write saves every finding seen this run to path, sorted for stable diffs.
+func (b *baseline) write(path string) error {
+	f := baselineFile{Findings: []baselineFinding{}}
+	for key, count := range b.findings {
+		f.Findings = append(f.Findings, baselineFinding{File: key.file, Rule: key.rule, Document: key.document, Count: count})
+	}
+	sort.Slice(f.Findings, func(i, j int) bool {
+		a, b := f.Findings[i], f.Findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Document != b.Document {
+			return a.Document < b.Document
+		}
+		return a.Rule < b.Rule
+	})
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaseline(t *testing.T) {
+	policy, err := yamlmin.LoadPolicy([]byte("maxDocumentBytes: 10\nforbidAnchors: [.data]\n"))
+	require.NoError(t, err)
+	check := func(doc string) []yamlmin.Violation {
+		violations, err := policy.Check([]byte(doc))
+		require.NoError(t, err)
+		return violations
+	}
+
+	// Record the findings of a 28-byte document with one forbidden anchor.
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	b, err := loadBaseline(path, true)
+	require.NoError(t, err)
+	recorded := check("data: {a: &x 1, b: *x}\nn: 12\n")
+	require.Len(t, recorded, 3)
+	for _, v := range recorded {
+		b.suppressed("a.yaml", v)
+	}
+	require.NoError(t, b.write(path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "findings": [
+    {
+      "file": "a.yaml",
+      "rule": "ForbidAnchors",
+      "document": 1,
+      "count": 2
+    },
+    {
+      "file": "a.yaml",
+      "rule": "MaxDocumentBytes",
+      "document": 1,
+      "count": 1
+    }
+  ]
+}
+`, string(data))
+
+	// Shrinking the document changes the size in the message, not the
+	// finding, and renaming the anchor moves it around.
+	b, err = loadBaseline(path, false)
+	require.NoError(t, err)
+	shrunk := check("data: {a: &y 1, b: *y}\nn: 1\n")
+	require.Len(t, shrunk, 3)
+	assert.NotEqual(t, recorded[0].Message, shrunk[0].Message)
+	for _, v := range shrunk {
+		assert.True(t, b.suppressed("a.yaml", v), v.String())
+	}
+
+	// A new copy of a known finding, one in another document, and one in
+	// another file are reported.
+	b, err = loadBaseline(path, false)
+	require.NoError(t, err)
+	var reported []string
+	for _, v := range check("data: {a: &x 1, b: *x, c: *x}\n") {
+		if !b.suppressed("a.yaml", v) {
+			reported = append(reported, v.Rule)
+		}
+	}
+	for _, v := range check("n: 1\n---\ndata: {a: 1, b: 2, c: 3}\n") {
+		if !b.suppressed("a.yaml", v) {
+			reported = append(reported, v.Rule)
+		}
+	}
+	for _, v := range check("data: {a: 1, b: 2, c: 3}\n") {
+		if !b.suppressed("b.yaml", v) {
+			reported = append(reported, v.Rule)
+		}
+	}
+	assert.Equal(t, []string{yamlmin.RuleForbidAnchors, yamlmin.RuleMaxDocumentBytes, yamlmin.RuleMaxDocumentBytes}, reported)
+
+	_, err = loadBaseline(filepath.Join(t.TempDir(), "missing.json"), false)
+	assert.Error(t, err)
+	b, err = loadBaseline("", false)
+	require.NoError(t, err)
+	assert.False(t, b.suppressed("a.yaml", recorded[0]))
+}
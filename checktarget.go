@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+)
+
+// checkTargetCmd validates files against a target's known limitations.
+// It exits 1 when any violation is found and 2 on usage errors.
+func checkTargetCmd(args []string) int {
+	fs := flag.NewFlagSet("check-target", flag.ExitOnError)
+	targetName := fs.String("target", "", "Target consumer: "+strings.Join(yamlmin.Targets(), ", "))
+	baselinePath := fs.String("baseline", "", "Suppress findings recorded in this baseline file, reporting only new ones")
+	updateBaseline := fs.Bool("update-baseline", false, "Record the current findings in the -baseline file instead of reporting them")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s check-target --target name [-baseline baseline.json] file ...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Reports constructs the target does not support.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	target, err := yamlmin.LookupTarget(*targetName)
+	if err != nil || fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if *updateBaseline && *baselinePath == "" {
+		fmt.Fprintf(os.Stderr, "Error: -update-baseline requires -baseline\n")
+		return 2
+	}
+	known, err := loadBaseline(*baselinePath, *updateBaseline)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading baseline: %v\n", err)
+		return 2
+	}
+
+	code := 0
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+			return 2
+		}
+		violations, err := yamlmin.CheckTarget(data, target)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error checking %s: %v\n", path, err)
+			return 2
+		}
+		for _, v := range violations {
+			if known.suppressed(path, v) || *updateBaseline {
+				continue
+			}
+			fmt.Printf("%s:%s\n", path, v)
+			code = 1
+		}
+	}
+	if *updateBaseline {
+		if err := known.write(*baselinePath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing baseline: %v\n", err)
+			return 2
+		}
+	}
+	return code
+}
@@ -0,0 +1,33 @@
+//go:build cgo
+
+package main
+
+// #include <stdlib.h>
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+)
+
+//export yamlmin_minify
+func yamlmin_minify(input, optionsJSON *C.char, errOut **C.char) *C.char {
+	opts := ""
+	if optionsJSON != nil {
+		opts = C.GoString(optionsJSON)
+	}
+	out, err := yamlmin.MinifyString(C.GoString(input), opts)
+	if err != nil {
+		if errOut != nil {
+			*errOut = C.CString(err.Error())
+		}
+		return nil
+	}
+	return C.CString(out)
+}
+
+//export yamlmin_free
+func yamlmin_free(p *C.char) {
+	C.free(unsafe.Pointer(p))
+}
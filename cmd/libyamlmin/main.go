@@ -0,0 +1,25 @@
+// Command libyamlmin builds yamlmin as a C shared library, so Python, Node,
+// and other build tooling can minify in-process instead of running the CLI:
+//
+//	CGO_ENABLED=1 go build -buildmode=c-shared -o libyamlmin.so ./cmd/libyamlmin
+//
+// This also writes libyamlmin.h, which declares:
+//
+//	char* yamlmin_minify(char* input, char* options_json, char** err);
+//	void yamlmin_free(char* p);
+//
+// options_json uses the same encoding as yamlmin.MinifyString and may be
+// NULL or empty for the defaults. yamlmin_minify returns the minified stream,
+// or NULL with *err set to a message; free either string with yamlmin_free.
+// From Python:
+//
+//	lib = ctypes.CDLL("./libyamlmin.so")
+//	lib.yamlmin_minify.restype = ctypes.c_void_p
+//	err = ctypes.c_char_p()
+//	p = lib.yamlmin_minify(data, b'{"MinSize": 40}', ctypes.byref(err))
+//	out = ctypes.string_at(p).decode()
+//	lib.yamlmin_free(p)
+package main
+
+// main is required by -buildmode=c-shared but never runs.
+func main() {}
@@ -0,0 +1,35 @@
+//go:build js && wasm
+
+// Command yamlmin-wasm exposes yamlmin to JavaScript as a global function:
+//
+//	const out = yamlminMinify(input, JSON.stringify({MinSize: 40}))
+//
+// Errors are thrown as JavaScript exceptions. Build with:
+//
+//	GOOS=js GOARCH=wasm go build -o yamlmin.wasm ./cmd/yamlmin-wasm
+package main
+
+import (
+	"syscall/js"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+)
+
+func main() {
+	js.Global().Set("yamlminMinify", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) < 1 {
+			panic(js.Global().Get("Error").New("yamlminMinify(input, [optionsJSON])"))
+		}
+		optsJSON := ""
+		if len(args) > 1 {
+			optsJSON = args[1].String()
+		}
+		out, err := yamlmin.MinifyString(args[0].String(), optsJSON)
+		if err != nil {
+			panic(js.Global().Get("Error").New(err.Error()))
+		}
+		return out
+	}))
+
+	select {}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/glennpratt/yamlmin/pkg/server"
+)
+
+// daemonCmd serves the minification API on a Unix socket, so build tools
+// making many requests pay for process startup once. It runs until
+// interrupted, then removes the socket.
+func daemonCmd(args []string) int {
+	def := server.DefaultConfig()
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	socket := fs.String("socket", "", "Unix socket path to listen on")
+	maxBody := fs.Int64("max-body", def.MaxBodyBytes, "Maximum request body size in bytes")
+	timeout := fs.Duration("timeout", def.RequestTimeout, "Maximum deduplication time per request")
+	maxConcurrent := fs.Int("max-concurrent", def.MaxConcurrent, "Maximum requests processed at once")
+	cacheEntries := fs.Int("cache-entries", 0, "Number of responses to keep in an LRU cache (0 disables)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s daemon -socket path [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Serves POST /minify, as serve does, on a Unix socket only the\n")
+		fmt.Fprintf(os.Stderr, "current user can connect to:\n\n")
+		fmt.Fprintf(os.Stderr, "  curl --unix-socket path --data-binary @in.yaml http://yamlmin/minify\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if *socket == "" {
+		fs.Usage()
+		return 2
+	}
+	if err := removeStaleSocket(*socket); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	ln, err := net.Listen("unix", *socket)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer ln.Close()
+	// The socket is the only access control, so keep it private.
+	if err := os.Chmod(*socket, 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	httpServer := &http.Server{
+		Handler: server.New(server.Config{
+			MaxBodyBytes:   *maxBody,
+			RequestTimeout: *timeout,
+			MaxConcurrent:  *maxConcurrent,
+			CacheEntries:   *cacheEntries,
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       *timeout + time.Minute,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdown)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Listening on unix:%s\n", *socket)
+	err = httpServer.Serve(ln)
+	if errors.Is(err, http.ErrServerClosed) {
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	return 1
+}
+
+// removeStaleSocket removes a socket left behind at path by a daemon that
+// didn't shut down cleanly. Anything else at path is left alone, and a socket
+// with a live daemon behind it is an error.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("a daemon is already listening on %s", path)
+	}
+	return os.Remove(path)
+}
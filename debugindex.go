@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+)
+
+// debugIndexCmd prints the duplicate index built for each input: every hash
+// bucket, where its nodes are, and why it was or wasn't anchored.
+func debugIndexCmd(args []string) int {
+	fs := flag.NewFlagSet("debug-index", flag.ExitOnError)
+	preset := fs.String("preset", "default", "Options preset: "+strings.Join(yamlmin.Presets(), ", "))
+	minOccurrences := fs.Int("min-occurrences", 2, "Minimum number of occurrences to create anchor")
+	minSize := fs.Int("min-size", 20, "Minimum structure size (chars) to consider for anchoring")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s debug-index [options] [file ...]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Dumps the duplicate index and the decision taken for each bucket.\n")
+		fmt.Fprintf(os.Stderr, "Reads from stdin when no files are given.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	opts, err := yamlmin.Preset(*preset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "min-occurrences":
+			opts.MinOccurrences = *minOccurrences
+		case "min-size":
+			opts.MinSize = *minSize
+		}
+	})
+
+	if fs.NArg() == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+			return 1
+		}
+		if err := yamlmin.DumpIndex(os.Stdout, data, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error processing YAML: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+			return 1
+		}
+		fmt.Printf("%s:\n", path)
+		if err := yamlmin.DumpIndex(os.Stdout, data, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", path, err)
+			return 1
+		}
+	}
+	return 0
+}
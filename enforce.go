@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+)
+
+// enforceCmd evaluates files against a policy file. It exits 1 when any
+// violation is found and 2 on usage errors.
+func enforceCmd(args []string) int {
+	fs := flag.NewFlagSet("enforce", flag.ExitOnError)
+	policyPath := fs.String("policy", "policy.yaml", "Policy file declaring the requirements")
+	baselinePath := fs.String("baseline", "", "Suppress findings recorded in this baseline file, reporting only new ones")
+	updateBaseline := fs.Bool("update-baseline", false, "Record the current findings in the -baseline file instead of reporting them")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s enforce [-policy policy.yaml] [-baseline baseline.json] file ...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Reports documents that fail the policy's requirements.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := os.ReadFile(*policyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading policy: %v\n", err)
+		return 2
+	}
+	policy, err := yamlmin.LoadPolicy(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", *policyPath, err)
+		return 2
+	}
+
+	if *updateBaseline && *baselinePath == "" {
+		fmt.Fprintf(os.Stderr, "Error: -update-baseline requires -baseline\n")
+		return 2
+	}
+	known, err := loadBaseline(*baselinePath, *updateBaseline)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading baseline: %v\n", err)
+		return 2
+	}
+
+	code := 0
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+			return 2
+		}
+		violations, err := policy.Check(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error checking %s: %v\n", path, err)
+			return 2
+		}
+		for _, v := range violations {
+			if known.suppressed(path, v) || *updateBaseline {
+				continue
+			}
+			fmt.Printf("%s:%s\n", path, v)
+			code = 1
+		}
+	}
+	if *updateBaseline {
+		if err := known.write(*baselinePath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing baseline: %v\n", err)
+			return 2
+		}
+	}
+	return code
+}
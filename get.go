@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"gopkg.in/yaml.v3"
+)
+
+// getCmd prints the value at a path in each document, resolving aliases and
+// merge keys. It exits 1 when the path is missing from any document.
+func getCmd(args []string) int {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s get path [file ...]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Prints the value at path, e.g. .spec.template or .jobs[0].steps,\n")
+		fmt.Fprintf(os.Stderr, "with aliases and merge keys expanded. Reads from stdin when no files are given.\n")
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	path := fs.Arg(0)
+
+	inputs := fs.Args()[1:]
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+
+	code := 0
+	first := true
+	for _, name := range inputs {
+		var data []byte
+		var err error
+		if name == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", name, err)
+			return 1
+		}
+
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		for {
+			var root yaml.Node
+			if err := dec.Decode(&root); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				fmt.Fprintf(os.Stderr, "Error parsing %s: %v\n", name, err)
+				return 1
+			}
+			node, err := yamlmin.Query(&root, path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+				code = 1
+				continue
+			}
+			if !first {
+				fmt.Println("---")
+			}
+			first = false
+			enc := yaml.NewEncoder(os.Stdout)
+			enc.SetIndent(2)
+			if err := enc.Encode(node); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", name, err)
+				return 1
+			}
+			_ = enc.Close()
+		}
+	}
+	return code
+}
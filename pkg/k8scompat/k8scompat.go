@@ -0,0 +1,71 @@
+// Package k8scompat is a drop-in replacement for sigs.k8s.io/yaml whose
+// Marshal and JSONToYAML deduplicate output with yamlmin.
+//
+// Kubernetes tooling can adopt yamlmin by swapping the import:
+//
+//	import yaml "github.com/glennpratt/yamlmin/pkg/k8scompat"
+//
+//	out, err := yaml.Marshal(obj)
+package k8scompat
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+// JSONOpt is a decoding option for decoding from JSON format.
+type JSONOpt = k8syaml.JSONOpt
+
+// DisallowUnknownFields configures the JSON decoder to error out if unknown
+// fields come along, instead of dropping them by default.
+var DisallowUnknownFields = k8syaml.DisallowUnknownFields
+
+// Marshal marshals obj using its JSON tags and returns minified YAML, as
+// JSONToYAML writes it.
+func Marshal(obj interface{}) ([]byte, error) {
+	j, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling into JSON: %w", err)
+	}
+	return JSONToYAML(j)
+}
+
+// Unmarshal converts YAML to JSON then uses JSON to unmarshal into obj.
+// Anchors, aliases, and merge keys are resolved.
+func Unmarshal(yamlBytes []byte, obj interface{}, opts ...JSONOpt) error {
+	return k8syaml.Unmarshal(yamlBytes, obj, opts...)
+}
+
+// UnmarshalStrict is like Unmarshal but rejects duplicate and unknown fields.
+func UnmarshalStrict(yamlBytes []byte, obj interface{}, opts ...JSONOpt) error {
+	return k8syaml.UnmarshalStrict(yamlBytes, obj, opts...)
+}
+
+// JSONToYAML converts JSON to minified YAML. The YAML is that of
+// sigs.k8s.io/yaml, with its block style, sorted keys, YAML 1.1 quoting, and
+// null for empty input, deduplicated with its styles kept; only sequences
+// are indented under their keys, as in all yamlmin output.
+func JSONToYAML(j []byte) ([]byte, error) {
+	y, err := k8syaml.JSONToYAML(j)
+	if err != nil {
+		return nil, err
+	}
+	out, err := yamlmin.MinifyBytes(y, yamlmin.DefaultOptions())
+	if err != nil {
+		return nil, fmt.Errorf("converting JSON to YAML: %w", err)
+	}
+	return out, nil
+}
+
+// YAMLToJSON converts YAML to JSON, resolving anchors and aliases.
+func YAMLToJSON(y []byte) ([]byte, error) {
+	return k8syaml.YAMLToJSON(y)
+}
+
+// YAMLToJSONStrict is like YAMLToJSON but rejects duplicate fields.
+func YAMLToJSONStrict(y []byte) ([]byte, error) {
+	return k8syaml.YAMLToJSONStrict(y)
+}
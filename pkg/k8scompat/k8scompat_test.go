@@ -0,0 +1,53 @@
+package k8scompat_test
+
+import (
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/k8scompat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+type container struct {
+	Name  string            `json:"name"`
+	Env   map[string]string `json:"env"`
+	Ports []int             `json:"ports,omitempty"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	env := map[string]string{"LOG_LEVEL": "debug", "REGION": "us-east-1"}
+	in := map[string]container{
+		"frontend": {Name: "frontend", Env: env},
+		"backend":  {Name: "backend", Env: env},
+	}
+
+	out, err := k8scompat.Marshal(in)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "&map1")
+
+	var roundtrip map[string]container
+	require.NoError(t, k8scompat.Unmarshal(out, &roundtrip))
+	assert.Equal(t, in, roundtrip)
+
+	j, err := k8scompat.YAMLToJSON(out)
+	require.NoError(t, err)
+	y, err := k8scompat.JSONToYAML(j)
+	require.NoError(t, err)
+	assert.Equal(t, string(out), string(y))
+}
+
+func TestJSONToYAMLMatchesK8s(t *testing.T) {
+	for _, j := range []string{
+		``,
+		"  \n",
+		`null`,
+		`{"data":{"empty":"","mode":"0755","on":"yes","script":"set -e\nmake"},"metadata":{"labels":{"app":"web"},"name":"web"},"replicas":3}`,
+	} {
+		want, err := k8syaml.JSONToYAML([]byte(j))
+		require.NoError(t, err)
+		got, err := k8scompat.JSONToYAML([]byte(j))
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(got), j)
+	}
+}
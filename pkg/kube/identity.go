@@ -0,0 +1,51 @@
+package kube
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// ObjectIdentity is what identifies a Kubernetes object in a cluster.
+// Namespace is empty for cluster-scoped objects and for manifests that leave
+// it to the client.
+type ObjectIdentity struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name,omitempty"`
+}
+
+// String formats the identity as kubectl does, e.g. "Deployment/web", with a
+// leading namespace when set.
+func (id ObjectIdentity) String() string {
+	s := id.Kind
+	if id.Name != "" {
+		s += "/" + id.Name
+	}
+	if id.Namespace != "" {
+		s = id.Namespace + "/" + s
+	}
+	return s
+}
+
+// Identify extracts the identity of the Kubernetes object in doc, a single
+// YAML document. ok is false when doc doesn't look like one, that is, it is
+// not a mapping with string apiVersion and kind fields.
+func Identify(doc []byte) (id ObjectIdentity, ok bool) {
+	var obj struct {
+		APIVersion interface{} `yaml:"apiVersion"`
+		Kind       interface{} `yaml:"kind"`
+		Metadata   struct {
+			Namespace string `yaml:"namespace"`
+			Name      string `yaml:"name"`
+		} `yaml:"metadata"`
+	}
+	if err := yaml.Unmarshal(doc, &obj); err != nil {
+		return ObjectIdentity{}, false
+	}
+	apiVersion, _ := obj.APIVersion.(string)
+	kind, _ := obj.Kind.(string)
+	if apiVersion == "" || kind == "" {
+		return ObjectIdentity{}, false
+	}
+	return ObjectIdentity{APIVersion: apiVersion, Kind: kind, Namespace: obj.Metadata.Namespace, Name: obj.Metadata.Name}, true
+}
@@ -0,0 +1,145 @@
+// Package kube shrinks YAML and JSON payloads stored inside Kubernetes
+// objects, helping controllers stay under the 256KiB annotation and 1MiB
+// object size limits.
+//
+// The helpers operate on plain maps so they work with controller-runtime's
+// unstructured.Unstructured (obj.Object), typed ConfigMaps (cm.Data), and
+// ObjectMeta annotations without importing Kubernetes libraries:
+//
+//	if err := kube.MinifyObject(u.Object, yamlmin.DefaultOptions()); err != nil {
+//		return err
+//	}
+//	return c.Update(ctx, u)
+//
+// Minified payloads are YAML with anchors and aliases. Readers must decode
+// them with a YAML parser (sigs.k8s.io/yaml resolves aliases); kubectl's
+// client-side apply expects the last-applied annotation to be JSON, so only
+// minify it for objects managed by server-side apply or YAML-aware tooling.
+package kube
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"gopkg.in/yaml.v3"
+)
+
+// LastAppliedAnnotation is the annotation kubectl apply stores the previous
+// configuration in.
+const LastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+const (
+	// MaxAnnotationBytes is the maximum total size of an object's annotations.
+	MaxAnnotationBytes = 256 * 1024
+
+	// MaxConfigMapBytes is the maximum size of a ConfigMap's data.
+	MaxConfigMapBytes = 1024 * 1024
+)
+
+// MinifyObject minifies the last-applied-configuration annotation of obj and,
+// when obj is a ConfigMap, every YAML or JSON document in its data. obj is an
+// unstructured object as held by unstructured.Unstructured.
+func MinifyObject(obj map[string]interface{}, opts yamlmin.Options) error {
+	if meta, ok := obj["metadata"].(map[string]interface{}); ok {
+		if annotations, ok := meta["annotations"].(map[string]interface{}); ok {
+			if v, ok := annotations[LastAppliedAnnotation].(string); ok {
+				out, err := minifyString(v, opts)
+				if err != nil {
+					return fmt.Errorf("annotation %s: %w", LastAppliedAnnotation, err)
+				}
+				annotations[LastAppliedAnnotation] = out
+			}
+		}
+	}
+
+	if obj["kind"] != "ConfigMap" {
+		return nil
+	}
+	data, ok := obj["data"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for key, value := range data {
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		out, err := minifyString(s, opts)
+		if err != nil {
+			return fmt.Errorf("data key %s: %w", key, err)
+		}
+		data[key] = out
+	}
+	return nil
+}
+
+// MinifyAnnotations minifies the last-applied-configuration annotation in
+// place, as found in ObjectMeta.Annotations.
+func MinifyAnnotations(annotations map[string]string, opts yamlmin.Options) error {
+	v, ok := annotations[LastAppliedAnnotation]
+	if !ok {
+		return nil
+	}
+	out, err := minifyString(v, opts)
+	if err != nil {
+		return fmt.Errorf("annotation %s: %w", LastAppliedAnnotation, err)
+	}
+	annotations[LastAppliedAnnotation] = out
+	return nil
+}
+
+// MinifyConfigMapData minifies every YAML or JSON document in a ConfigMap's
+// data in place. Values that are not YAML collections are left untouched.
+func MinifyConfigMapData(data map[string]string, opts yamlmin.Options) error {
+	for key, value := range data {
+		out, err := minifyString(value, opts)
+		if err != nil {
+			return fmt.Errorf("data key %s: %w", key, err)
+		}
+		data[key] = out
+	}
+	return nil
+}
+
+// minifyString returns the minified form of s, or s itself when it is not a
+// stream of YAML collections or minifying does not make it smaller.
+func minifyString(s string, opts yamlmin.Options) (string, error) {
+	if !isCollectionStream(s) {
+		return s, nil
+	}
+
+	var out bytes.Buffer
+	if err := yamlmin.Minify(strings.NewReader(s), &out, opts); err != nil {
+		return "", err
+	}
+
+	if out.Len() >= len(s) {
+		return s, nil
+	}
+	return out.String(), nil
+}
+
+// isCollectionStream reports whether every document in s is a mapping or
+// sequence, so plain text payloads are never rewritten.
+func isCollectionStream(s string) bool {
+	dec := yaml.NewDecoder(bytes.NewReader([]byte(s)))
+	docs := 0
+	for {
+		var node yaml.Node
+		err := dec.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			return docs > 0
+		}
+		if err != nil || len(node.Content) == 0 {
+			return false
+		}
+		if kind := node.Content[0].Kind; kind != yaml.MappingNode && kind != yaml.SequenceNode {
+			return false
+		}
+		docs++
+	}
+}
@@ -0,0 +1,126 @@
+package kube_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/kube"
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+func TestMinifyObject(t *testing.T) {
+	lastApplied := `{"data":{"a":{"key":"a long repeated string"},"b":{"key":"a long repeated string"}}}`
+	payload := "a:\n  key: a long repeated string\nb:\n  key: a long repeated string\n"
+
+	obj := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":        "example",
+			"annotations": map[string]interface{}{kube.LastAppliedAnnotation: lastApplied},
+		},
+		"data": map[string]interface{}{
+			"config.yaml": payload,
+			"motd":        "hello world",
+		},
+	}
+
+	require.NoError(t, kube.MinifyObject(obj, yamlmin.DefaultOptions()))
+
+	annotations := obj["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})
+	minified := annotations[kube.LastAppliedAnnotation].(string)
+	assert.Less(t, len(minified), len(lastApplied))
+	assert.Contains(t, minified, "*map1")
+
+	data := obj["data"].(map[string]interface{})
+	assert.Equal(t, "a: &map1\n  key: a long repeated string\nb: *map1\n", data["config.yaml"])
+	assert.Equal(t, "hello world", data["motd"])
+
+	var expected, actual interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(lastApplied), &expected))
+	require.NoError(t, yaml.Unmarshal([]byte(minified), &actual))
+	assert.Equal(t, expected, actual)
+}
+
+func TestIdentify(t *testing.T) {
+	tests := []struct {
+		doc  string
+		want kube.ObjectIdentity
+		ok   bool
+	}{
+		{
+			doc:  "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: prod\n",
+			want: kube.ObjectIdentity{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "prod", Name: "web"},
+			ok:   true,
+		},
+		{
+			doc:  "base: &m {name: ns}\napiVersion: v1\nkind: Namespace\nmetadata: *m\n",
+			want: kube.ObjectIdentity{APIVersion: "v1", Kind: "Namespace", Name: "ns"},
+			ok:   true,
+		},
+		{doc: "kind: Deployment\nmetadata: {name: web}\n"},
+		{doc: "apiVersion: v1\nkind: [a]\n"},
+		{doc: "- apiVersion: v1\n"},
+		{doc: "apiVersion: v1\nkind: Pod\nmetadata: oops\n"},
+	}
+	for _, tt := range tests {
+		id, ok := kube.Identify([]byte(tt.doc))
+		assert.Equal(t, tt.ok, ok, tt.doc)
+		assert.Equal(t, tt.want, id, tt.doc)
+	}
+	assert.Equal(t, "prod/Deployment/web", tests[0].want.String())
+}
+
+func TestMinifyLastApplied(t *testing.T) {
+	lastApplied := `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"x","creationTimestamp":null,"labels":{}},` +
+		`"data":{"a":"a repeated value","b":"a repeated value","port":"8080","empty":""},"items":[{},null],` +
+		`"volumes":[{"name":"cache","emptyDir":{}}],"args":[],"status":{"phase":"x"}}`
+
+	out, headroom, err := kube.MinifyLastApplied([]byte(lastApplied))
+	require.NoError(t, err)
+	assert.Equal(t, kube.MaxAnnotationBytes-len(out), headroom)
+	assert.Equal(t, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: x
+  labels: {}
+data:
+  a: &str1 a repeated value
+  b: *str1
+  port: "8080"
+  empty: ""
+items:
+  - {}
+  - null
+volumes:
+  - name: cache
+    emptyDir: {}
+args: []
+`, string(out))
+
+	big := `{"data":"` + strings.Repeat("x", kube.MaxAnnotationBytes) + `"}`
+	out, headroom, err = kube.MinifyLastApplied([]byte(big))
+	assert.ErrorIs(t, err, kube.ErrAnnotationTooLarge)
+	assert.Negative(t, headroom)
+	assert.NotEmpty(t, out)
+
+	_, _, err = kube.MinifyLastApplied([]byte(`[1, 2]`))
+	assert.Error(t, err)
+}
+
+func TestMinifyLastAppliedYAML11Words(t *testing.T) {
+	lastApplied := `{"data":{"n":"no","i":"yes","o":"on","off":"off","y":"y","N":"n","yes":"kept"}}`
+	out, _, err := kube.MinifyLastApplied([]byte(lastApplied))
+	require.NoError(t, err)
+
+	// kubectl reads the annotation with sigs.k8s.io/yaml, a YAML 1.1 parser.
+	var want, got interface{}
+	require.NoError(t, json.Unmarshal([]byte(lastApplied), &want))
+	require.NoError(t, k8syaml.Unmarshal(out, &got))
+	assert.Equal(t, want, got, string(out))
+}
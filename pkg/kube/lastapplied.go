@@ -0,0 +1,90 @@
+package kube
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrAnnotationTooLarge is returned by MinifyLastApplied when even the
+// minified configuration does not fit in MaxAnnotationBytes.
+var ErrAnnotationTooLarge = errors.New("last-applied configuration exceeds the annotation size limit")
+
+// MinifyLastApplied shrinks a last-applied configuration (JSON or YAML) as
+// far as it safely can to fit the annotation size limit: it drops fields
+// that are null, and the status stanza, which apply never sets, rewrites
+// JSON syntax as block YAML, and deduplicates with a low size threshold.
+// Empty strings, maps, and lists are kept, as they are often meaningful
+// values: an emptyDir: {} is what gives a volume its source. So are list
+// items, even null ones, as apply compares lists by position.
+// Strings YAML 1.1 parsers such as sigs.k8s.io/yaml would read as another
+// type, like "no" or "on", stay quoted. Fields set to their API server
+// defaults are kept too: the annotation records what was applied, and a
+// default dropped from it would read as a field the next apply removes.
+//
+// headroom is the number of bytes left under MaxAnnotationBytes. When it is
+// negative the output is returned along with ErrAnnotationTooLarge.
+func MinifyLastApplied(obj []byte) (out []byte, headroom int, err error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(obj, &root); err != nil {
+		return nil, 0, fmt.Errorf("parsing last-applied configuration: %w", err)
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, 0, fmt.Errorf("last-applied configuration is not an object")
+	}
+	doc := root.Content[0]
+	removeKey(doc, "status")
+	prune(doc)
+
+	opts := yamlmin.DefaultOptions()
+	opts.MinSize = 8
+	// prune unquotes every scalar; quote those YAML 1.1 reads differently.
+	opts.YAMLVersion = yamlmin.YAML12
+	out, err = yamlmin.MarshalWithOptions(doc, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	headroom = MaxAnnotationBytes - len(out)
+	if headroom < 0 {
+		return out, headroom, ErrAnnotationTooLarge
+	}
+	return out, headroom, nil
+}
+
+// prune removes null values from node's mappings and clears flow and
+// quoting styles. Sequence items are never removed. It reports whether node
+// itself is null.
+func prune(node *yaml.Node) bool {
+	node.Style &^= yaml.FlowStyle | yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle
+	switch node.Kind {
+	case yaml.MappingNode:
+		kept := node.Content[:0]
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			prune(key)
+			if prune(value) {
+				continue
+			}
+			kept = append(kept, key, value)
+		}
+		node.Content = kept
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			prune(item)
+		}
+	case yaml.ScalarNode:
+		return node.ShortTag() == "!!null"
+	}
+	return false
+}
+
+func removeKey(mapping *yaml.Node, key string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Package pipeline adds yamlmin to generator pipelines (cdk8s, ytt, custom
+// renderers) by decorating the function that produces their YAML:
+//
+//	render := pipeline.Wrap(yaml.Marshal, yamlmin.DefaultOptions(), pipeline.SemanticEqual)
+//	out, err := render(chart)
+//
+// The wrapped function minifies whatever the original emits, then runs each
+// verifier on the before and after streams, so a pipeline can adopt
+// minification without trusting it blindly.
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"gopkg.in/yaml.v3"
+)
+
+// MarshalFunc renders a value as a YAML stream, like yaml.Marshal.
+type MarshalFunc func(in interface{}) ([]byte, error)
+
+// Verifier checks minified output against the stream it was produced from.
+type Verifier func(original, minified []byte) error
+
+// ErrVerification wraps the errors of failed verifiers.
+var ErrVerification = errors.New("minified output failed verification")
+
+// Wrap returns a MarshalFunc that renders with marshal, minifies the result
+// with opts, and runs verifiers in order. The first failing verifier's error
+// is returned, wrapped in ErrVerification.
+func Wrap(marshal MarshalFunc, opts yamlmin.Options, verifiers ...Verifier) MarshalFunc {
+	return func(in interface{}) ([]byte, error) {
+		original, err := marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		minified, err := yamlmin.MinifyBytes(original, opts)
+		if err != nil {
+			return nil, fmt.Errorf("minifying: %w", err)
+		}
+		for _, verify := range verifiers {
+			if err := verify(original, minified); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrVerification, err)
+			}
+		}
+		return minified, nil
+	}
+}
+
+// SemanticEqual verifies that both streams decode to the same values, with
+// aliases resolved.
+func SemanticEqual(original, minified []byte) error {
+	want, err := decodeAll(original)
+	if err != nil {
+		return fmt.Errorf("decoding original: %w", err)
+	}
+	got, err := decodeAll(minified)
+	if err != nil {
+		return fmt.Errorf("decoding minified: %w", err)
+	}
+	if len(got) != len(want) {
+		return fmt.Errorf("got %d documents, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			return fmt.Errorf("document %d differs", i)
+		}
+	}
+	return nil
+}
+
+// MaxBytes returns a Verifier rejecting output larger than limit bytes.
+func MaxBytes(limit int) Verifier {
+	return func(_, minified []byte) error {
+		if len(minified) > limit {
+			return fmt.Errorf("output is %d bytes, limit is %d", len(minified), limit)
+		}
+		return nil
+	}
+}
+
+func decodeAll(data []byte) ([]interface{}, error) {
+	var docs []interface{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, v)
+	}
+}
@@ -0,0 +1,36 @@
+package pipeline_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/pipeline"
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestWrap(t *testing.T) {
+	shared := map[string]string{"image": "registry.example.com/app:1.2.3"}
+	in := map[string]interface{}{"a": shared, "b": shared}
+
+	render := pipeline.Wrap(yaml.Marshal, yamlmin.DefaultOptions(), pipeline.SemanticEqual)
+	out, err := render(in)
+	require.NoError(t, err)
+	assert.Equal(t, "a: &map1\n  image: registry.example.com/app:1.2.3\nb: *map1\n", string(out))
+
+	render = pipeline.Wrap(yaml.Marshal, yamlmin.DefaultOptions(), pipeline.MaxBytes(10))
+	_, err = render(in)
+	assert.ErrorIs(t, err, pipeline.ErrVerification)
+
+	failing := func(interface{}) ([]byte, error) { return nil, errors.New("boom") }
+	_, err = pipeline.Wrap(failing, yamlmin.DefaultOptions())(in)
+	assert.EqualError(t, err, "boom")
+}
+
+func TestSemanticEqual(t *testing.T) {
+	assert.NoError(t, pipeline.SemanticEqual([]byte("a: {k: v}\nb: {k: v}\n"), []byte("a: &m {k: v}\nb: *m\n")))
+	assert.Error(t, pipeline.SemanticEqual([]byte("a: 1\n"), []byte("a: 2\n")))
+	assert.Error(t, pipeline.SemanticEqual([]byte("a: 1\n"), []byte("a: 1\n---\nb: 2\n")))
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// authenticate reports whether r satisfies the configured authentication,
+// and returns the tenant whose token it carried, if any.
+func (s *Server) authenticate(r *http.Request) (*Tenant, bool) {
+	if s.cfg.RequireClientCert && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+		return nil, false
+	}
+	if len(s.cfg.BearerTokens) == 0 && len(s.cfg.Tenants) == 0 {
+		return nil, true
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil, false
+	}
+	// Compare against every token so timing doesn't reveal which matched.
+	match := 0
+	for _, t := range s.cfg.BearerTokens {
+		match |= subtle.ConstantTimeCompare([]byte(token), []byte(t))
+	}
+	var tenant *Tenant
+	for i := range s.cfg.Tenants {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Tenants[i].Token)) == 1 {
+			tenant = &s.cfg.Tenants[i]
+		}
+	}
+	return tenant, match == 1 || tenant != nil
+}
+
+// TLSConfig returns a server TLS configuration using the certificate and key
+// files. When clientCAFile is set, clients must present a certificate signed
+// by one of its CAs.
+func TLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading certificate: %w", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", clientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
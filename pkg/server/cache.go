@@ -0,0 +1,61 @@
+package server
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+)
+
+// cacheKey identifies a request by its body and the options it selected.
+type cacheKey struct {
+	sum    [sha256.Size]byte
+	preset string
+	tenant string
+}
+
+type cacheEntry struct {
+	key cacheKey
+	out []byte
+	res yamlmin.Result
+}
+
+// lruCache is a fixed-size, least-recently-used cache of minified responses.
+type lruCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // front is most recently used
+	entries map[cacheKey]*list.Element
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{size: size, order: list.New(), entries: make(map[cacheKey]*list.Element)}
+}
+
+func (c *lruCache) get(key cacheKey) ([]byte, yamlmin.Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, yamlmin.Result{}, false
+	}
+	c.order.MoveToFront(el)
+	e := el.Value.(*cacheEntry)
+	return e.out, e.res, true
+}
+
+func (c *lruCache) add(key cacheKey, out []byte, res yamlmin.Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, out: out, res: res})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
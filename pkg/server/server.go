@@ -0,0 +1,184 @@
+// Package server exposes YAML minification over HTTP.
+//
+// Clients POST a YAML stream to /minify and receive the minified stream:
+//
+//	curl --data-binary @manifests.yaml http://localhost:8080/minify?preset=default
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+)
+
+// Config configures a Server.
+type Config struct {
+	// MaxBodyBytes is the largest request body accepted.
+	// Default: 10 MiB
+	MaxBodyBytes int64
+
+	// RequestTimeout bounds deduplication time per request. It caps
+	// Options.TimeLimit, so slow requests return partially deduplicated output
+	// rather than holding a worker.
+	// Default: 10s
+	RequestTimeout time.Duration
+
+	// MaxConcurrent is the number of requests minified at once. Requests
+	// beyond it are rejected with 503 Service Unavailable once their bodies
+	// are read; reading a body does not count.
+	// Default: runtime.NumCPU()
+	MaxConcurrent int
+
+	// BearerTokens, when non-empty, requires every request to carry an
+	// "Authorization: Bearer <token>" header matching one of them.
+	// Default: nil (no token required)
+	BearerTokens []string
+
+	// Tenants authenticate by bearer token like BearerTokens, and each
+	// supplies its own default preset, target, and option overrides.
+	// Default: nil
+	Tenants []Tenant
+
+	// RequireClientCert rejects requests that did not present a verified TLS
+	// client certificate. The listener's tls.Config must request and verify
+	// client certificates (see TLSConfig).
+	// Default: false
+	RequireClientCert bool
+
+	// CacheEntries is the number of responses kept in an LRU cache keyed by
+	// request body and preset, so repeated identical requests skip
+	// minification. Responses cut short by RequestTimeout are not cached.
+	// Default: 0 (no cache)
+	CacheEntries int
+}
+
+// DefaultConfig returns a Config with default values.
+func DefaultConfig() Config {
+	return Config{
+		MaxBodyBytes:   10 << 20,
+		RequestTimeout: 10 * time.Second,
+		MaxConcurrent:  runtime.NumCPU(),
+	}
+}
+
+// Server is an http.Handler serving the minification API.
+type Server struct {
+	cfg   Config
+	sem   chan struct{}
+	mux   *http.ServeMux
+	cache *lruCache
+}
+
+// New returns a Server using cfg. Zero fields take their default values.
+func New(cfg Config) *Server {
+	def := DefaultConfig()
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = def.MaxBodyBytes
+	}
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = def.RequestTimeout
+	}
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = def.MaxConcurrent
+	}
+
+	s := &Server{cfg: cfg, sem: make(chan struct{}, cfg.MaxConcurrent), mux: http.NewServeMux()}
+	if cfg.CacheEntries > 0 {
+		s.cache = newLRUCache(cfg.CacheEntries)
+	}
+	s.mux.HandleFunc("POST /minify", s.handleMinify)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := s.authenticate(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="yamlmin"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if tenant != nil {
+		r = r.WithContext(context.WithValue(r.Context(), tenantKey{}, tenant))
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// tenantKey is the request context key holding the authenticated *Tenant.
+type tenantKey struct{}
+
+func (s *Server) handleMinify(w http.ResponseWriter, r *http.Request) {
+	tenant, _ := r.Context().Value(tenantKey{}).(*Tenant)
+	preset := r.URL.Query().Get("preset")
+	opts, err := tenant.options(preset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if opts.TimeLimit <= 0 || opts.TimeLimit > s.cfg.RequestTimeout {
+		opts.TimeLimit = s.cfg.RequestTimeout
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.cfg.MaxBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "reading request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var key cacheKey
+	out, res, hit := []byte(nil), yamlmin.Result{}, false
+	if s.cache != nil {
+		key = cacheKey{sum: sha256.Sum256(body), preset: preset}
+		if tenant != nil {
+			key.tenant = tenant.Name
+		}
+		out, res, hit = s.cache.get(key)
+		if hit {
+			w.Header().Set("X-Yamlmin-Cache", "hit")
+		} else {
+			w.Header().Set("X-Yamlmin-Cache", "miss")
+		}
+	}
+	if !hit {
+		// Only minification takes a slot, so slow clients sending their
+		// bodies cannot hold them all.
+		select {
+		case s.sem <- struct{}{}:
+		default:
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "too many concurrent requests", http.StatusServiceUnavailable)
+			return
+		}
+		var buf bytes.Buffer
+		res, err = yamlmin.NewDecoder(bytes.NewReader(body), opts).WriteStream(&buf, nil)
+		out = buf.Bytes()
+		<-s.sem
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		// Output cut short by the time limit could be completed by a
+		// later, less busy attempt, so it is not kept.
+		if s.cache != nil && !res.TimedOut {
+			s.cache.add(key, out, res)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Header().Set("X-Yamlmin-Anchors", strconv.Itoa(res.Anchors))
+	w.Header().Set("X-Yamlmin-Aliases", strconv.Itoa(res.Aliases))
+	_, _ = w.Write(out)
+}
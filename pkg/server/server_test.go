@@ -0,0 +1,223 @@
+package server_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glennpratt/yamlmin/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const input = "a: a long repeated string\nb: a long repeated string\n"
+
+func TestMinify(t *testing.T) {
+	srv := server.New(server.DefaultConfig())
+
+	req := httptest.NewRequest(http.MethodPost, "/minify", strings.NewReader(input))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "a: &str1 a long repeated string\nb: *str1\n", rec.Body.String())
+	assert.Equal(t, "1", rec.Header().Get("X-Yamlmin-Aliases"))
+}
+
+func TestLimits(t *testing.T) {
+	t.Run("MaxBodyBytes", func(t *testing.T) {
+		cfg := server.DefaultConfig()
+		cfg.MaxBodyBytes = 10
+		srv := server.New(cfg)
+
+		req := httptest.NewRequest(http.MethodPost, "/minify", strings.NewReader(input))
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("MaxConcurrent", func(t *testing.T) {
+		cfg := server.DefaultConfig()
+		cfg.MaxConcurrent = 1
+		srv := server.New(cfg)
+
+		// A request whose body blocks until released does not hold the only
+		// slot while it is read.
+		body, release := blockingBody()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/minify", body))
+		}()
+		body.waitForRead()
+
+		req := httptest.NewRequest(http.MethodPost, "/minify", strings.NewReader(input))
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		release()
+		<-done
+	})
+
+	t.Run("BadPreset", func(t *testing.T) {
+		srv := server.New(server.DefaultConfig())
+
+		req := httptest.NewRequest(http.MethodPost, "/minify?preset=nope", strings.NewReader(input))
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+// gatedReader blocks its first Read until released.
+type gatedReader struct {
+	reading chan struct{}
+	gate    chan struct{}
+}
+
+func blockingBody() (*gatedReader, func()) {
+	r := &gatedReader{reading: make(chan struct{}), gate: make(chan struct{})}
+	return r, func() { close(r.gate) }
+}
+
+func (r *gatedReader) Read(p []byte) (int, error) {
+	select {
+	case <-r.reading:
+	default:
+		close(r.reading)
+	}
+	<-r.gate
+	return 0, io.EOF
+}
+
+func (r *gatedReader) waitForRead() {
+	<-r.reading
+}
+
+func TestAuth(t *testing.T) {
+	cfg := server.DefaultConfig()
+	cfg.BearerTokens = []string{"s3cret"}
+	srv := server.New(cfg)
+
+	tests := []struct {
+		name     string
+		header   string
+		expected int
+	}{
+		{"Missing", "", http.StatusUnauthorized},
+		{"Wrong", "Bearer nope", http.StatusUnauthorized},
+		{"Valid", "Bearer s3cret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/minify", strings.NewReader(input))
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+			assert.Equal(t, tt.expected, rec.Code)
+		})
+	}
+
+	t.Run("RequireClientCert", func(t *testing.T) {
+		srv := server.New(server.Config{RequireClientCert: true})
+
+		req := httptest.NewRequest(http.MethodPost, "/minify", strings.NewReader(input))
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestCache(t *testing.T) {
+	cfg := server.DefaultConfig()
+	cfg.CacheEntries = 1
+	srv := server.New(cfg)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/minify", strings.NewReader(body)))
+		return rec
+	}
+
+	first := post(input)
+	assert.Equal(t, "miss", first.Header().Get("X-Yamlmin-Cache"))
+
+	second := post(input)
+	assert.Equal(t, "hit", second.Header().Get("X-Yamlmin-Cache"))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "1", second.Header().Get("X-Yamlmin-Aliases"))
+
+	// A different body evicts the only entry.
+	assert.Equal(t, "miss", post("c: unique\n").Header().Get("X-Yamlmin-Cache"))
+	assert.Equal(t, "miss", post(input).Header().Get("X-Yamlmin-Cache"))
+
+	// Output cut short by the timeout is not cached.
+	cfg.RequestTimeout = time.Nanosecond
+	srv = server.New(cfg)
+	assert.Equal(t, "miss", post(input).Header().Get("X-Yamlmin-Cache"))
+	assert.Equal(t, "miss", post(input).Header().Get("X-Yamlmin-Cache"))
+}
+
+func TestTenants(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`tenants:
+  - name: strict
+    token: strict-token
+    options: {MinOccurrences: 3}
+  - name: plain
+    token: plain-token
+    target: cloudformation
+`), 0o600))
+	tenants, err := server.LoadTenants(path)
+	require.NoError(t, err)
+
+	cfg := server.DefaultConfig()
+	cfg.Tenants = tenants
+	cfg.BearerTokens = []string{"shared"}
+	cfg.CacheEntries = 4
+	srv := server.New(cfg)
+
+	tests := []struct {
+		token    string
+		query    string
+		expected string
+	}{
+		{"shared", "", "a: &str1 a long repeated string\nb: *str1\n"},
+		{"strict-token", "", input},
+		{"strict-token", "?preset=default", input},
+		{"plain-token", "", input},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token+tt.query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/minify"+tt.query, strings.NewReader(input))
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.expected, rec.Body.String())
+		})
+	}
+
+	for _, bad := range []string{
+		"tenants:\n  - name: x\n",
+		"tenants:\n  - {name: a, token: t}\n  - {name: b, token: t}\n",
+		"tenants:\n  - {name: a, token: t, preset: nope}\n",
+		"tenants:\n  - {name: a, token: t, options: {MinSize: big}}\n",
+		"tenant: []\n",
+	} {
+		require.NoError(t, os.WriteFile(path, []byte(bad), 0o600))
+		_, err := server.LoadTenants(path)
+		assert.Error(t, err, bad)
+	}
+}
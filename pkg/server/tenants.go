@@ -0,0 +1,109 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"gopkg.in/yaml.v3"
+)
+
+// Tenant holds the defaults applied to requests authenticated with its token,
+// so one service can serve teams with different compatibility targets.
+type Tenant struct {
+	// Name identifies the tenant in logs and cache keys.
+	Name string `yaml:"name"`
+
+	// Token is the bearer token identifying the tenant.
+	Token string `yaml:"token"`
+
+	// Preset is used when a request names no preset.
+	// Default: "default"
+	Preset string `yaml:"preset"`
+
+	// Target, when set and the request names no preset, selects the
+	// target's options (see yamlmin.Targets) instead of Preset.
+	Target string `yaml:"target"`
+
+	// Options overrides individual fields of the selected preset, using the
+	// names of the canonical JSON form (see yamlmin.ParseOptions), e.g.
+	// {minSize: 40}.
+	Options map[string]interface{} `yaml:"options"`
+}
+
+// options resolves the options for a request that asked for preset, which
+// may be empty.
+func (t *Tenant) options(preset string) (yamlmin.Options, error) {
+	var opts yamlmin.Options
+	var err error
+	switch {
+	case preset != "":
+		opts, err = yamlmin.Preset(preset)
+	case t != nil && t.Target != "":
+		var target yamlmin.Target
+		if target, err = yamlmin.LookupTarget(t.Target); err == nil {
+			opts, err = target.Options()
+		}
+	case t != nil && t.Preset != "":
+		opts, err = yamlmin.Preset(t.Preset)
+	default:
+		opts, err = yamlmin.Preset("default")
+	}
+	if err != nil || t == nil || len(t.Options) == 0 {
+		return opts, err
+	}
+
+	overrides, err := json.Marshal(t.Options)
+	if err != nil {
+		return yamlmin.Options{}, fmt.Errorf("tenant %s: %w", t.Name, err)
+	}
+	if err := json.Unmarshal(overrides, &opts); err != nil {
+		return yamlmin.Options{}, fmt.Errorf("tenant %s: options: %w", t.Name, err)
+	}
+	return opts, nil
+}
+
+// LoadTenants reads a tenants file:
+//
+//	tenants:
+//	  - name: platform
+//	    token: ...
+//	    target: kubernetes
+//	  - name: ci
+//	    token: ...
+//	    preset: default
+//	    options: {minSize: 40}
+//
+// Every tenant is validated up front so misconfiguration fails at startup.
+func LoadTenants(path string) ([]Tenant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading tenants: %w", err)
+	}
+	var file struct {
+		Tenants []Tenant `yaml:"tenants"`
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range file.Tenants {
+		t := &file.Tenants[i]
+		if t.Name == "" || t.Token == "" {
+			return nil, fmt.Errorf("%s: tenant %d needs a name and a token", path, i+1)
+		}
+		if seen[t.Token] {
+			return nil, fmt.Errorf("%s: tenant %s reuses another tenant's token", path, t.Name)
+		}
+		seen[t.Token] = true
+		if _, err := t.options(""); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return file.Tenants, nil
+}
@@ -0,0 +1,145 @@
+// Package analysis reports duplicated structures in YAML source, with their
+// positions, without rewriting or emitting anything. It is meant to back
+// editor integrations such as an "extract anchor" code action in a YAML
+// language server.
+package analysis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"gopkg.in/yaml.v3"
+)
+
+// Position is a location in the source.
+type Position struct {
+	// Line and Column are 1-based, with columns counted in characters as
+	// the YAML parser reports them.
+	Line   int `json:"line"`
+	Column int `json:"column"`
+
+	// Offset is the 0-based byte offset into the source.
+	Offset int `json:"offset"`
+}
+
+// Range is the source text of a node, from its first character (including
+// any anchor or tag) to just after its last one.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Occurrence is one copy of a duplicated structure.
+type Occurrence struct {
+	// Path locates the node in yamlmin.Query syntax, e.g. ".spec.ports[0]".
+	// Mapping keys have the path of the entry they introduce.
+	Path string `json:"path"`
+
+	// Key reports whether the node is a mapping key.
+	Key bool `json:"key,omitempty"`
+
+	// Document is the 0-based index of the document in the stream.
+	Document int `json:"document"`
+
+	Range Range `json:"range"`
+}
+
+// Duplicate is a group of structurally identical nodes.
+type Duplicate struct {
+	// Kind is "mapping", "sequence", or "scalar".
+	Kind string `json:"kind"`
+
+	// Size is the estimated size of one occurrence, in characters.
+	Size int `json:"size"`
+
+	// Score is the group's score under the options' Score function.
+	Score float64 `json:"score"`
+
+	// Selected reports whether yamlmin would anchor this group with the same
+	// options; Reason explains the decision either way.
+	Selected bool   `json:"selected"`
+	Reason   string `json:"reason"`
+
+	// Occurrences are in document order.
+	Occurrences []Occurrence `json:"occurrences"`
+}
+
+// Analyze reports the duplicate groups in every document of src, using opts
+// to decide what counts as a candidate.
+func Analyze(src []byte, opts yamlmin.Options) ([]Duplicate, error) {
+	dups, _, err := analyze(src, opts)
+	return dups, err
+}
+
+// analyze is Analyze, also returning the anchor names already in use.
+func analyze(src []byte, opts yamlmin.Options) ([]Duplicate, map[string]bool, error) {
+	idx := newSourceIndex(src)
+
+	var roots []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(src))
+	for {
+		var root yaml.Node
+		err := dec.Decode(&root)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing YAML: %w", err)
+		}
+		roots = append(roots, &root)
+	}
+
+	// Ranges need every node's successor, so collect them across the stream
+	// before indexing, which may reorder sets.
+	paths := make(map[*yaml.Node]string)
+	docs := make(map[*yaml.Node]int)
+	keys := make(map[*yaml.Node]bool)
+	anchors := make(map[string]bool)
+	for i, root := range roots {
+		idx.collect(root, i, "", paths, docs)
+		markKeys(root, keys, anchors)
+	}
+
+	var dups []Duplicate
+	for _, root := range roots {
+		for _, b := range yamlmin.Index(root, opts) {
+			if len(b.Nodes) < 2 {
+				continue
+			}
+			d := Duplicate{
+				Kind:     yamlmin.KindName(b.Kind),
+				Size:     b.Size,
+				Score:    b.Score,
+				Selected: b.Decision == yamlmin.DecisionAnchored,
+				Reason:   b.Decision,
+			}
+			for _, n := range b.Nodes {
+				d.Occurrences = append(d.Occurrences, Occurrence{
+					Path:     paths[n],
+					Key:      keys[n],
+					Document: docs[n],
+					Range:    idx.rangeOf(n),
+				})
+			}
+			dups = append(dups, d)
+		}
+	}
+	return dups, anchors, nil
+}
+
+// markKeys records which nodes under node are mapping keys, and every anchor
+// name in use.
+func markKeys(node *yaml.Node, keys map[*yaml.Node]bool, anchors map[string]bool) {
+	if node.Anchor != "" {
+		anchors[node.Anchor] = true
+	}
+	for i, child := range node.Content {
+		if node.Kind == yaml.MappingNode && i%2 == 0 {
+			keys[child] = true
+		}
+		markKeys(child, keys, anchors)
+	}
+}
@@ -0,0 +1,124 @@
+package analysis_test
+
+import (
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/glennpratt/yamlmin/pkg/yamlmin/analysis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyze(t *testing.T) {
+	input := `# services
+web:
+  env: &e
+    LOG_LEVEL: debug
+    REGION: us-east-1
+  ports: [8080, "8443"]
+worker:
+  env:
+    LOG_LEVEL: debug
+    REGION: us-east-1
+  # trailing comment
+  ports: [8080, "8443"]
+---
+- name: "a long name value here"
+- name: "a long name value here"
+`
+	opts := yamlmin.DefaultOptions()
+	opts.MinSize = 8
+	dups, err := analysis.Analyze([]byte(input), opts)
+	require.NoError(t, err)
+
+	text := func(r analysis.Range) string {
+		return input[r.Start.Offset:r.End.Offset]
+	}
+	byPath := make(map[string]analysis.Occurrence)
+	for _, d := range dups {
+		assert.GreaterOrEqual(t, len(d.Occurrences), 2)
+		for _, o := range d.Occurrences {
+			byPath[o.Path] = o
+		}
+	}
+
+	env := byPath[".web.env"]
+	assert.Equal(t, analysis.Position{Line: 3, Column: 8, Offset: 23}, env.Range.Start)
+	assert.Equal(t, "&e\n    LOG_LEVEL: debug\n    REGION: us-east-1", text(env.Range))
+	assert.Equal(t, "LOG_LEVEL: debug\n    REGION: us-east-1", text(byPath[".worker.env"].Range))
+
+	assert.Equal(t, `[8080, "8443"]`, text(byPath[".web.ports"].Range))
+	assert.Equal(t, `[8080, "8443"]`, text(byPath[".worker.ports"].Range))
+
+	item := byPath["[1]"]
+	assert.Equal(t, 1, item.Document)
+	assert.Equal(t, `name: "a long name value here"`, text(item.Range))
+	assert.Equal(t, `"a long name value here"`, text(byPath["[1].name"].Range))
+
+	_, err = analysis.Analyze([]byte("a: [b"), opts)
+	assert.Error(t, err)
+}
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name: "block mapping",
+			input: `web:
+  env:
+    LOG_LEVEL: debug
+    REGION: us-east-1
+  replicas: 1
+worker:
+  env:
+    LOG_LEVEL: debug
+    REGION: us-east-1
+  replicas: 2
+`,
+			want: `web:
+  env: &map1
+    LOG_LEVEL: debug
+    REGION: us-east-1
+  replicas: 1
+worker:
+  env: *map1
+  replicas: 2
+`,
+		},
+		{
+			name: "existing anchor and flow",
+			input: `a: &m0 {image: nginx, port: 8080, tls: true}
+b: {image: nginx, port: 8080, tls: true}
+c: *m0
+`,
+			want: `a: &m0 {image: nginx, port: 8080, tls: true}
+b: *m0
+c: *m0
+`,
+		},
+		{
+			name: "sequence items",
+			input: `- name: a shared long value
+  x: 1
+- name: a shared long value
+  x: 1
+`,
+			want: `- &map1
+  name: a shared long value
+  x: 1
+- *map1
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestions, err := analysis.Suggest([]byte(tt.input), yamlmin.DefaultOptions())
+			require.NoError(t, err)
+			require.Len(t, suggestions, 1)
+			assert.Equal(t, tt.want, string(analysis.Apply([]byte(tt.input), suggestions[0].Edits)))
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package analysis
+
+import (
+	"bytes"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"gopkg.in/yaml.v3"
+)
+
+// sourceIndex maps parser positions back to source text.
+type sourceIndex struct {
+	src        []byte
+	lineStarts []int
+
+	// order lists nodes in pre-order, which is source order; next[n] is the
+	// index in order of the first node after n's subtree.
+	order []*yaml.Node
+	next  map[*yaml.Node]int
+	start map[*yaml.Node]int
+}
+
+func newSourceIndex(src []byte) *sourceIndex {
+	idx := &sourceIndex{src: src, lineStarts: []int{0}, next: make(map[*yaml.Node]int), start: make(map[*yaml.Node]int)}
+	for i, c := range src {
+		if c == '\n' {
+			idx.lineStarts = append(idx.lineStarts, i+1)
+		}
+	}
+	return idx
+}
+
+// collect records node and its descendants in document order along with
+// their paths and document numbers.
+func (idx *sourceIndex) collect(node *yaml.Node, doc int, path string, paths map[*yaml.Node]string, docs map[*yaml.Node]int) {
+	if node.Kind != yaml.DocumentNode {
+		idx.start[node] = idx.offset(node.Line, node.Column)
+		idx.order = append(idx.order, node)
+		paths[node], docs[node] = path, doc
+		if path == "" {
+			paths[node] = "."
+		}
+	}
+	for i, child := range node.Content {
+		childP := path
+		switch {
+		case node.Kind == yaml.MappingNode && i%2 == 0:
+			// Keys are not addressable in Query syntax; give them the path
+			// of the entry they introduce.
+			childP = yamlmin.ChildPath(path, node, i+1)
+		case node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode:
+			childP = yamlmin.ChildPath(path, node, i)
+		}
+		idx.collect(child, doc, childP, paths, docs)
+	}
+	if node.Kind != yaml.DocumentNode {
+		idx.next[node] = len(idx.order)
+	}
+}
+
+// offset converts a 1-based line and character column to a byte offset.
+func (idx *sourceIndex) offset(line, column int) int {
+	if line < 1 || line > len(idx.lineStarts) {
+		return len(idx.src)
+	}
+	off := idx.lineStarts[line-1]
+	for c := 1; c < column && off < len(idx.src) && idx.src[off] != '\n'; c++ {
+		_, size := utf8.DecodeRune(idx.src[off:])
+		off += size
+	}
+	return off
+}
+
+// position converts a byte offset to a Position.
+func (idx *sourceIndex) position(off int) Position {
+	line := sort.Search(len(idx.lineStarts), func(i int) bool { return idx.lineStarts[i] > off })
+	col := utf8.RuneCount(idx.src[idx.lineStarts[line-1]:off]) + 1
+	return Position{Line: line, Column: col, Offset: off}
+}
+
+func (idx *sourceIndex) rangeOf(node *yaml.Node) Range {
+	start := idx.start[node]
+	return Range{Start: idx.position(start), End: idx.position(idx.end(node, start))}
+}
+
+// end finds the offset just past node's text. Flow collections and quoted
+// scalars are scanned directly; single-line plain scalars are their value;
+// anything else extends to the next node outside its subtree, less trailing
+// whitespace, comments, and indicators.
+func (idx *sourceIndex) end(node *yaml.Node, start int) int {
+	src := idx.src
+	off := skipProperties(src, start)
+	if off < len(src) {
+		switch src[off] {
+		case '[', '{':
+			if e := matchBracket(src, off); e > 0 {
+				return e
+			}
+		case '"', '\'':
+			if e := matchQuote(src, off); e > 0 {
+				return e
+			}
+		}
+	}
+	if node.Kind == yaml.ScalarNode && node.Style == 0 && bytes.HasPrefix(src[off:], []byte(node.Value)) &&
+		!bytes.ContainsRune([]byte(node.Value), '\n') {
+		return off + len(node.Value)
+	}
+
+	limit := len(src)
+	if n := idx.next[node]; n < len(idx.order) {
+		limit = idx.start[idx.order[n]]
+	}
+	return trimTail(src, start, limit)
+}
+
+// skipProperties skips an anchor and tag preceding a node's content.
+func skipProperties(src []byte, off int) int {
+	for off < len(src) && (src[off] == '&' || src[off] == '!') {
+		for off < len(src) && src[off] != ' ' && src[off] != '\t' && src[off] != '\n' {
+			off++
+		}
+		for off < len(src) && (src[off] == ' ' || src[off] == '\t') {
+			off++
+		}
+	}
+	return off
+}
+
+func matchBracket(src []byte, off int) int {
+	depth := 0
+	for i := off; i < len(src); i++ {
+		switch src[i] {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		case '"', '\'':
+			e := matchQuote(src, i)
+			if e < 0 {
+				return -1
+			}
+			i = e - 1
+		}
+	}
+	return -1
+}
+
+func matchQuote(src []byte, off int) int {
+	q := src[off]
+	for i := off + 1; i < len(src); i++ {
+		switch {
+		case q == '"' && src[i] == '\\':
+			i++
+		case src[i] == q && q == '\'' && i+1 < len(src) && src[i+1] == '\'':
+			i++
+		case src[i] == q:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// trimTail backs limit off over whitespace and over trailing lines that hold
+// only comments, sequence indicators, or document markers.
+func trimTail(src []byte, start, limit int) int {
+	for {
+		for limit > start && isSpace(src[limit-1]) {
+			limit--
+		}
+		lineStart := bytes.LastIndexByte(src[start:limit], '\n')
+		if lineStart < 0 {
+			return limit
+		}
+		lineStart += start + 1
+		line := bytes.TrimSpace(src[lineStart:limit])
+		if len(line) == 0 || line[0] == '#' || isIndicators(line) || string(line) == "---" || string(line) == "..." {
+			limit = lineStart
+			continue
+		}
+		return limit
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isIndicators(line []byte) bool {
+	for _, c := range line {
+		if c != '-' && c != '?' && c != ':' && c != ' ' {
+			return false
+		}
+	}
+	return true
+}
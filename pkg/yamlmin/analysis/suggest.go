@@ -0,0 +1,194 @@
+package analysis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"gopkg.in/yaml.v3"
+)
+
+// Edit replaces the text in Range with NewText. An empty range is an
+// insertion.
+type Edit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// Suggestion is an "extract anchor" refactoring for one duplicate group: an
+// anchor on the first occurrence and aliases in place of the others. Its
+// edits do not overlap and are sorted by position; apply them all or none.
+type Suggestion struct {
+	Title  string `json:"title"`
+	Anchor string `json:"anchor"`
+	Edits  []Edit `json:"edits"`
+}
+
+// Suggest returns a suggestion for each duplicate group yamlmin would anchor
+// with opts. Each suggestion is checked on its own: applying its edits to src
+// must leave the documents' values unchanged. Occurrences holding anchors of
+// their own are left in place, since aliases elsewhere may refer to them.
+func Suggest(src []byte, opts yamlmin.Options) ([]Suggestion, error) {
+	dups, anchors, err := analyze(src, opts)
+	if err != nil {
+		return nil, err
+	}
+	want, err := decodeAll(src)
+	if err != nil {
+		return nil, err
+	}
+
+	counters := make(map[string]int)
+	var suggestions []Suggestion
+	for _, d := range dups {
+		if !d.Selected {
+			continue
+		}
+		s, ok := suggestion(src, d, anchors, counters)
+		if !ok {
+			continue
+		}
+		got, err := decodeAll(Apply(src, s.Edits))
+		if err != nil || !reflect.DeepEqual(got, want) {
+			continue
+		}
+		anchors[s.Anchor] = true
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, nil
+}
+
+func suggestion(src []byte, d Duplicate, anchors map[string]bool, counters map[string]int) (Suggestion, bool) {
+	first := d.Occurrences[0]
+	text := src[first.Range.Start.Offset:first.Range.End.Offset]
+
+	var s Suggestion
+	if name, ok := anchorOf(text); ok {
+		s.Anchor = name
+	} else {
+		s.Anchor = freshAnchor(d.Kind, anchors, counters)
+		s.Edits = append(s.Edits, anchorEdit(src, d, first, s.Anchor))
+	}
+	for _, o := range d.Occurrences[1:] {
+		if o.Document != first.Document || bytes.IndexByte(src[o.Range.Start.Offset:o.Range.End.Offset], '&') >= 0 {
+			continue
+		}
+		s.Edits = append(s.Edits, aliasEdit(src, d, o, s.Anchor))
+	}
+	if len(s.Edits) == 0 || (len(s.Edits) == 1 && s.Edits[0].Range.Start == s.Edits[0].Range.End) {
+		return Suggestion{}, false
+	}
+	s.Title = fmt.Sprintf("Extract &%s from %d duplicate %ss at %s", s.Anchor, len(d.Occurrences), d.Kind, first.Path)
+	return s, true
+}
+
+// anchorOf returns the anchor name text starts with, if any.
+func anchorOf(text []byte) (string, bool) {
+	if len(text) == 0 || text[0] != '&' {
+		return "", false
+	}
+	end := bytes.IndexAny(text, " \t\r\n")
+	if end < 0 {
+		end = len(text)
+	}
+	return string(text[1:end]), true
+}
+
+// freshAnchor names anchors like yamlmin does, skipping names already used.
+func freshAnchor(kind string, anchors map[string]bool, counters map[string]int) string {
+	prefix := map[string]string{"mapping": "map", "sequence": "list"}[kind]
+	if prefix == "" {
+		prefix = "str"
+	}
+	for {
+		counters[prefix]++
+		name := prefix + strconv.Itoa(counters[prefix])
+		if !anchors[name] {
+			return name
+		}
+	}
+}
+
+// anchorEdit puts an anchor on the first occurrence. Properties in front of
+// a block collection's first entry would belong to that entry, so block
+// collections take theirs after the indicator introducing them instead.
+func anchorEdit(src []byte, d Duplicate, o Occurrence, name string) Edit {
+	if ind, ok := blockIndicator(src, d, o); ok {
+		indent := strings.Repeat(" ", o.Range.Start.Column-1)
+		return Edit{Range: Range{Start: positionAt(src, ind), End: o.Range.Start}, NewText: " &" + name + "\n" + indent}
+	}
+	return Edit{Range: Range{Start: o.Range.Start, End: o.Range.Start}, NewText: "&" + name + " "}
+}
+
+// aliasEdit replaces an occurrence with an alias, joining a block collection's
+// alias to its indicator's line.
+func aliasEdit(src []byte, d Duplicate, o Occurrence, name string) Edit {
+	if ind, ok := blockIndicator(src, d, o); ok {
+		return Edit{Range: Range{Start: positionAt(src, ind), End: o.Range.End}, NewText: " *" + name}
+	}
+	alias := "*" + name
+	if o.Key {
+		// "*a:" would read as an alias named "a:".
+		alias += " "
+	}
+	return Edit{Range: o.Range, NewText: alias}
+}
+
+// blockIndicator returns the offset just past the ":" or "-" that introduces
+// o, when o is a block collection.
+func blockIndicator(src []byte, d Duplicate, o Occurrence) (int, bool) {
+	start := o.Range.Start.Offset
+	if d.Kind == "scalar" || src[start] == '[' || src[start] == '{' || src[start] == '&' || src[start] == '!' {
+		return 0, false
+	}
+	i := start
+	for i > 0 && isSpace(src[i-1]) {
+		i--
+	}
+	if i > 0 && (src[i-1] == ':' || src[i-1] == '-') {
+		return i, true
+	}
+	return 0, false
+}
+
+func positionAt(src []byte, off int) Position {
+	return newSourceIndex(src).position(off)
+}
+
+// Apply returns src with edits applied. Edits must not overlap.
+func Apply(src []byte, edits []Edit) []byte {
+	sorted := append([]Edit(nil), edits...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Range.Start.Offset < sorted[j].Range.Start.Offset })
+
+	var out bytes.Buffer
+	last := 0
+	for _, e := range sorted {
+		out.Write(src[last:e.Range.Start.Offset])
+		out.WriteString(e.NewText)
+		last = e.Range.End.Offset
+	}
+	out.Write(src[last:])
+	return out.Bytes()
+}
+
+// decodeAll decodes every document in src to plain values.
+func decodeAll(src []byte) ([]interface{}, error) {
+	var docs []interface{}
+	dec := yaml.NewDecoder(bytes.NewReader(src))
+	for {
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			if errors.Is(err, io.EOF) {
+				return docs, nil
+			}
+			return nil, err
+		}
+		docs = append(docs, v)
+	}
+}
@@ -0,0 +1,213 @@
+package yamlmin
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// pruneAnchors clears anchors that no alias in the tree refers to. Unlike
+// removeUnusedAnchors it works on any tree, including one rewritten after the
+// alias pass.
+func pruneAnchors(root *yaml.Node) {
+	used := make(map[*yaml.Node]bool)
+	var anchored []*yaml.Node
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		if node == nil {
+			return
+		}
+		if node.Kind == yaml.AliasNode {
+			used[node.Alias] = true
+			return
+		}
+		if node.Anchor != "" {
+			anchored = append(anchored, node)
+		}
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	for _, node := range anchored {
+		if !used[node] {
+			node.Anchor = ""
+		}
+	}
+}
+
+// countRefs returns the number of anchors and aliases in the tree.
+func countRefs(root *yaml.Node) (anchors, aliases int) {
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		if node == nil {
+			return
+		}
+		if node.Kind == yaml.AliasNode {
+			aliases++
+			return
+		}
+		if node.Anchor != "" {
+			anchors++
+		}
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+	walk(root)
+	return anchors, aliases
+}
+
+// anchorSavings estimates the bytes saved by each anchor in root; see
+// Stats.AnchorSavings.
+func (df *duplicateFinder) anchorSavings(root *yaml.Node) map[string]int {
+	refs := make(map[*yaml.Node]int)
+	var anchored []*yaml.Node
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		if node == nil {
+			return
+		}
+		if node.Kind == yaml.AliasNode {
+			refs[node.Alias]++
+			return
+		}
+		if node.Anchor != "" {
+			anchored = append(anchored, node)
+		}
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	savings := make(map[string]int, len(anchored))
+	for _, node := range anchored {
+		savings[node.Anchor] = estimatedSavings(df.estimateSize(node, 0), refs[node], len(node.Anchor))
+	}
+	return savings
+}
+
+// estimatedSavings estimates the bytes saved by sharing content of the given
+// size through an anchor with nameLen characters and the given number of
+// aliases: each alias replaces a copy, and the anchor itself costs a marker.
+func estimatedSavings(size, aliases, nameLen int) int {
+	marker := len("*") + nameLen
+	return aliases*(size-marker) - marker - len(" ")
+}
+
+// orderAnchors makes every anchor precede its aliases in document order, as
+// YAML requires. Rewrites such as moving merged pairs to the front of a
+// mapping can put an alias first; its anchored node then trades places with
+// it, which leaves the content the same.
+func orderAnchors(root *yaml.Node) {
+	type slot struct {
+		parent *yaml.Node
+		index  int
+	}
+	where := make(map[*yaml.Node]slot)
+	var index func(node *yaml.Node)
+	index = func(node *yaml.Node) {
+		for i, child := range node.Content {
+			if child.Anchor != "" {
+				where[child] = slot{node, i}
+			}
+			if child.Kind != yaml.AliasNode {
+				index(child)
+			}
+		}
+	}
+	index(root)
+
+	seen := make(map[*yaml.Node]bool)
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		for i, child := range node.Content {
+			if child.Kind == yaml.AliasNode && !seen[child.Alias] {
+				if s, ok := where[child.Alias]; ok {
+					s.parent.Content[s.index] = child
+					node.Content[i] = child.Alias
+					where[child.Alias] = slot{node, i}
+					child = child.Alias
+				}
+			}
+			if child.Anchor != "" {
+				seen[child] = true
+			}
+			if child.Kind != yaml.AliasNode {
+				walk(child)
+			}
+		}
+	}
+	walk(root)
+}
+
+// adoptAnchors prepares a document that already carries anchors, such as
+// yamlmin's own output, so that minifying it again changes nothing. Anchors
+// in the input keep their names and are tracked like the ones this run
+// creates, and new anchors never reuse those names. "!!merge" keys are
+// written back as the plain "<<" they came from, and fingerprint comments are
+// dropped because they are regenerated.
+func (df *duplicateFinder) adoptAnchors(root *yaml.Node) {
+	var walk func(node, holder *yaml.Node, base bool)
+	walk = func(node, holder *yaml.Node, base bool) {
+		if node.Anchor != "" {
+			df.taken[node.Anchor] = true
+			// A Decoder adds "docN_" under UniqueAnchors after this pass,
+			// so the unprefixed name is taken as well.
+			if rest, ok := strings.CutPrefix(node.Anchor, "doc"); ok {
+				if i := strings.IndexByte(rest, '_'); i > 0 && isDigits(rest[:i]) {
+					df.taken[rest[i+1:]] = true
+				}
+			}
+			if _, ok := df.anchorNodes[node.Anchor]; !ok && !base {
+				df.anchorNodes[node.Anchor] = &anchorInfo{node: node, holder: holder, existing: true}
+			}
+		}
+		node.HeadComment = stripFingerprint(node.HeadComment)
+		node.LineComment = stripFingerprint(node.LineComment)
+		node.FootComment = stripFingerprint(node.FootComment)
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i < len(node.Content); i += 2 {
+				key, value := node.Content[i], node.Content[i+1]
+				if key.Tag == "!!merge" && key.Value == "<<" {
+					key.Tag = ""
+				}
+				walk(key, key, false)
+				walk(value, key, isMergeKey(key))
+			}
+		case yaml.AliasNode:
+		default:
+			for _, child := range node.Content {
+				walk(child, child, false)
+			}
+		}
+	}
+	walk(root, root, false)
+}
+
+// stripFingerprint removes FingerprintPrefix lines from a comment.
+func stripFingerprint(comment string) string {
+	if !strings.Contains(comment, FingerprintPrefix) {
+		return comment
+	}
+	lines := strings.Split(comment, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), FingerprintPrefix) {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -70,6 +70,27 @@ func BenchmarkMarshal(b *testing.B) {
 	b.SetBytes(int64(len(output)))
 }
 
+func BenchmarkMinifier(b *testing.B) {
+	input, err := os.ReadFile("testdata/fixture.yaml")
+	require.NoError(b, err)
+	var data interface{}
+	require.NoError(b, yaml.Unmarshal(input, &data))
+	m := yamlmin.NewMinifier(yamlmin.DefaultOptions())
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	var output []byte
+	for b.Loop() {
+		var err error
+		output, err = m.Marshal(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.SetBytes(int64(len(output)))
+}
+
 func BenchmarkK8sMarshal(b *testing.B) {
 	input, err := os.ReadFile("testdata/fixture.yaml")
 	require.NoError(b, err)
@@ -0,0 +1,69 @@
+package yamlmin
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CommentMode controls how comments interact with deduplication.
+type CommentMode string
+
+const (
+	// CommentsDrop ignores comments when comparing structures; comments
+	// inside an occurrence replaced by an alias are lost.
+	CommentsDrop CommentMode = ""
+
+	// CommentsStrict treats comments as content: structures deduplicate only
+	// when their comments are identical too, so no comment is ever lost.
+	CommentsStrict CommentMode = "strict"
+
+	// CommentsKeep ignores comments when comparing structures, but moves the
+	// comments attached to a replaced occurrence itself onto its alias (or its
+	// mapping key). Comments nested deeper inside it are still lost.
+	CommentsKeep CommentMode = "keep"
+)
+
+func (m CommentMode) validate() error {
+	switch m {
+	case CommentsDrop, CommentsStrict, CommentsKeep:
+		return nil
+	}
+	return fmt.Errorf("unknown comment mode %q", m)
+}
+
+// writeCommentsToHash adds a node's comments to its hash in CommentsStrict.
+func (df *duplicateFinder) writeCommentsToHash(h interface{ Write([]byte) (int, error) }, node *yaml.Node) error {
+	if df.comments != CommentsStrict {
+		return nil
+	}
+	for _, c := range [...]string{node.HeadComment, node.LineComment, node.FootComment} {
+		if _, err := h.Write(append([]byte(c), 0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// carryComments moves the comments of a node being replaced by alias in
+// CommentsKeep. Line comments go on the alias; head and foot comments go on
+// holder, the mapping key for mapping values, since yaml.v3 misplaces them on
+// aliases there.
+func (df *duplicateFinder) carryComments(alias, replaced, holder *yaml.Node) {
+	if df.comments != CommentsKeep {
+		return
+	}
+	alias.LineComment = replaced.LineComment
+	holder.HeadComment = joinComments(holder.HeadComment, replaced.HeadComment)
+	holder.FootComment = joinComments(holder.FootComment, replaced.FootComment)
+}
+
+func joinComments(a, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	return a + "\n" + b
+}
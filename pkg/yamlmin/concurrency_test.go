@@ -0,0 +1,83 @@
+package yamlmin_test
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// TestConcurrentMarshal runs the stateless entry points from many goroutines
+// with a shared Options value; run it with -race (see make race-test).
+func TestConcurrentMarshal(t *testing.T) {
+	data, err := os.ReadFile("testdata/fixture.yaml")
+	require.NoError(t, err)
+	var value interface{}
+	require.NoError(t, yaml.Unmarshal(data, &value))
+
+	opts := yamlmin.DefaultOptions()
+	opts.Verify = true
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	opts.MinOccurrencesByKind = map[yaml.Kind]int{yaml.ScalarNode: 3}
+	opts.SetKeys = []string{"tags"}
+
+	wantMarshal, err := yamlmin.MarshalWithOptions(value, opts)
+	require.NoError(t, err)
+	wantK8s, err := yamlmin.K8sMarshalWithOptions(value, opts)
+	require.NoError(t, err)
+	wantString, err := yamlmin.MinifyString(string(data), "")
+	require.NoError(t, err)
+
+	const workers = 8
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 2 {
+				out, err := yamlmin.MarshalWithOptions(value, opts)
+				assert.NoError(t, err)
+				assert.Equal(t, string(wantMarshal), string(out))
+
+				out, err = yamlmin.K8sMarshalWithOptions(value, opts)
+				assert.NoError(t, err)
+				assert.Equal(t, string(wantK8s), string(out))
+
+				str, err := yamlmin.MinifyString(string(data), "")
+				assert.NoError(t, err)
+				assert.Equal(t, wantString, str)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestConcurrentDecoders checks that independent Decoders do not interfere.
+func TestConcurrentDecoders(t *testing.T) {
+	input := strings.Repeat("---\na: a long repeated string\nb: a long repeated string\n", 20)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dec := yamlmin.NewDecoder(strings.NewReader(input), yamlmin.DefaultOptions())
+			for {
+				doc, _, err := dec.Decode()
+				if err == io.EOF {
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, "a: &str1 a long repeated string\nb: *str1\n", string(doc))
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,94 @@
+package yamlmin
+
+import (
+	"math"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CostModel estimates what the parts of a deduplicated document cost in the
+// size metric a consumer cares about. CostScore turns one into a Score, so
+// anchoring decisions optimize for that metric.
+type CostModel interface {
+	// NodeCost is the cost of writing one occurrence of the group in full.
+	NodeCost(group DuplicateGroup) float64
+
+	// AnchorCost is the extra cost of sharing the group: the anchor on its
+	// first occurrence, or the definition entry for $ref output.
+	AnchorCost(group DuplicateGroup) float64
+
+	// AliasCost is the cost of one reference replacing an occurrence.
+	AliasCost(group DuplicateGroup) float64
+}
+
+// CostScore returns a Score ranking groups by the cost m says sharing them
+// saves, with every occurrence after the first replaced by an alias. Groups
+// that would save nothing score <= 0 and are left as they are.
+func CostScore(m CostModel) func(group DuplicateGroup) float64 {
+	return func(group DuplicateGroup) float64 {
+		aliases := float64(group.Occurrences - 1)
+		return aliases*(m.NodeCost(group)-m.AliasCost(group)) - m.AnchorCost(group)
+	}
+}
+
+var (
+	// YAMLCost counts bytes of YAML output: scalar text plus about two bytes
+	// of indicators and indentation per node, against anchors like " &map12"
+	// and aliases like "*map12".
+	YAMLCost CostModel = yamlCost{}
+
+	// JSONRefCost counts bytes of JSON output in which shared structures
+	// become definitions referenced by {"$ref": ...} objects, as with
+	// Options.RefMode or YAML converted to JSON Schema. Every occurrence,
+	// the first included, becomes a reference.
+	JSONRefCost CostModel = jsonRefCost{}
+
+	// GzipCost counts bytes after gzip compression, which already stores a
+	// repeat within its 32 KiB window as back-references of about three bytes
+	// per 258 bytes matched. Only structures large enough that aliases beat
+	// that are worth sharing.
+	GzipCost CostModel = gzipCost{}
+)
+
+type yamlCost struct{}
+
+func (yamlCost) NodeCost(g DuplicateGroup) float64   { return float64(g.Size + 2*g.Nodes) }
+func (yamlCost) AnchorCost(g DuplicateGroup) float64 { return float64(len(" &map12")) }
+func (yamlCost) AliasCost(g DuplicateGroup) float64  { return float64(len("*map12")) }
+
+type jsonRefCost struct{}
+
+const jsonRef = `{"$ref":"#/$defs/map12"}`
+
+func (jsonRefCost) NodeCost(g DuplicateGroup) float64 {
+	// Quotes around strings, plus a colon or comma per node.
+	return float64(g.Size + 3*g.Nodes)
+}
+func (jsonRefCost) AnchorCost(g DuplicateGroup) float64 {
+	return float64(len(`"map12":,`) + len(jsonRef))
+}
+func (jsonRefCost) AliasCost(g DuplicateGroup) float64 { return float64(len(jsonRef)) }
+
+type gzipCost struct{}
+
+func (gzipCost) NodeCost(g DuplicateGroup) float64 {
+	return 3 * math.Ceil(yamlCost{}.NodeCost(g)/258)
+}
+func (gzipCost) AnchorCost(g DuplicateGroup) float64 { return yamlCost{}.AnchorCost(g) }
+func (gzipCost) AliasCost(g DuplicateGroup) float64  { return 2 }
+
+// countNodes returns the number of nodes in node, keys included, within the
+// same depth and width limits as estimateSize.
+func (df *duplicateFinder) countNodes(node *yaml.Node, depth int) int {
+	if node == nil || depth > df.maxDepth {
+		return 0
+	}
+	n := 1
+	for i, child := range node.Content {
+		if node.Kind == yaml.MappingNode && i/2 >= df.maxWidth || node.Kind == yaml.SequenceNode && i >= df.maxWidth {
+			break
+		}
+		n += df.countNodes(child, depth+1)
+	}
+	return n
+}
@@ -0,0 +1,119 @@
+package yamlmin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Actions a GroupDecision can take.
+const (
+	ActionAccept = "accept"
+	ActionReject = "reject"
+)
+
+// GroupDecision is a reviewed decision about one duplicate group.
+type GroupDecision struct {
+	// Action is ActionAccept or ActionReject. A rejected group is never
+	// anchored; an accepted one is anchored as usual when it qualifies.
+	Action string `json:"action"`
+
+	// Name is the anchor name to give an accepted group instead of a
+	// generated one, unless the input already uses it.
+	Name string `json:"name,omitempty"`
+}
+
+// Decisions maps duplicate group hashes to reviewed decisions, such as those
+// made in yamlmin tui, so later runs repeat them. In JSON the keys are the
+// hashes as 16 hex digits, as in fingerprint comments.
+type Decisions map[uint64]GroupDecision
+
+func (d Decisions) MarshalJSON() ([]byte, error) {
+	m := make(map[string]GroupDecision, len(d))
+	for hash, g := range d {
+		m[fmt.Sprintf("%016x", hash)] = g
+	}
+	return json.Marshal(m)
+}
+
+func (d *Decisions) UnmarshalJSON(data []byte) error {
+	var m map[string]GroupDecision
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	out := make(Decisions, len(m))
+	for key, g := range m {
+		hash, err := strconv.ParseUint(key, 16, 64)
+		if err != nil {
+			return fmt.Errorf("decisions: invalid group hash %q", key)
+		}
+		out[hash] = g
+	}
+	if err := out.validate(); err != nil {
+		return err
+	}
+	*d = out
+	return nil
+}
+
+func (d Decisions) validate() error {
+	for hash, g := range d {
+		switch g.Action {
+		case ActionAccept, ActionReject:
+		default:
+			return fmt.Errorf("decisions: group %016x: unknown action %q, want accept or reject", hash, g.Action)
+		}
+		if g.Name != "" && !ValidAnchorName(g.Name) {
+			return fmt.Errorf("decisions: group %016x: invalid anchor name %q: use only letters, digits, '_', and '-'", hash, g.Name)
+		}
+	}
+	return nil
+}
+
+// reserveDecidedNames holds the names accepted decisions give their groups,
+// so no generated name can take them first.
+func (df *duplicateFinder) reserveDecidedNames() {
+	for _, g := range df.decisions {
+		if g.Action == ActionAccept && g.Name != "" && !df.taken[g.Name] {
+			df.taken[g.Name] = true
+			df.reserved[g.Name] = true
+		}
+	}
+}
+
+// rejected reports whether a decision keeps the group from being anchored.
+func (df *duplicateFinder) rejected(hash uint64) bool {
+	g, ok := df.decisions[hash]
+	return ok && g.Action == ActionReject
+}
+
+// anchorName returns the name for a new anchor on node, of the group with
+// hash: the name an anchor-name comment gives it, else the decided name when
+// there is one the input does not use, else one from Options.AnchorNaming,
+// else the next generated one.
+func (df *duplicateFinder) anchorName(node *yaml.Node, hash uint64) string {
+	if len(df.directed) > 0 {
+		if name := df.directedName(hash); name != "" {
+			return name
+		}
+	}
+	if g, ok := df.decisions[hash]; ok && g.Name != "" && df.reserved[g.Name] {
+		delete(df.reserved, g.Name)
+		return g.Name
+	}
+	if df.contextNames {
+		if name := df.contextName(node); name != "" {
+			return name
+		}
+	} else if df.nameKeys != nil {
+		if name := df.fieldName(node); name != "" {
+			return name
+		}
+	}
+	if df.hashNames {
+		return df.hashName(kindPrefix(node), hash)
+	}
+	return df.nextAnchorName(node)
+}
@@ -0,0 +1,330 @@
+package yamlmin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Result describes the outcome of minifying a single document.
+type Result struct {
+	// InputBytes is the size of the document encoded without deduplication.
+	InputBytes int
+
+	// OutputBytes is the size of the minified document.
+	OutputBytes int
+
+	// Anchors is the number of anchors in the minified document.
+	Anchors int
+
+	// Aliases is the number of aliases in the minified document.
+	Aliases int
+
+	// HashCollisions is the number of hash buckets found to hold structurally
+	// distinct nodes. Only counted when Options.Verify is set.
+	HashCollisions int
+
+	// NodesScanned is the number of nodes examined for duplicates.
+	NodesScanned int
+
+	// Candidates is the number of nodes hashed as potential duplicates.
+	Candidates int
+
+	// IndexEntries is the number of distinct structures in the hash index.
+	IndexEntries int
+
+	// AuxBytes estimates the peak memory, in bytes, of the scan and index
+	// bookkeeping, not counting the node tree itself. Stream totals hold the
+	// largest value of any document, as documents are processed one at a
+	// time.
+	AuxBytes int
+
+	// Truncated lists the Query paths of subtrees cut to fit
+	// Options.MaxOutputBytes, in the order they were cut. Stream totals list
+	// the paths of every document in turn.
+	Truncated []string
+
+	// MergePasses is the number of detection passes Options.MergeSubsets
+	// and Options.SequenceRuns ran, at most four; 0 without them. Stream totals hold the largest value
+	// of any document.
+	MergePasses int
+
+	// TimedOut is set when Options.TimeLimit ran out before deduplication
+	// finished, so the document may keep duplicates a longer run would
+	// anchor. Stream totals are set when any document's is.
+	TimedOut bool
+}
+
+// Add sums doc, the result of one document of a stream, into r, the
+// stream's totals.
+func (r *Result) Add(doc Result) {
+	r.InputBytes += doc.InputBytes
+	r.OutputBytes += doc.OutputBytes
+	r.Anchors += doc.Anchors
+	r.Aliases += doc.Aliases
+	r.HashCollisions += doc.HashCollisions
+	r.NodesScanned += doc.NodesScanned
+	r.Candidates += doc.Candidates
+	r.IndexEntries += doc.IndexEntries
+	r.AuxBytes = max(r.AuxBytes, doc.AuxBytes)
+	r.Truncated = append(r.Truncated, doc.Truncated...)
+	r.MergePasses = max(r.MergePasses, doc.MergePasses)
+	r.TimedOut = r.TimedOut || doc.TimedOut
+}
+
+// Decoder reads a YAML stream and minifies it one document at a time.
+type Decoder struct {
+	dec  *yaml.Decoder
+	opts Options
+
+	// With Options.Passthrough the stream is split into raw documents up
+	// front, so unmodified documents can be returned byte for byte.
+	r    io.Reader
+	raw  []rawDocument
+	read bool
+
+	docs int       // documents read so far
+	min  *Minifier // reused state, when decoding for a Minifier
+}
+
+// NewDecoder returns a Decoder that reads documents from r and minifies them
+// with opts.
+func NewDecoder(r io.Reader, opts Options) *Decoder {
+	if opts.Passthrough {
+		return &Decoder{opts: opts, r: r}
+	}
+	return &Decoder{dec: yaml.NewDecoder(r), opts: opts}
+}
+
+// Decode reads the next document from the stream and returns it minified,
+// along with statistics for that document. It returns io.EOF when the stream
+// has no more documents.
+func (d *Decoder) Decode() ([]byte, Result, error) {
+	out, res, err := d.decode()
+	if err == nil && d.opts.ConcatSafe {
+		n := len(out)
+		out = concatSafe(out)
+		res.OutputBytes += len(out) - n
+	}
+	return out, res, err
+}
+
+// WriteStream minifies every remaining document, writes them to w separated
+// by document markers, and returns their totals. When each is not nil it is
+// called with every document and its result once the document is written,
+// and an error from it stops the stream.
+func (d *Decoder) WriteStream(w io.Writer, each func(doc []byte, res Result) error) (Result, error) {
+	var total Result
+	for n := 0; ; n++ {
+		doc, res, err := d.Decode()
+		if errors.Is(err, io.EOF) {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+		if n > 0 && !StartsDocument(doc) {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return total, err
+			}
+		}
+		if _, err := w.Write(doc); err != nil {
+			return total, err
+		}
+		total.Add(res)
+		if each != nil {
+			if err := each(doc, res); err != nil {
+				return total, err
+			}
+		}
+	}
+}
+
+func (d *Decoder) decode() ([]byte, Result, error) {
+	if d.opts.Passthrough {
+		return d.decodeRaw()
+	}
+
+	var root yaml.Node
+	if err := d.dec.Decode(&root); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, Result{}, io.EOF
+		}
+		return nil, Result{}, fmt.Errorf("parsing YAML: %w", err)
+	}
+	out, res, _, err := d.minify(&root)
+	return out, res, err
+}
+
+// minify processes a parsed document. changed is false when the document was
+// deselected or processing left it as it was.
+func (d *Decoder) minify(root *yaml.Node) (out []byte, res Result, changed bool, err error) {
+	d.docs++
+	before, err := encodeNode(root, d.opts)
+	if err != nil {
+		return nil, Result{}, false, err
+	}
+	if d.opts.Select != nil && !d.opts.Select(root) {
+		return before, Result{InputBytes: len(before), OutputBytes: len(before)}, false, nil
+	}
+
+	if d.min != nil {
+		res, err = d.min.process(root)
+	} else {
+		res, err = process(root, d.opts)
+	}
+	if err != nil {
+		return nil, Result{}, false, err
+	}
+	if d.opts.UniqueAnchors {
+		prefixAnchors(root, "doc"+strconv.Itoa(d.docs)+"_")
+	}
+	out, err = encodeNode(root, d.opts)
+	if err != nil {
+		return nil, Result{}, false, err
+	}
+	if out, res.Truncated, err = fitOutput(root, d.opts, out); err != nil {
+		return nil, Result{}, false, err
+	}
+
+	res.InputBytes, res.OutputBytes = len(before), len(out)
+	res.Anchors, res.Aliases = countRefs(root)
+	return out, res, !bytes.Equal(before, out), nil
+}
+
+// prefixAnchors renames every anchor under node, and the aliases referring
+// to them, by prepending prefix. Names that already have it are left alone,
+// so prefixed input keeps its names.
+func prefixAnchors(node *yaml.Node, prefix string) {
+	if node.Anchor != "" && !strings.HasPrefix(node.Anchor, prefix) {
+		node.Anchor = prefix + node.Anchor
+	}
+	if node.Kind == yaml.AliasNode && !strings.HasPrefix(node.Value, prefix) {
+		node.Value = prefix + node.Value
+	}
+	for _, child := range node.Content {
+		prefixAnchors(child, prefix)
+	}
+}
+
+// decodeRaw is Decode for Options.Passthrough. Documents that are not
+// selected, not changed, not made smaller, or not valid YAML are returned as
+// their original bytes.
+func (d *Decoder) decodeRaw() ([]byte, Result, error) {
+	if !d.read {
+		data, err := io.ReadAll(d.r)
+		if err != nil {
+			return nil, Result{}, err
+		}
+		d.raw, d.read = splitDocuments(data), true
+	}
+	if len(d.raw) == 0 {
+		return nil, Result{}, io.EOF
+	}
+	doc := d.raw[0]
+	d.raw = d.raw[1:]
+
+	passthrough := Result{InputBytes: len(doc.data), OutputBytes: len(doc.data)}
+	var root yaml.Node
+	if err := yaml.Unmarshal(doc.data, &root); err != nil {
+		return doc.data, passthrough, nil
+	}
+	out, res, changed, err := d.minify(&root)
+	if err != nil {
+		return nil, Result{}, err
+	}
+	if doc.inline || len(res.Truncated) > 0 || (changed && len(out) < len(doc.data)) {
+		return out, res, nil
+	}
+	passthrough.Anchors, passthrough.Aliases = res.Anchors, res.Aliases
+	return doc.data, passthrough, nil
+}
+
+// rawDocument is the source text of one document in a stream.
+type rawDocument struct {
+	data []byte
+
+	// inline is set when the document began on its "---" line (as in
+	// "--- |"). data then starts with that marker, so the document is always
+	// re-encoded rather than passed through.
+	inline bool
+}
+
+// splitDocuments splits a stream at "---" and "..." marker lines, which YAML
+// only allows at the start of a line and never inside content. Chunks holding
+// only comments or blank lines are kept with the following document.
+func splitDocuments(data []byte) []rawDocument {
+	var docs []rawDocument
+	var cur []byte
+	inline := false
+	var pending []byte // comment-only text waiting for the next document
+
+	flush := func() {
+		var probe yaml.Node
+		err := yaml.Unmarshal(cur, &probe)
+		switch {
+		case err == nil && probe.Kind == 0:
+			pending = append(pending, cur...)
+		default:
+			doc := append(pending, cur...)
+			if len(doc) > 0 && doc[len(doc)-1] != '\n' {
+				doc = append(doc, '\n')
+			}
+			docs = append(docs, rawDocument{data: doc, inline: inline})
+			pending = nil
+		}
+		cur, inline = nil, false
+	}
+
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		text := bytes.TrimRight(line, "\r\n")
+		switch {
+		case isMarker(text, "---"):
+			flush()
+			if rest := bytes.TrimSpace(text[3:]); len(rest) > 0 && rest[0] != '#' {
+				cur, inline = append(cur, line...), true
+			}
+		case isMarker(text, "..."):
+			flush()
+		default:
+			cur = append(cur, line...)
+		}
+	}
+	flush()
+	if len(pending) > 0 && len(docs) > 0 {
+		last := &docs[len(docs)-1]
+		last.data = append(last.data, pending...)
+	}
+	return docs
+}
+
+// StartsDocument reports whether doc begins with a "---" marker line, as
+// documents do with Options.ConcatSafe. Stream writers only separate
+// documents that don't.
+func StartsDocument(doc []byte) bool {
+	line, _, _ := bytes.Cut(doc, []byte("\n"))
+	return isMarker(bytes.TrimRight(line, "\r"), "---")
+}
+
+// concatSafe gives doc a leading marker and a trailing newline.
+func concatSafe(doc []byte) []byte {
+	if !StartsDocument(doc) {
+		doc = append([]byte("---\n"), doc...)
+	}
+	if doc[len(doc)-1] != '\n' {
+		doc = append(doc, '\n')
+	}
+	return doc
+}
+
+func isMarker(line []byte, marker string) bool {
+	if !bytes.HasPrefix(line, []byte(marker)) {
+		return false
+	}
+	return len(line) == 3 || line[3] == ' ' || line[3] == '\t'
+}
@@ -0,0 +1,308 @@
+package yamlmin_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestDecoder(t *testing.T) {
+	input := `a: a long repeated string
+b: a long repeated string
+---
+c: unique
+`
+	dec := yamlmin.NewDecoder(strings.NewReader(input), yamlmin.DefaultOptions())
+
+	doc, res, err := dec.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, "a: &str1 a long repeated string\nb: *str1\n", string(doc))
+	assert.Equal(t, yamlmin.Result{InputBytes: 52, OutputBytes: len(doc), Anchors: 1, Aliases: 1, NodesScanned: 4, Candidates: 3, IndexEntries: 2, AuxBytes: 371}, res)
+
+	doc, res, err = dec.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, "c: unique\n", string(doc))
+	assert.Equal(t, yamlmin.Result{InputBytes: 10, OutputBytes: 10, NodesScanned: 3, AuxBytes: 96}, res)
+
+	_, _, err = dec.Decode()
+	assert.True(t, errors.Is(err, io.EOF))
+}
+
+func TestWriteStream(t *testing.T) {
+	input := "a: a long repeated string\nb: a long repeated string\n---\nc: unique\n"
+	var out bytes.Buffer
+	var docs []yamlmin.Result
+	total, err := yamlmin.NewDecoder(strings.NewReader(input), yamlmin.DefaultOptions()).WriteStream(&out, func(doc []byte, res yamlmin.Result) error {
+		docs = append(docs, res)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "a: &str1 a long repeated string\nb: *str1\n---\nc: unique\n", out.String())
+	require.Len(t, docs, 2)
+	var sum yamlmin.Result
+	sum.Add(docs[0])
+	sum.Add(docs[1])
+	assert.Equal(t, sum, total)
+	assert.Equal(t, yamlmin.Result{InputBytes: 62, OutputBytes: out.Len() - len("---\n"), Anchors: 1, Aliases: 1, NodesScanned: 7, Candidates: 3, IndexEntries: 2, AuxBytes: 371}, total)
+
+	// An error from each stops the stream after the document it was given.
+	out.Reset()
+	stop := errors.New("stop")
+	_, err = yamlmin.NewDecoder(strings.NewReader(input), yamlmin.DefaultOptions()).WriteStream(&out, func([]byte, yamlmin.Result) error {
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, "a: &str1 a long repeated string\nb: *str1\n", out.String())
+}
+
+func TestResultAdd(t *testing.T) {
+	total := yamlmin.Result{AuxBytes: 300, Truncated: []string{".a"}, MergePasses: 2, HashCollisions: 1}
+	total.Add(yamlmin.Result{InputBytes: 5, AuxBytes: 100, Truncated: []string{".b"}, MergePasses: 1, HashCollisions: 2})
+	assert.Equal(t, yamlmin.Result{InputBytes: 5, AuxBytes: 300, Truncated: []string{".a", ".b"}, MergePasses: 2, HashCollisions: 3}, total)
+}
+
+func TestMinifyString(t *testing.T) {
+	input := "a: a long repeated string\nb: a long repeated string\n---\nc: [x, x]\n"
+
+	out, err := yamlmin.MinifyString(input, "")
+	require.NoError(t, err)
+	assert.Equal(t, "a: &str1 a long repeated string\nb: *str1\n---\nc: [x, x]\n", out)
+
+	out, err = yamlmin.MinifyString(input, `{"MinOccurrences": 3}`)
+	require.NoError(t, err)
+	assert.Equal(t, "a: a long repeated string\nb: a long repeated string\n---\nc: [x, x]\n", out)
+
+	_, err = yamlmin.MinifyString(input, `{`)
+	assert.Error(t, err)
+}
+
+func TestMinify(t *testing.T) {
+	input := "a: a long repeated string\nb: a long repeated string\n---\nc: [x, x]\n---\nd: [\n"
+
+	// The first documents are written before the bad one is read.
+	var out bytes.Buffer
+	err := yamlmin.Minify(strings.NewReader(input), &out, yamlmin.DefaultOptions())
+	assert.Error(t, err)
+	assert.Equal(t, "a: &str1 a long repeated string\nb: *str1\n---\nc: [x, x]\n", out.String())
+}
+
+func TestMinifyBytes(t *testing.T) {
+	input := `# config
+zeta: 'a long repeated string'  # first
+alpha: "a long repeated string"
+list: [1, 2]
+block: |
+  text
+`
+	out, err := yamlmin.MinifyBytes([]byte(input), yamlmin.DefaultOptions())
+	require.NoError(t, err)
+	assert.Equal(t, `# config
+zeta: &str1 'a long repeated string' # first
+alpha: *str1
+list: [1, 2]
+block: |
+  text
+`, string(out))
+}
+
+func TestVerify(t *testing.T) {
+	// The hash ignores scalar tags, so both mappings share a bucket.
+	input := "a:\n  id: \"12345678901234567890\"\nb:\n  id: 12345678901234567890\n"
+
+	var logs bytes.Buffer
+	opts := yamlmin.DefaultOptions()
+	opts.Verify = true
+	opts.Logger = slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	doc, res, err := yamlmin.NewDecoder(strings.NewReader(input), opts).Decode()
+	require.NoError(t, err)
+	assert.Equal(t, input, string(doc))
+	assert.Equal(t, 1, res.HashCollisions)
+	assert.Contains(t, logs.String(), "hash collision")
+}
+
+func TestSelect(t *testing.T) {
+	input := `kind: ConfigMap
+a: a long repeated string
+b: a long repeated string
+---
+kind: Secret
+a: a long repeated string
+b: a long repeated string
+`
+	opts := yamlmin.DefaultOptions()
+	var err error
+	opts.Select, err = yamlmin.ParseSelector("kind==ConfigMap")
+	require.NoError(t, err)
+
+	dec := yamlmin.NewDecoder(strings.NewReader(input), opts)
+	doc, _, err := dec.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, "kind: ConfigMap\na: &str1 a long repeated string\nb: *str1\n", string(doc))
+	doc, res, err := dec.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, "kind: Secret\na: a long repeated string\nb: a long repeated string\n", string(doc))
+	assert.Equal(t, res.InputBytes, res.OutputBytes)
+
+	for expr, want := range map[string][]bool{
+		"kind!=ConfigMap":            {false, true},
+		".kind==Secret, a!=x":        {false, true},
+		"metadata.name==x":           {false, false},
+		"metadata.name!=x,kind!=Foo": {true, true},
+	} {
+		sel, err := yamlmin.ParseSelector(expr)
+		require.NoError(t, err, expr)
+		docs := strings.Split(input, "---\n")
+		for i, d := range docs {
+			var root yaml.Node
+			require.NoError(t, yaml.Unmarshal([]byte(d), &root))
+			assert.Equal(t, want[i], sel(&root), "%s on document %d", expr, i)
+		}
+	}
+
+	for _, expr := range []string{"kind", "==x", "kind=ConfigMap", "a[==b"} {
+		_, err := yamlmin.ParseSelector(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestPassthrough(t *testing.T) {
+	input := `# leading comment
+kind: ConfigMap
+a:   a long repeated string
+b:   a long repeated string
+---
+kind:    Secret   # untouched
+a: a long repeated string
+b: a long repeated string
+---
+kind: Other
+c:    [1,   2]
+---
+not: [valid
+...
+--- |
+  literal
+`
+	opts := yamlmin.DefaultOptions()
+	opts.Passthrough = true
+	var err error
+	opts.Select, err = yamlmin.ParseSelector("kind!=Secret")
+	require.NoError(t, err)
+
+	var docs []string
+	dec := yamlmin.NewDecoder(strings.NewReader(input), opts)
+	for {
+		doc, _, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		docs = append(docs, string(doc))
+	}
+
+	assert.Equal(t, []string{
+		"# leading comment\nkind: ConfigMap\na: &str1 a long repeated string\nb: *str1\n",
+		"kind:    Secret   # untouched\na: a long repeated string\nb: a long repeated string\n",
+		"kind: Other\nc:    [1,   2]\n",
+		"not: [valid\n",
+		"|\n  literal\n",
+	}, docs)
+}
+
+func TestConcatSafe(t *testing.T) {
+	opts := yamlmin.DefaultOptions()
+	opts.ConcatSafe = true
+
+	a, err := yamlmin.MarshalWithOptions(map[string]string{"a": "a long repeated string", "b": "a long repeated string"}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "---\na: &str1 a long repeated string\nb: *str1\n", string(a))
+	assert.True(t, yamlmin.StartsDocument(a))
+
+	out, err := yamlmin.MinifyString("x: 1\n---\ny: 2\n", `{"ConcatSafe": true}`)
+	require.NoError(t, err)
+	assert.Equal(t, "---\nx: 1\n---\ny: 2\n", out)
+
+	// Concatenated outputs keep their anchors in separate documents.
+	var docs []map[string]string
+	dec := yaml.NewDecoder(strings.NewReader(string(a) + string(a)))
+	for {
+		var doc map[string]string
+		if err := dec.Decode(&doc); errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		docs = append(docs, doc)
+	}
+	assert.Len(t, docs, 2)
+
+	assert.False(t, yamlmin.StartsDocument([]byte("a: ---\n")))
+	assert.True(t, yamlmin.StartsDocument([]byte("--- |\n  text\n")))
+}
+
+func TestUniqueAnchors(t *testing.T) {
+	input := `a: &x {k: 1}
+b: *x
+c: a long repeated string
+d: a long repeated string
+---
+c: a long repeated string
+d: a long repeated string
+`
+	out, err := yamlmin.MinifyString(input, `{"UniqueAnchors": true}`)
+	require.NoError(t, err)
+	assert.Equal(t, `a: &doc1_x {k: 1}
+b: *doc1_x
+c: &doc1_str1 a long repeated string
+d: *doc1_str1
+---
+c: &doc2_str1 a long repeated string
+d: *doc2_str1
+`, out)
+}
+
+func TestMaxOutputBytes(t *testing.T) {
+	input := `name: demo
+shared:
+  a: &s a long repeated string
+  b: *s
+logs:
+  - first line of a very long log entry that will not fit
+  - second line of a very long log entry that will not fit
+env:
+  region: us-east-1
+`
+	opts := yamlmin.DefaultOptions()
+	opts.MaxOutputBytes = 140
+	dec := yamlmin.NewDecoder(strings.NewReader(input), opts)
+	out, res, err := dec.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, `name: demo
+shared:
+  a: &s a long repeated string
+  b: *s
+logs: <truncated>
+env:
+  region: us-east-1
+# yamlmin:truncated .logs 109
+`, string(out))
+	assert.Equal(t, []string{".logs"}, res.Truncated)
+	assert.LessOrEqual(t, len(out), opts.MaxOutputBytes)
+
+	opts.MaxOutputBytes = 10
+	_, err = yamlmin.MinifyBytes([]byte(input), opts)
+	assert.ErrorIs(t, err, yamlmin.ErrTooLarge)
+
+	opts.MaxOutputBytes = 1000
+	out, err = yamlmin.MinifyBytes([]byte(input), opts)
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), yamlmin.TruncatedPrefix)
+}
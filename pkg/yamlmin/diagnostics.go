@@ -0,0 +1,135 @@
+package yamlmin
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Warning reasons, naming the Options limit that was reached.
+const (
+	WarningMaxDepth  = "MaxDepth"
+	WarningMaxWidth  = "MaxWidth"
+	WarningTimeLimit = "TimeLimit"
+)
+
+// Warning reports part of the input that a limit kept out of
+// deduplication. Its duplicates were neither anchored nor aliased.
+type Warning struct {
+	// Path is the Query path of the skipped node; "." is the root. For
+	// MaxWidth the node is the collection whose later entries were skipped,
+	// and for TimeLimit it is where scanning stopped, or the root if the
+	// limit ran out in a later pass.
+	Path string
+
+	// Reason is WarningMaxDepth, WarningMaxWidth, or WarningTimeLimit.
+	Reason string
+
+	// Limit is the value of the limit, such as "50" or "1s".
+	Limit string
+}
+
+// Diagnostics describes the output of MarshalWithDiagnostics.
+type Diagnostics struct {
+	Result
+
+	// Warnings lists the skipped parts of the input in the order they were
+	// found. Deduplication was complete only if it is empty.
+	Warnings []Warning
+}
+
+// MarshalWithDiagnostics is MarshalWithStats reporting, instead of anchor
+// savings, which parts of the input MaxDepth, MaxWidth, or TimeLimit kept
+// out of deduplication.
+func MarshalWithDiagnostics(in interface{}, opts Options) ([]byte, Diagnostics, error) {
+	root, err := encodeValue(in)
+	if err != nil {
+		return nil, Diagnostics{}, err
+	}
+	before, err := encodeNode(root, opts)
+	if err != nil {
+		return nil, Diagnostics{}, err
+	}
+	// Paths are taken before aliases replace any subtree.
+	paths := make(map[*yaml.Node]string)
+	collectPaths(root, nil, paths)
+
+	df := newDuplicateFinder(opts)
+	res, err := df.process(root, opts)
+	if err != nil {
+		return nil, Diagnostics{}, err
+	}
+	out, truncated, err := encodeOutput(root, opts)
+	if err != nil {
+		return nil, Diagnostics{}, err
+	}
+
+	res.InputBytes, res.OutputBytes = len(before), len(out)
+	res.Anchors, res.Aliases = countRefs(root)
+	res.Truncated = truncated
+	return out, Diagnostics{Result: res, Warnings: df.warnings(paths)}, nil
+}
+
+// ErrLimitReached is wrapped by the *LimitError that Options.Strict returns.
+var ErrLimitReached = errors.New("deduplication limit reached")
+
+// LimitError is returned under Options.Strict when MaxDepth, MaxWidth, or
+// TimeLimit kept part of a document out of deduplication.
+type LimitError struct {
+	Warnings []Warning
+}
+
+func (e *LimitError) Error() string {
+	w := e.Warnings[0]
+	msg := fmt.Sprintf("%s: %s %s at %s", ErrLimitReached, w.Reason, w.Limit, w.Path)
+	if n := len(e.Warnings) - 1; n > 0 {
+		msg += fmt.Sprintf(" (and %d more)", n)
+	}
+	return msg
+}
+
+func (e *LimitError) Unwrap() error { return ErrLimitReached }
+
+// limitError returns a *LimitError for the skipped subtrees under root, or
+// nil if there are none.
+func (df *duplicateFinder) limitError(root *yaml.Node) error {
+	if len(df.skipped) == 0 {
+		return nil
+	}
+	paths := make(map[*yaml.Node]string)
+	collectPaths(root, nil, paths)
+	return &LimitError{Warnings: df.warnings(paths)}
+}
+
+// warnings describes df.skipped using paths, falling back to the root for
+// nodes it lacks.
+func (df *duplicateFinder) warnings(paths map[*yaml.Node]string) []Warning {
+	var out []Warning
+	for _, s := range df.skipped {
+		path, ok := paths[s.node]
+		if !ok {
+			path = "."
+		}
+		out = append(out, Warning{Path: path, Reason: s.reason, Limit: s.limit})
+	}
+	return out
+}
+
+// skippedNode is a subtree a limit kept from being scanned.
+type skippedNode struct {
+	node          *yaml.Node
+	reason, limit string
+}
+
+func (df *duplicateFinder) skip(node *yaml.Node, reason, limit string) {
+	df.skipped = append(df.skipped, skippedNode{node, reason, limit})
+}
+
+// skipTimeLimit records that the time limit ran out at node, once.
+func (df *duplicateFinder) skipTimeLimit(node *yaml.Node) {
+	if !df.timedOut {
+		df.timedOut = true
+		df.skip(node, WarningTimeLimit, df.timeLimit.String())
+	}
+}
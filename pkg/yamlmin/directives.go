@@ -0,0 +1,126 @@
+package yamlmin
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// directivePrefix starts a comment that tunes deduplication of the node it
+// is attached to (see MinifyBytes). Written above or beside a mapping key, a
+// directive applies to the key's value.
+const directivePrefix = "yamlmin:"
+
+// directives are the settings comments give a node. ignore and minSize hold
+// for the node's whole subtree; anchorName only for the node itself.
+type directives struct {
+	ignore     bool
+	minSize    int // 0 keeps Options.MinSize
+	anchorName string
+
+	// holdsIgnored marks an ancestor of an ignored node. It is not anchored
+	// either, as aliasing it would replace the ignored node, comment and all.
+	holdsIgnored bool
+}
+
+// markDirectives records the directives in effect for node and everything
+// inside it in df.directed, given those inherited from its ancestors, and
+// reserves the names anchor-name directives ask for, so no generated name
+// can take them first. It reports whether node or anything inside it is
+// ignored.
+func (df *duplicateFinder) markDirectives(node *yaml.Node, inherited directives) (bool, error) {
+	d := inherited
+	if err := parseDirectives(node, &d); err != nil {
+		return false, err
+	}
+	if d != (directives{}) {
+		df.directed[node] = d
+	}
+	if d.anchorName != "" && !df.taken[d.anchorName] {
+		df.taken[d.anchorName] = true
+		df.reserved[d.anchorName] = true
+	}
+	d.anchorName = ""
+	holdsIgnored := false
+	for i, child := range node.Content {
+		if child.Kind == yaml.AliasNode {
+			continue
+		}
+		childD := d
+		if node.Kind == yaml.MappingNode && i%2 == 1 {
+			if err := parseDirectives(node.Content[i-1], &childD); err != nil {
+				return false, err
+			}
+		}
+		ignored, err := df.markDirectives(child, childD)
+		if err != nil {
+			return false, err
+		}
+		holdsIgnored = holdsIgnored || ignored
+	}
+	if holdsIgnored && !d.ignore {
+		held := df.directed[node]
+		held.holdsIgnored = true
+		df.directed[node] = held
+	}
+	return d.ignore || holdsIgnored, nil
+}
+
+// validAnchorName matches the anchor names yaml.v3 can write.
+var validAnchorName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ValidAnchorName reports whether name can name an anchor: it is made of
+// letters, digits, '_', and '-', as yaml.v3 writes nothing else.
+func ValidAnchorName(name string) bool {
+	return validAnchorName.MatchString(name)
+}
+
+// parseDirectives applies the directives in node's head and line comments
+// to d.
+func parseDirectives(node *yaml.Node, d *directives) error {
+	for _, comment := range []string{node.HeadComment, node.LineComment} {
+		for _, line := range strings.Split(comment, "\n") {
+			text := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "#"))
+			rest, ok := strings.CutPrefix(text, directivePrefix)
+			if !ok {
+				continue
+			}
+			directive, _, _ := strings.Cut(rest, " ")
+			name, value, hasValue := strings.Cut(directive, "=")
+			switch {
+			case name == "ignore" && !hasValue:
+				d.ignore = true
+			case name == "min-size" && hasValue:
+				n, err := strconv.Atoi(value)
+				if err != nil || n <= 0 {
+					return fmt.Errorf("line %d: yamlmin:min-size must be a positive integer, got %q", node.Line, value)
+				}
+				d.minSize = n
+			case name == "anchor-name" && hasValue:
+				if !validAnchorName.MatchString(value) {
+					return fmt.Errorf("line %d: invalid yamlmin:anchor-name %q: use only letters, digits, '_', and '-'", node.Line, value)
+				}
+				d.anchorName = value
+			}
+			// Anything else is prose that happens to start with the prefix.
+		}
+	}
+	return nil
+}
+
+// directedName returns the name an anchor-name directive gives the group
+// with hash, from the first occurrence that has one, or "" if none does or
+// the input or another group already has the name.
+func (df *duplicateFinder) directedName(hash uint64) string {
+	nodes, _ := df.nodesByHash.get(hash)
+	for _, n := range nodes {
+		if name := df.directed[n].anchorName; name != "" && df.reserved[name] {
+			delete(df.reserved, name)
+			return name
+		}
+	}
+	return ""
+}
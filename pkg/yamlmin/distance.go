@@ -0,0 +1,90 @@
+package yamlmin
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// limitAliasDistance replaces aliases emitted more than maxLines lines after
+// their anchor with a copy of the anchored content. Expanding an alias pushes
+// later lines down, so it repeats until every remaining alias is in range.
+func limitAliasDistance(root *yaml.Node, opts Options, maxLines int) error {
+	for {
+		out, err := encodeNode(root, opts)
+		if err != nil {
+			return err
+		}
+		var emitted yaml.Node
+		if err := yaml.Unmarshal(out, &emitted); err != nil {
+			return fmt.Errorf("re-reading output: %w", err)
+		}
+
+		anchorLines := make(map[*yaml.Node]int)
+		var far []*yaml.Node
+		var walk func(n, e *yaml.Node)
+		walk = func(n, e *yaml.Node) {
+			if n == nil || e == nil {
+				return
+			}
+			if n.Anchor != "" {
+				anchorLines[n] = e.Line
+			}
+			if n.Kind == yaml.AliasNode {
+				if line, ok := anchorLines[n.Alias]; ok && e.Line-line > maxLines {
+					far = append(far, n)
+				}
+				return
+			}
+			for i := range n.Content {
+				if i < len(e.Content) {
+					walk(n.Content[i], e.Content[i])
+				}
+			}
+		}
+		walk(unwrapDocument(root), unwrapDocument(&emitted))
+
+		if len(far) == 0 {
+			pruneAnchors(root)
+			return nil
+		}
+		expand := make(map[*yaml.Node]bool, len(far))
+		for _, n := range far {
+			expand[n] = true
+		}
+		expandAliases(root, expand)
+	}
+}
+
+func unwrapDocument(n *yaml.Node) *yaml.Node {
+	if n.Kind == yaml.DocumentNode && len(n.Content) == 1 {
+		return n.Content[0]
+	}
+	return n
+}
+
+// expandAliases replaces the listed alias nodes with copies of their targets.
+func expandAliases(node *yaml.Node, aliases map[*yaml.Node]bool) {
+	for i, child := range node.Content {
+		if aliases[child] {
+			node.Content[i] = copyWithoutAnchors(child.Alias)
+			continue
+		}
+		expandAliases(child, aliases)
+	}
+}
+
+// copyWithoutAnchors deep-copies node, dropping anchors from the copy. Aliases
+// inside are copied as aliases to the same anchors.
+func copyWithoutAnchors(node *yaml.Node) *yaml.Node {
+	c := *node
+	c.Anchor = ""
+	if node.Kind == yaml.AliasNode {
+		return &c
+	}
+	c.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		c.Content[i] = copyWithoutAnchors(child)
+	}
+	return &c
+}
@@ -0,0 +1,125 @@
+package yamlmin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Decisions recorded for each bucket of the duplicate index.
+const (
+	DecisionAnchored      = "anchored"
+	DecisionTooFew        = "below min occurrences"
+	DecisionNoScore       = "score not positive"
+	DecisionAliased       = "too few occurrences outside aliased structures"
+	DecisionEnclosing     = "encloses an anchored structure"
+	DecisionSectionBudget = "section anchor limit reached"
+	DecisionRejected      = "rejected in review"
+	DecisionNested        = "too few occurrences outside anchored structures"
+	DecisionAliasRatio    = "alias ratio limit reached"
+)
+
+// IndexBucket describes one hash bucket of the duplicate index and what the
+// selection pass decided to do with it.
+type IndexBucket struct {
+	DuplicateGroup
+	Score    float64
+	Decision string
+	Nodes    []*yaml.Node
+}
+
+// decide records a bucket when the index is being dumped.
+func (df *duplicateFinder) decide(group DuplicateGroup, nodes []*yaml.Node, score float64, decision string) {
+	if df.index == nil {
+		return
+	}
+	df.index[group.Hash] = &IndexBucket{DuplicateGroup: group, Score: score, Decision: decision, Nodes: nodes}
+}
+
+// redecide updates the decision for a bucket recorded by decide.
+func (df *duplicateFinder) redecide(hash uint64, decision string) {
+	if b, ok := df.index[hash]; ok {
+		b.Decision = decision
+	}
+}
+
+// rescore updates the score for a bucket recorded by decide.
+func (df *duplicateFinder) rescore(hash uint64, score float64) {
+	if b, ok := df.index[hash]; ok {
+		b.Score = score
+	}
+}
+
+// Index builds the duplicate index for a single document and returns its
+// buckets in first-occurrence order. root is not modified unless opts.SetKeys
+// reorders sequences. RefMode is ignored; the index covers anchor selection
+// only.
+func Index(root *yaml.Node, opts Options) []IndexBucket {
+	df := newDuplicateFinder(opts)
+	df.index = make(map[uint64]*IndexBucket)
+	if len(opts.SetKeys) > 0 {
+		df.canonicalizeSets(root, setOf(opts.SetKeys))
+	}
+	df.scanNode(root, 0)
+	df.indexCandidates()
+	df.markDuplicates()
+
+	buckets := make([]IndexBucket, 0, len(df.hashOrder))
+	for _, hash := range df.hashOrder {
+		if b, ok := df.index[hash]; ok {
+			buckets = append(buckets, *b)
+		}
+	}
+	return buckets
+}
+
+// DumpIndex writes a human-readable dump of the duplicate index for every
+// document in data: each bucket's kind, size, score and decision, followed by
+// the positions of its nodes. It answers "why wasn't this deduplicated?".
+func DumpIndex(w io.Writer, data []byte, opts Options) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for n := 1; ; n++ {
+		var root yaml.Node
+		if err := dec.Decode(&root); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "document %d\n", n); err != nil {
+			return err
+		}
+		for _, b := range Index(&root, opts) {
+			if _, err := fmt.Fprintf(w, "  %016x %s occurrences=%d size=%d score=%g: %s\n",
+				b.Hash, KindName(b.Kind), b.Occurrences, b.Size, b.Score, b.Decision); err != nil {
+				return err
+			}
+			for _, node := range b.Nodes {
+				if _, err := fmt.Fprintf(w, "    %d:%d\n", node.Line, node.Column); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// KindName returns a short name for a node kind, like "mapping".
+func KindName(kind yaml.Kind) string {
+	switch kind {
+	case yaml.MappingNode:
+		return "mapping"
+	case yaml.SequenceNode:
+		return "sequence"
+	case yaml.ScalarNode:
+		return "scalar"
+	case yaml.DocumentNode:
+		return "document"
+	case yaml.AliasNode:
+		return "alias"
+	default:
+		return "node"
+	}
+}
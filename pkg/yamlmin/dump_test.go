@@ -0,0 +1,118 @@
+package yamlmin_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestIndex(t *testing.T) {
+	input := `a:
+  x: a long repeated string
+b:
+  x: a long repeated string
+d: only once here ok ok
+`
+	var root yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(input), &root))
+
+	type key struct {
+		kind        yaml.Kind
+		occurrences int
+	}
+	decisions := make(map[key]string)
+	for _, b := range yamlmin.Index(&root, yamlmin.DefaultOptions()) {
+		decisions[key{b.Kind, b.Occurrences}] = b.Decision
+	}
+	assert.Equal(t, map[key]string{
+		{yaml.MappingNode, 1}: yamlmin.DecisionTooFew,   // the document
+		{yaml.MappingNode, 2}: yamlmin.DecisionAnchored, // {x: ...}
+		{yaml.ScalarNode, 2}:  yamlmin.DecisionAliased,  // only inside the anchored mappings
+		{yaml.ScalarNode, 1}:  yamlmin.DecisionTooFew,
+	}, decisions)
+
+	var out strings.Builder
+	require.NoError(t, yamlmin.DumpIndex(&out, []byte(input), yamlmin.DefaultOptions()))
+	assert.Contains(t, out.String(), "mapping occurrences=2 size=23 score=23: anchored\n    2:3\n    4:3\n")
+}
+
+func TestAnalyze(t *testing.T) {
+	shared := map[string]string{"image": "nginx:1.25", "pull": "IfNotPresent"}
+	data := map[string]interface{}{
+		"jobs": []interface{}{shared, shared},
+		"main": shared,
+	}
+
+	report, err := yamlmin.Analyze(data, yamlmin.DefaultOptions())
+	require.NoError(t, err)
+	require.Len(t, report.Candidates, 1)
+	c := report.Candidates[0]
+	assert.Equal(t, []string{".jobs[0]", ".jobs[1]", ".main"}, c.Paths)
+	assert.Equal(t, 3, c.Occurrences)
+	assert.True(t, c.Selected)
+	assert.Equal(t, 2*(31-5)-6, c.EstimatedSavings)
+	assert.Equal(t, c.EstimatedSavings, report.EstimatedSavings)
+	assert.Equal(t, []string{"jobs", "jobs", "main"}, c.Sections)
+	require.Len(t, report.Sections, 2)
+	assert.Equal(t, "jobs", report.Sections[0].Key)
+	assert.Equal(t, c.EstimatedSavings*2/3, report.Sections[0].EstimatedSavings)
+	assert.Equal(t, "main", report.Sections[1].Key)
+	assert.Equal(t, c.EstimatedSavings/3, report.Sections[1].EstimatedSavings)
+	assert.Equal(t, []yamlmin.Candidate{c}, report.Sections[1].Candidates)
+
+	report, err = yamlmin.Analyze(data, yamlmin.NewOptions(yamlmin.WithMinOccurrences(4)))
+	require.NoError(t, err)
+	require.Len(t, report.Candidates, 1)
+	assert.False(t, report.Candidates[0].Selected)
+	assert.Equal(t, yamlmin.DecisionTooFew, report.Candidates[0].Decision)
+	assert.Zero(t, report.EstimatedSavings)
+}
+
+func TestFingerprint(t *testing.T) {
+	a, err := yamlmin.FingerprintBytes([]byte("image: nginx # web\nport: 80\n"))
+	require.NoError(t, err)
+	b, err := yamlmin.FingerprintBytes([]byte("{port: 80, image: \"nginx\"}"))
+	require.NoError(t, err)
+	assert.Equal(t, a, b, "key order, styles, and comments are ignored")
+
+	c, err := yamlmin.FingerprintBytes([]byte("image: nginx\nport: 8080\n"))
+	require.NoError(t, err)
+	assert.NotEqual(t, a, c)
+
+	var doc yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte("x: &w {image: nginx, port: 80}\ny: *w\nz: {<<: *w}\n"), &doc))
+	mapping := doc.Content[0]
+	for _, node := range []*yaml.Node{mapping.Content[1], mapping.Content[3], mapping.Content[5]} {
+		h, err := yamlmin.Fingerprint(node)
+		require.NoError(t, err)
+		assert.Equal(t, a, h, "aliases and bare merges hash as their target")
+	}
+	h, err := yamlmin.StructuralHash(mapping.Content[1])
+	require.NoError(t, err)
+	assert.Equal(t, a, h)
+
+	// Changing any of these requires a new FingerprintVersion.
+	require.Equal(t, 1, yamlmin.FingerprintVersion)
+	for input, want := range map[string]uint64{
+		"image: nginx\nport: 80\n": 0xa580a3aa5cab765d,
+		"[a, 1, true]":             0x856fae93580692c3,
+		"hello":                    0x077fc7d6ec6bd5cd,
+		"!Ref x":                   0x3c2a33e969123f2b,
+		"{}":                       0xaf63b94c8601b113,
+	} {
+		got, err := yamlmin.FingerprintBytes([]byte(input))
+		require.NoError(t, err)
+		assert.Equal(t, want, got, "%q: %016x", input, got)
+	}
+
+	_, err = yamlmin.FingerprintBytes([]byte("a: 1\n---\nb: 2\n"))
+	assert.Error(t, err)
+	_, err = yamlmin.FingerprintBytes(nil)
+	assert.Error(t, err)
+	_, err = yamlmin.Fingerprint(nil)
+	assert.Error(t, err)
+}
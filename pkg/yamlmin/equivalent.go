@@ -0,0 +1,174 @@
+package yamlmin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Diff locates the first difference Equivalent found between two streams.
+type Diff struct {
+	// Document is the index of the document in the stream.
+	Document int
+	// Path is the Query path of the differing node; "." is the root.
+	Path string
+	// A and B describe the node at Path on each side, or "missing".
+	A, B string
+}
+
+func (d Diff) String() string {
+	return fmt.Sprintf("document %d %s: %s != %s", d.Document, d.Path, d.A, d.B)
+}
+
+// Equivalent reports whether the YAML streams a and b hold the same data:
+// the same documents, with aliases and "<<" merge keys resolved, mapping key
+// order ignored, and scalars compared by the values they decode to. It is
+// the check for validating minified output against its input. When they
+// differ, the Diff locates the first difference.
+//
+// Comparing resolves aliases without copying, but the work still grows with
+// the expanded size; it stops with ErrExpansionLimit past Expand's default
+// node limit.
+func Equivalent(a, b []byte) (bool, Diff, error) {
+	docsA, err := decodeAll(a)
+	if err != nil {
+		return false, Diff{}, fmt.Errorf("a: %w", err)
+	}
+	docsB, err := decodeAll(b)
+	if err != nil {
+		return false, Diff{}, fmt.Errorf("b: %w", err)
+	}
+
+	for i := 0; i < max(len(docsA), len(docsB)); i++ {
+		if i >= len(docsA) || i >= len(docsB) {
+			d := Diff{Document: i, Path: ".", A: "missing", B: "missing"}
+			if i < len(docsA) {
+				d.A = describe(docsA[i])
+			} else {
+				d.B = describe(docsB[i])
+			}
+			return false, d, nil
+		}
+		c := comparison{budget: expansionBudget{nodes: maxQueryNodes, bytes: math.MaxInt}}
+		equal, d, err := c.compare(docsA[i], docsB[i], nil)
+		if err != nil {
+			return false, Diff{}, fmt.Errorf("document %d: %w", i, err)
+		}
+		if !equal {
+			d.Document = i
+			return false, d, nil
+		}
+	}
+	return true, Diff{}, nil
+}
+
+// decodeAll parses every document in data, unwrapping document nodes.
+func decodeAll(data []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var root yaml.Node
+		err := dec.Decode(&root)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing YAML: %w", err)
+		}
+		node := &root
+		if node.Kind == yaml.DocumentNode && len(node.Content) == 1 {
+			node = node.Content[0]
+		}
+		docs = append(docs, node)
+	}
+}
+
+// comparison is one document's walk in Equivalent.
+type comparison struct {
+	budget expansionBudget
+}
+
+// compare walks a and b, at path, in step, resolving aliases as it goes.
+func (c *comparison) compare(a, b *yaml.Node, path []pathStep) (bool, Diff, error) {
+	a, b = resolveAlias(a), resolveAlias(b)
+	if err := c.budget.spend(a); err != nil {
+		return false, Diff{}, err
+	}
+	differ := func() (bool, Diff, error) {
+		p := "."
+		if len(path) > 0 {
+			p = formatPath(path)
+		}
+		return false, Diff{Path: p, A: describe(a), B: describe(b)}, nil
+	}
+	if a.Kind != b.Kind {
+		return differ()
+	}
+
+	switch a.Kind {
+	case yaml.ScalarNode:
+		if a.Value == b.Value && a.ShortTag() == b.ShortTag() {
+			return true, Diff{}, nil
+		}
+		var va, vb interface{}
+		if a.Decode(&va) != nil || b.Decode(&vb) != nil || !reflect.DeepEqual(va, vb) {
+			return differ()
+		}
+	case yaml.SequenceNode:
+		if len(a.Content) != len(b.Content) {
+			return differ()
+		}
+		for i := range a.Content {
+			step := append(path[:len(path):len(path)], pathStep{index: i, isIndex: true})
+			if equal, d, err := c.compare(a.Content[i], b.Content[i], step); !equal || err != nil {
+				return equal, d, err
+			}
+		}
+	case yaml.MappingNode:
+		pairsA, pairsB := flattenMerges(a, 0), flattenMerges(b, 0)
+		values := make(map[string]*yaml.Node, len(pairsB)/2)
+		for i := 0; i+1 < len(pairsB); i += 2 {
+			values[keyString(resolveAlias(pairsB[i]))] = pairsB[i+1]
+		}
+		seen := make(map[string]bool, len(pairsA)/2)
+		for i := 0; i+1 < len(pairsA); i += 2 {
+			key := keyString(resolveAlias(pairsA[i]))
+			seen[key] = true
+			step := append(path[:len(path):len(path)], pathStep{key: key})
+			value, ok := values[key]
+			if !ok {
+				p := formatPath(step)
+				return false, Diff{Path: p, A: describe(resolveAlias(pairsA[i+1])), B: "missing"}, nil
+			}
+			if equal, d, err := c.compare(pairsA[i+1], value, step); !equal || err != nil {
+				return equal, d, err
+			}
+		}
+		for i := 0; i+1 < len(pairsB); i += 2 {
+			if key := keyString(resolveAlias(pairsB[i])); !seen[key] {
+				p := formatPath(append(path[:len(path):len(path)], pathStep{key: key}))
+				return false, Diff{Path: p, A: "missing", B: describe(resolveAlias(pairsB[i+1]))}, nil
+			}
+		}
+	}
+	return true, Diff{}, nil
+}
+
+// describe names node briefly for a Diff.
+func describe(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.ShortTag() + " " + strconv.Quote(node.Value)
+	case yaml.MappingNode:
+		return fmt.Sprintf("mapping of %d keys", len(flattenMerges(node, 0))/2)
+	case yaml.SequenceNode:
+		return fmt.Sprintf("sequence of %d items", len(node.Content))
+	}
+	return KindName(node.Kind)
+}
@@ -0,0 +1,52 @@
+package yamlmin_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEquivalent(t *testing.T) {
+	data, err := os.ReadFile("testdata/fixture.yaml")
+	require.NoError(t, err)
+	opts := yamlmin.DefaultOptions()
+	opts.MergeSubsets = true
+	minified, err := yamlmin.MinifyBytes(data, opts)
+	require.NoError(t, err)
+
+	equal, diff, err := yamlmin.Equivalent(data, minified)
+	require.NoError(t, err)
+	assert.True(t, equal, diff.String())
+
+	a := "x: &b {image: nginx, port: 80}\ny:\n  <<: *b\n  port: 0x50\n"
+	equal, _, err = yamlmin.Equivalent([]byte(a), []byte("y: {port: 80, image: nginx}\nx: {image: nginx, port: 80}\n"))
+	require.NoError(t, err)
+	assert.True(t, equal)
+
+	for _, tt := range []struct {
+		b    string
+		want yamlmin.Diff
+	}{
+		{"x: {image: nginx, port: 80}\ny: {image: nginx, port: \"80\"}\n",
+			yamlmin.Diff{Path: ".y.port", A: `!!int "0x50"`, B: `!!str "80"`}},
+		{"x: {image: nginx, port: 80}\ny: {image: nginx}\n",
+			yamlmin.Diff{Path: ".y.port", A: `!!int "0x50"`, B: "missing"}},
+		{"x: {image: nginx, port: 80, tag: v1}\ny: {image: nginx, port: 80}\n",
+			yamlmin.Diff{Path: ".x.tag", A: "missing", B: `!!str "v1"`}},
+		{"x: {image: nginx, port: 80}\ny: [nginx]\n",
+			yamlmin.Diff{Path: ".y", A: "mapping of 2 keys", B: "sequence of 1 items"}},
+		{"x: {image: nginx, port: 80}\ny: {image: nginx, port: 80}\n---\nz: 1\n",
+			yamlmin.Diff{Document: 1, Path: ".", A: "missing", B: "mapping of 1 keys"}},
+	} {
+		equal, diff, err := yamlmin.Equivalent([]byte(a), []byte(tt.b))
+		require.NoError(t, err)
+		assert.False(t, equal)
+		assert.Equal(t, tt.want, diff)
+	}
+
+	_, _, err = yamlmin.Equivalent([]byte("a: [b"), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,184 @@
+package yamlmin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrExpansionLimit is returned when expanding aliases would exceed a limit,
+// as inputs built from nested aliases ("billion laughs") are designed to.
+var ErrExpansionLimit = errors.New("expansion limit exceeded")
+
+// ExpandOptions bounds the output of Expand.
+type ExpandOptions struct {
+	// MaxNodes caps the number of nodes in each expanded document.
+	// Default: 1048576
+	MaxNodes int
+
+	// MaxBytes caps the scalar content of each expanded document, in bytes.
+	// Default: 64 MiB
+	MaxBytes int
+
+	// Indent is the number of spaces to use for indentation in output.
+	// Default: 2
+	Indent int
+}
+
+// Expand undoes minification: it resolves every alias and "<<" merge key in
+// a YAML stream and drops the anchors, returning plain YAML. Expansion stops
+// with ErrExpansionLimit once a document outgrows opts' limits.
+func Expand(data []byte, opts ExpandOptions) ([]byte, error) {
+	var out bytes.Buffer
+	if err := ExpandTo(&out, bytes.NewReader(data), opts); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// ExpandTo is Expand reading the stream from r and writing it to w as it
+// goes. Documents are read one at a time, and one whose top level is a block
+// mapping or sequence is expanded and written an entry at a time, so memory
+// holds the minified document and a single expanded entry rather than the
+// whole expansion. Output written before an error is left in w.
+func ExpandTo(w io.Writer, r io.Reader, opts ExpandOptions) error {
+	if opts.MaxNodes <= 0 {
+		opts.MaxNodes = maxQueryNodes
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 64 << 20
+	}
+
+	dec := yaml.NewDecoder(r)
+	for n := 0; ; n++ {
+		var root yaml.Node
+		err := dec.Decode(&root)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("parsing YAML: %w", err)
+		}
+
+		if n > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		budget := &expansionBudget{nodes: opts.MaxNodes, bytes: opts.MaxBytes}
+		if err := expandDocument(w, &root, budget, Options{Indent: opts.Indent}); err != nil {
+			return fmt.Errorf("document %d: %w", n, err)
+		}
+	}
+}
+
+// expandDocument writes the expansion of doc to w. A block collection at the
+// top level is encoded one entry at a time, each as a collection of its own;
+// anything that would not read back the same that way (flow style, tags,
+// comments) is expanded and encoded whole.
+func expandDocument(w io.Writer, doc *yaml.Node, budget *expansionBudget, opts Options) error {
+	var top *yaml.Node
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) == 1 && !hasComments(doc) {
+		top = resolveAlias(doc.Content[0])
+	}
+	if top == nil || top.Style&yaml.FlowStyle != 0 || hasComments(top) ||
+		(top.Kind != yaml.MappingNode || top.Tag != "!!map") && (top.Kind != yaml.SequenceNode || top.Tag != "!!seq") {
+		expanded, err := expandCopy(doc, budget)
+		if err != nil {
+			return err
+		}
+		return encodeTo(w, expanded, opts)
+	}
+
+	if err := budget.spend(top); err != nil {
+		return err
+	}
+	entries, step := top.Content, 1
+	if top.Kind == yaml.MappingNode {
+		entries, step = flattenMerges(top, 0), 2
+	}
+	for i := 0; i+step <= len(entries); i += step {
+		entry := &yaml.Node{Kind: top.Kind, Tag: top.Tag}
+		for _, child := range entries[i : i+step] {
+			c, err := expandCopy(child, budget)
+			if err != nil {
+				return err
+			}
+			entry.Content = append(entry.Content, c)
+		}
+		if err := encodeTo(w, entry, opts); err != nil {
+			return err
+		}
+	}
+	if len(entries) == 0 {
+		return encodeTo(w, &yaml.Node{Kind: top.Kind, Tag: top.Tag}, opts)
+	}
+	return nil
+}
+
+func hasComments(node *yaml.Node) bool {
+	return node.HeadComment != "" || node.LineComment != "" || node.FootComment != ""
+}
+
+// expansionBudget is what remains of the limits on an expanded copy.
+type expansionBudget struct {
+	nodes, bytes int
+}
+
+func (b *expansionBudget) spend(node *yaml.Node) error {
+	if b.nodes--; b.nodes < 0 {
+		return fmt.Errorf("%w: too many nodes", ErrExpansionLimit)
+	}
+	if b.bytes -= len(node.Value); b.bytes < 0 {
+		return fmt.Errorf("%w: too many bytes", ErrExpansionLimit)
+	}
+	return nil
+}
+
+// ExpansionFactor reports how many times larger, in nodes, the documents in
+// data would be with their aliases expanded: the largest ratio of expanded
+// to parsed nodes over the stream, or 1 for an empty stream. Nothing is
+// expanded to find it, so inputs built to explode are cheap to check before
+// handing them to anything that expands them.
+func ExpansionFactor(data []byte) (float64, error) {
+	factor := 1.0
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var root yaml.Node
+		err := dec.Decode(&root)
+		if errors.Is(err, io.EOF) {
+			return factor, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("parsing YAML: %w", err)
+		}
+		parsed := 0
+		expanded := expandedSize(&root, make(map[*yaml.Node]float64), &parsed)
+		factor = max(factor, expanded/float64(parsed))
+	}
+}
+
+// expandedSize returns the number of nodes under node once aliases are
+// expanded, counting the nodes actually present in parsed. Anchored nodes
+// precede their aliases, so their sizes are known by the time an alias needs
+// them.
+func expandedSize(node *yaml.Node, sizes map[*yaml.Node]float64, parsed *int) float64 {
+	*parsed++
+	if node.Kind == yaml.AliasNode {
+		if size, ok := sizes[node.Alias]; ok {
+			return size
+		}
+		return 1
+	}
+	size := 1.0
+	for _, child := range node.Content {
+		size += expandedSize(child, sizes, parsed)
+	}
+	if node.Anchor != "" {
+		sizes[node] = size
+	}
+	return size
+}
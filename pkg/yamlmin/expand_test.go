@@ -0,0 +1,84 @@
+package yamlmin_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestExpand(t *testing.T) {
+	input := `base: &b
+  image: nginx
+  port: 80
+web:
+  <<: *b
+  port: 8080
+tags: [&t prod, *t]
+---
+x: &s a long repeated string
+y: *s
+`
+	out, err := yamlmin.Expand([]byte(input), yamlmin.ExpandOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, `base:
+  image: nginx
+  port: 80
+web:
+  port: 8080
+  image: nginx
+tags: [prod, prod]
+---
+x: a long repeated string
+y: a long repeated string
+`, string(out))
+
+	laughs := `a: &a ["lol", "lol", "lol", "lol", "lol", "lol", "lol", "lol", "lol"]
+b: &b [*a, *a, *a, *a, *a, *a, *a, *a, *a]
+c: &c [*b, *b, *b, *b, *b, *b, *b, *b, *b]
+d: &d [*c, *c, *c, *c, *c, *c, *c, *c, *c]
+e: &e [*d, *d, *d, *d, *d, *d, *d, *d, *d]
+f: &f [*e, *e, *e, *e, *e, *e, *e, *e, *e]
+g: &g [*f, *f, *f, *f, *f, *f, *f, *f, *f]
+`
+	_, err = yamlmin.Expand([]byte(laughs), yamlmin.ExpandOptions{})
+	assert.ErrorIs(t, err, yamlmin.ErrExpansionLimit)
+
+	factor, err := yamlmin.ExpansionFactor([]byte(laughs))
+	require.NoError(t, err)
+	assert.Greater(t, factor, 1e4)
+	factor, err = yamlmin.ExpansionFactor([]byte(input))
+	require.NoError(t, err)
+	assert.Less(t, factor, 2.0)
+
+	_, err = yamlmin.Expand([]byte(input), yamlmin.ExpandOptions{MaxBytes: 20})
+	assert.ErrorIs(t, err, yamlmin.ErrExpansionLimit)
+}
+
+func TestExpandTo(t *testing.T) {
+	data, err := os.ReadFile("testdata/fixture.yaml")
+	require.NoError(t, err)
+	minified, err := yamlmin.MinifyBytes(data, yamlmin.DefaultOptions())
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, yamlmin.ExpandTo(&out, bytes.NewReader(minified), yamlmin.ExpandOptions{}))
+	var want, got interface{}
+	require.NoError(t, yaml.Unmarshal(data, &want))
+	require.NoError(t, yaml.Unmarshal(out.Bytes(), &got))
+	assert.Equal(t, want, got)
+	assert.NotContains(t, out.String(), "*")
+
+	// Entries are written as they are expanded, so those before the one
+	// over the limit are already out.
+	out.Reset()
+	laughs := "first: fine\nlol: &a [lol, lol, lol]\nmore: [*a, *a, *a, *a]\n"
+	err = yamlmin.ExpandTo(&out, strings.NewReader(laughs), yamlmin.ExpandOptions{MaxNodes: 10})
+	assert.ErrorIs(t, err, yamlmin.ErrExpansionLimit)
+	assert.Equal(t, "first: fine\nlol: [lol, lol, lol]\n", out.String())
+}
@@ -0,0 +1,86 @@
+package yamlmin
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// foldScalars switches single-line strings under node longer than width to
+// folded style, for encodeNode to wrap. Mapping keys, and strings that
+// would not read back the same once wrapped at their spaces, are left as
+// they are.
+func foldScalars(node *yaml.Node, width int) {
+	for i, child := range node.Content {
+		switch {
+		case node.Kind == yaml.MappingNode && i%2 == 0:
+		case child.Kind == yaml.ScalarNode:
+			if foldable(child.Value, width) && child.ShortTag() == "!!str" {
+				child.Style = yaml.FoldedStyle
+			}
+		default:
+			foldScalars(child, width)
+		}
+	}
+}
+
+// foldable reports whether s is a single line longer than width with a
+// space to break it at.
+func foldable(s string, width int) bool {
+	return len(s) > width && !strings.Contains(s, "\n") && s == strings.TrimSpace(s) && breakAfter(s, 0) >= 0
+}
+
+// breakAfter returns the index of the first space in s at or after from
+// that separates two non-blank characters, or -1. Folding reads a line break
+// there back as that space; a break next to other blanks would not.
+func breakAfter(s string, from int) int {
+	for i := max(from, 1); i < len(s)-1; i++ {
+		if s[i] == ' ' && !isBlank(s[i-1]) && !isBlank(s[i+1]) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// foldedValues returns the single-line values of folded scalars under node
+// longer than width: the ones wrapFolded wraps.
+func foldedValues(node *yaml.Node, width int, values map[string]bool) {
+	if node.Kind == yaml.ScalarNode && node.Style&yaml.FoldedStyle != 0 && foldable(node.Value, width) {
+		values[node.Value] = true
+	}
+	for _, child := range node.Content {
+		foldedValues(child, width, values)
+	}
+}
+
+// wrapFolded wraps the content of folded scalars holding one of values,
+// which yaml.v3 writes on a single line after a ">-" header, at spaces into
+// lines of about width characters.
+func wrapFolded(out []byte, values map[string]bool, width int) []byte {
+	lines := strings.Split(string(out), "\n")
+	for i := 1; i < len(lines); i++ {
+		content := strings.TrimLeft(lines[i], " ")
+		indent := lines[i][:len(lines[i])-len(content)]
+		if indent == "" || !values[content] || !strings.HasSuffix(lines[i-1], ">-") {
+			continue
+		}
+		var wrapped []string
+		for {
+			cut := -1
+			for at := breakAfter(content, 0); at >= 0 && (cut < 0 || len(indent)+at <= width); at = breakAfter(content, at+1) {
+				cut = at
+			}
+			if cut < 0 || len(indent)+len(content) <= width {
+				break
+			}
+			wrapped = append(wrapped, indent+content[:cut])
+			content = content[cut+1:]
+		}
+		lines[i] = strings.Join(append(wrapped, indent+content), "\n")
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
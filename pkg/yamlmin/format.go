@@ -0,0 +1,80 @@
+package yamlmin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FormatOptions controls the output of Format.
+type FormatOptions struct {
+	// Indent is the number of spaces to use for indentation in output.
+	// Default: 2
+	Indent int
+}
+
+// Format re-emits anchored YAML in the layout yamlmin writes, so a
+// hand-edited minified file can be normalized without re-deciding what is
+// anchored: every anchor, alias, comment, and scalar is kept as it is.
+// Indentation is made uniform, alias keys are written "*name :" so other
+// parsers read them the same, and flow collections holding anchors are
+// written in block style, so every anchor starts the block it names.
+func Format(data []byte, opts FormatOptions) ([]byte, error) {
+	var out bytes.Buffer
+	if err := FormatTo(&out, bytes.NewReader(data), opts); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// FormatTo is Format reading the stream from r and writing it to w a
+// document at a time. Output written before an error is left in w.
+func FormatTo(w io.Writer, r io.Reader, opts FormatOptions) error {
+	dec := yaml.NewDecoder(r)
+	for n := 0; ; n++ {
+		var root yaml.Node
+		err := dec.Decode(&root)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("parsing YAML: %w", err)
+		}
+
+		if n > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		formatLayout(&root)
+		out, err := encodeNode(&root, Options{Indent: opts.Indent, DedupKeys: true})
+		if err != nil {
+			return fmt.Errorf("document %d: %w", n, err)
+		}
+		if _, err := w.Write(out); err != nil {
+			return err
+		}
+	}
+}
+
+// formatLayout switches flow collections under node that hold an anchor, or
+// are anchored themselves, to block style, and writes "!!merge" keys back as
+// the plain "<<" they came from. It reports whether node holds an anchor.
+func formatLayout(node *yaml.Node) bool {
+	anchored := node.Anchor != ""
+	for i, child := range node.Content {
+		if node.Kind == yaml.MappingNode && i%2 == 0 && child.Tag == "!!merge" && child.Value == "<<" {
+			child.Tag = ""
+		}
+		if formatLayout(child) {
+			anchored = true
+		}
+	}
+	if anchored && (node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode) && len(node.Content) > 0 {
+		node.Style &^= yaml.FlowStyle
+	}
+	return anchored
+}
@@ -0,0 +1,66 @@
+package yamlmin_test
+
+import (
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	input := `a:   &base {image: nginx,   port: 80}
+b:
+      <<: *base
+      port: 8080
+# kept
+c: [x, &s a long string, *s]
+d: {plain: flow}
+e:
+    &k sha256:abc: 1
+f:
+    *k : 2
+---
+x: &l [1, 2]
+y: *l
+`
+	out, err := yamlmin.Format([]byte(input), yamlmin.FormatOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, `a: &base
+  image: nginx
+  port: 80
+b:
+  <<: *base
+  port: 8080
+# kept
+c:
+  - x
+  - &s a long string
+  - *s
+d: {plain: flow}
+e:
+  &k sha256:abc: 1
+f:
+  *k : 2
+---
+x: &l
+  - 1
+  - 2
+y: *l
+`, string(out))
+
+	ok, diff, err := yamlmin.Equivalent([]byte(input), out)
+	require.NoError(t, err)
+	assert.True(t, ok, diff.String())
+
+	again, err := yamlmin.Format(out, yamlmin.FormatOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, string(out), string(again))
+
+	out, err = yamlmin.Format([]byte("a:\n  b: [1]\n"), yamlmin.FormatOptions{Indent: 4})
+	require.NoError(t, err)
+	assert.Equal(t, "a:\n    b: [1]\n", string(out))
+
+	_, err = yamlmin.Format([]byte("a: *missing\n"), yamlmin.FormatOptions{})
+	assert.ErrorContains(t, err, "parsing YAML")
+}
@@ -0,0 +1,74 @@
+package yamlmin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FingerprintVersion identifies the algorithm behind Fingerprint. It is
+// incremented whenever any node's fingerprint changes, so a store keyed by
+// fingerprints can record it and rebuild when it differs. Fingerprints of
+// one version are the same across releases, platforms, and processes.
+const FingerprintVersion = 1
+
+// Fingerprint returns the content hash yamlmin uses with DefaultOptions to
+// find duplicates of node, for content-addressed stores of YAML fragments
+// that agree with yamlmin on what is equal. Subtrees that yamlmin would
+// alias to one another fingerprint the same: mapping key order, scalar and
+// flow styles, and comments are ignored, aliases (and mappings holding only
+// a "<<" merge of one) count as the node they refer to, and local tags such
+// as !Ref count. A document node fingerprints as its content.
+//
+// The fingerprint is 64-bit FNV-1a, so distinct subtrees can collide; yamlmin
+// compares nodes before aliasing them, and stores should too. It is stable
+// for a given FingerprintVersion.
+func Fingerprint(node *yaml.Node) (uint64, error) {
+	if node == nil {
+		return 0, errors.New("nil node")
+	}
+	if node.Kind == yaml.DocumentNode && len(node.Content) == 1 {
+		node = node.Content[0]
+	}
+	df := newDuplicateFinder(DefaultOptions())
+	hash, err := df.hashNode(node, 0)
+	if errors.Is(err, errLimitHit) {
+		return 0, fmt.Errorf("node is deeper than %d levels or wider than %d entries", df.maxDepth, df.maxWidth)
+	}
+	return hash, err
+}
+
+// FingerprintBytes is Fingerprint of the single YAML document in data.
+func FingerprintBytes(data []byte) (uint64, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var root yaml.Node
+	if err := dec.Decode(&root); err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, errors.New("no YAML document")
+		}
+		return 0, fmt.Errorf("parsing YAML: %w", err)
+	}
+	var extra yaml.Node
+	if err := dec.Decode(&extra); !errors.Is(err, io.EOF) {
+		return 0, errors.New("more than one YAML document")
+	}
+	return Fingerprint(&root)
+}
+
+// StructuralHash is Fingerprint.
+//
+// Deprecated: Use Fingerprint, whose stability is tied to
+// FingerprintVersion.
+func StructuralHash(node *yaml.Node) (uint64, error) {
+	return Fingerprint(node)
+}
+
+// StructuralHashBytes is FingerprintBytes.
+//
+// Deprecated: Use FingerprintBytes.
+func StructuralHashBytes(data []byte) (uint64, error) {
+	return FingerprintBytes(data)
+}
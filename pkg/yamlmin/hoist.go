@@ -0,0 +1,55 @@
+package yamlmin
+
+import (
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultHoistKey is the top-level key used when Options.HoistKey is empty.
+const DefaultHoistKey = "x-yamlmin-values"
+
+// hoistScalars moves every anchored scalar into a mapping under key at the top
+// of the root mapping, leaving an alias at the original location. Existing
+// aliases keep pointing at the moved node.
+func hoistScalars(root *yaml.Node, key string) {
+	doc := root
+	if doc.Kind == yaml.DocumentNode {
+		if len(doc.Content) == 0 {
+			return
+		}
+		doc = doc.Content[0]
+	}
+	if doc.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i < len(doc.Content); i += 2 {
+		if doc.Content[i].Value == key {
+			return
+		}
+	}
+
+	values := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		for i, child := range node.Content {
+			if child.Kind == yaml.ScalarNode && child.Anchor != "" {
+				name := "v" + strconv.Itoa(len(values.Content)/2+1)
+				values.Content = append(values.Content,
+					&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name},
+					child,
+				)
+				node.Content[i] = &yaml.Node{Kind: yaml.AliasNode, Value: child.Anchor, Alias: child}
+				continue
+			}
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	if len(values.Content) == 0 {
+		return
+	}
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+	doc.Content = append([]*yaml.Node{keyNode, values}, doc.Content...)
+}
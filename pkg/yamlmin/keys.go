@@ -0,0 +1,44 @@
+package yamlmin
+
+import (
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// aliasKeyPattern matches an alias written as a simple mapping key in block
+// or flow context, e.g. "  *str1: value" or "{*str1: value".
+var aliasKeyPattern = regexp.MustCompile(`(?m)(^[ \t]*(?:- )*|[{,] ?)\*([^\s:,\[\]{}]+):( |$)`)
+
+// aliasKeyNames returns the anchor names referenced by aliases used as
+// mapping keys anywhere under node.
+func aliasKeyNames(node *yaml.Node, names map[string]bool) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i < len(node.Content); i += 2 {
+			if node.Content[i].Kind == yaml.AliasNode {
+				names[node.Content[i].Value] = true
+			}
+		}
+	}
+	for _, child := range node.Content {
+		aliasKeyNames(child, names)
+	}
+}
+
+// spaceAliasKeys inserts a space between alias keys and their ':' indicator.
+// yaml.v3 emits "*str1: value", but ':' is a valid anchor character, so
+// other parsers read that as an alias named "str1:". Only aliases in names
+// are touched, which keeps look-alike text in scalars intact.
+func spaceAliasKeys(out []byte, names map[string]bool) []byte {
+	return aliasKeyPattern.ReplaceAllFunc(out, func(m []byte) []byte {
+		sub := aliasKeyPattern.FindSubmatch(m)
+		if !names[string(sub[2])] {
+			return m
+		}
+		fixed := append([]byte{}, sub[1]...)
+		fixed = append(fixed, '*')
+		fixed = append(fixed, sub[2]...)
+		fixed = append(fixed, " :"...)
+		return append(fixed, sub[3]...)
+	})
+}
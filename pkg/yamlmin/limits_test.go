@@ -116,3 +116,68 @@ func TestLimits(t *testing.T) {
 		})
 	}
 }
+
+func TestMarshalWithDiagnostics(t *testing.T) {
+	shared := map[string]string{"k": "very_very_long_value_to_ensure_dedup"}
+	data := map[string]interface{}{
+		"deep": map[string]interface{}{"x": map[string]interface{}{"y": map[string]interface{}{"z": shared}}},
+		"wide": []interface{}{shared, shared, shared},
+	}
+
+	out, diag, err := yamlmin.MarshalWithDiagnostics(data, yamlmin.DefaultOptions())
+	require.NoError(t, err)
+	assert.Empty(t, diag.Warnings)
+	assert.Equal(t, len(out), diag.OutputBytes)
+
+	opts := yamlmin.DefaultOptions()
+	opts.MaxDepth = 3
+	opts.MaxWidth = 2
+	_, diag, err = yamlmin.MarshalWithDiagnostics(data, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []yamlmin.Warning{
+		{Path: ".deep.x.y.z", Reason: yamlmin.WarningMaxDepth, Limit: "3"},
+		{Path: ".wide", Reason: yamlmin.WarningMaxWidth, Limit: "2"},
+	}, diag.Warnings)
+
+	opts = yamlmin.DefaultOptions()
+	opts.TimeLimit = time.Nanosecond
+	_, diag, err = yamlmin.MarshalWithDiagnostics(data, opts)
+	require.NoError(t, err)
+	require.Len(t, diag.Warnings, 1)
+	assert.Equal(t, yamlmin.WarningTimeLimit, diag.Warnings[0].Reason)
+	assert.Equal(t, "1ns", diag.Warnings[0].Limit)
+
+	_, res, err := yamlmin.NewDecoder(strings.NewReader("a: [x, y]\nb: [x, y]\n"), opts).Decode()
+	require.NoError(t, err)
+	assert.True(t, res.TimedOut)
+	_, res, err = yamlmin.NewDecoder(strings.NewReader("a: [x, y]\nb: [x, y]\n"), yamlmin.DefaultOptions()).Decode()
+	require.NoError(t, err)
+	assert.False(t, res.TimedOut)
+}
+
+func TestStrictLimits(t *testing.T) {
+	shared := map[string]string{"k": "very_very_long_value_to_ensure_dedup"}
+	data := map[string]interface{}{
+		"deep": map[string]interface{}{"x": map[string]interface{}{"y": map[string]interface{}{"z": shared}}},
+		"wide": []interface{}{shared, shared, shared},
+	}
+	opts := yamlmin.DefaultOptions()
+	opts.Strict = true
+	_, err := yamlmin.MarshalWithOptions(data, opts)
+	require.NoError(t, err)
+
+	opts.MaxDepth = 3
+	opts.MaxWidth = 2
+	_, err = yamlmin.MarshalWithOptions(data, opts)
+	require.ErrorIs(t, err, yamlmin.ErrLimitReached)
+	var limitErr *yamlmin.LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Len(t, limitErr.Warnings, 2)
+	assert.EqualError(t, err, "deduplication limit reached: MaxDepth 3 at .deep.x.y.z (and 1 more)")
+
+	opts = yamlmin.DefaultOptions()
+	opts.Strict = true
+	opts.TimeLimit = time.Nanosecond
+	_, err = yamlmin.MinifyBytes([]byte("a: [very_very_long_value_to_ensure_dedup]\n"), opts)
+	assert.ErrorIs(t, err, yamlmin.ErrLimitReached)
+}
@@ -5,9 +5,26 @@
 //
 // Basic usage:
 //
-//	import "github.com/glennpratt/yamlmin"
+//	import "github.com/glennpratt/yamlmin/pkg/yamlmin"
 //
 //	output, err := yamlmin.Marshal(myStruct)
+//
+// # Concurrency
+//
+// The package-level functions (Marshal, MarshalWithOptions, K8sMarshal,
+// K8sMarshalWithOptions, MarshalForTarget, MinifyString, CheckTarget,
+// DumpIndex, Preset and LookupTarget) keep no state between calls and are
+// safe to call from multiple goroutines, including with a shared Options
+// value. Options is only read; a custom Score func or Logger shared that way
+// must itself be safe for concurrent use.
+//
+// Options.Parallel only spreads the work of a single call over goroutines; it
+// does not change this contract, and its output is identical to serial mode.
+//
+// A Decoder reads from a single stream and requires exclusive use, as does a
+// Minifier, which reuses its buffers from call to call. Index and
+// ShareRefs may modify the nodes passed to them, so callers must not share
+// those trees with other goroutines while they run.
 package yamlmin
 
 import (
@@ -15,9 +32,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
 	"hash/fnv"
+	"io"
+	"log/slog"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -30,6 +51,12 @@ type Options struct {
 	// Default: 2
 	MinOccurrences int
 
+	// MinOccurrencesByKind overrides MinOccurrences for specific node kinds
+	// (yaml.MappingNode, yaml.SequenceNode, yaml.ScalarNode). Kinds not present
+	// in the map use MinOccurrences.
+	// Default: nil
+	MinOccurrencesByKind map[yaml.Kind]int
+
 	// MinSize is the minimum structure size (in chars) to consider for deduplication.
 	// Default: 20
 	MinSize int
@@ -38,6 +65,14 @@ type Options struct {
 	// Default: 2
 	Indent int
 
+	// FoldWidth, when positive, writes single-line strings longer than it,
+	// such as long command lines and URLs, in folded style (">-") wrapped at
+	// spaces into lines of about that many characters. Their values are
+	// unchanged. Mapping keys and strings without a space to wrap at stay on
+	// one line.
+	// Default: 0 (no folding)
+	FoldWidth int
+
 	// MaxDepth is the maximum tree depth to traverse during deduplication.
 	// Default: 50
 	MaxDepth int
@@ -49,6 +84,241 @@ type Options struct {
 	// TimeLimit is the maximum duration to wait for deduplication to complete.
 	// Default: 0 (no limit)
 	TimeLimit time.Duration
+
+	// Score ranks duplicate groups for the greedy anchoring pass. Groups are
+	// considered in descending score order; a group loses occurrences nested in
+	// structures that were already aliased, and is skipped if it would enclose an
+	// already selected group. Groups scoring <= 0 are never anchored, or in a
+	// RefMode, hoisted. CostScore builds a Score from a CostModel.
+	// Default: nil (SizeScore, larger structures first)
+	Score func(group DuplicateGroup) float64
+
+	// MaximizeSavings selects anchors for the most total savings rather
+	// than by score alone: groups are scored again as larger anchored
+	// structures absorb their occurrences, and a structure enclosing
+	// anchored ones is still anchored when it saves more than they lose.
+	// Score defaults to SavingsScore.
+	// Default: false
+	MaximizeSavings bool
+
+	// NoSequenceAnchors disables anchoring of sequences, leaving only mappings
+	// and scalars as deduplication candidates.
+	// Default: false
+	NoSequenceAnchors bool
+
+	// NoNestedAnchors never anchors or aliases anything inside an anchored
+	// structure. Its copies are already aliases, so a nested anchor only
+	// pays off through occurrences elsewhere; with this set those are
+	// anchored among themselves or written out, for fewer, flatter anchors.
+	// Default: false
+	NoNestedAnchors bool
+
+	// MultilineScalarsOnly restricts scalar deduplication to strings spanning
+	// more than one line. Mappings and sequences are unaffected.
+	// Default: false
+	MultilineScalarsOnly bool
+
+	// HoistScalars moves anchored scalars into a generated top-level mapping
+	// (see HoistKey) and aliases them from every usage site, collecting large
+	// shared strings in one place. Only applies when the document root is a
+	// mapping that does not already contain HoistKey.
+	// Default: false
+	HoistScalars bool
+
+	// HoistKey is the top-level key used for hoisted scalars.
+	// Default: "x-yamlmin-values"
+	HoistKey string
+
+	// SetKeys lists mapping keys whose sequence values are treated as sets:
+	// they are emitted in a canonical order so lists that differ only by
+	// ordering (labels, finalizers) deduplicate. YAML !!set mappings are
+	// already order-insensitive.
+	// Default: nil
+	SetKeys []string
+
+	// MergeSubsets extracts key/value subsets shared by several mappings (such
+	// as identical resources or env fragments in pod specs) into a base anchor
+	// that each mapping includes with a "<<" merge key. The subset must meet
+	// MinSize and be shared by at least the mapping occurrence threshold.
+	// Extracting a base can leave the rest of those mappings identical, so
+	// detection repeats, up to four passes, until a pass extracts nothing.
+	// Default: false
+	MergeSubsets bool
+
+	// SequenceRuns extracts runs of two or more items shared by several
+	// sequences, such as the first volumeMounts of every container, into a
+	// sub-list anchored in place of the first run and aliased in place of
+	// the others: [a, b, c, x] and [a, b, c, y] become [&run1 [a, b, c], x]
+	// and [*run1, y]. YAML cannot splice a list into another, so this changes
+	// the nesting, and suits only consumers that flatten nested lists (GitLab
+	// CI scripts, Ansible loops). The run must meet MinSize and be shared by
+	// at least the sequence occurrence threshold. Detection repeats with
+	// MergeSubsets. It has no effect with NoSequenceAnchors.
+	// Default: false
+	SequenceRuns bool
+
+	// DedupKeys also considers mapping keys as candidates, so long scalar keys
+	// (image digests, URLs) and complex keys can be anchored and aliased like
+	// values.
+	// Default: false
+	DedupKeys bool
+
+	// YAMLVersion selects YAML 1.1 or 1.2 resolution of plain scalars. When
+	// set, scalars only deduplicate with scalars of the same resolved type,
+	// and plain strings the other version would read as another type (on,
+	// 0755, 0o755) are quoted.
+	// Default: YAMLVersionNone
+	YAMLVersion YAMLVersion
+
+	// Parallel hashes candidate structures on GOMAXPROCS goroutines. Results
+	// are merged in document order, so the output is byte-identical to the
+	// serial mode.
+	// Default: false
+	Parallel bool
+
+	// MaxAliasDistance is the furthest, in emitted lines, an alias may be
+	// from its anchor. Aliases further away are replaced by a copy of the
+	// content, so readers never have to scroll thousands of lines back.
+	// Default: 0 (unlimited)
+	MaxAliasDistance int
+
+	// MaxAliasRatio, when positive, stops anchoring further duplicates once
+	// aliases would make up more than this fraction of the document's nodes,
+	// so output stays followable by people and tools. Duplicates are
+	// considered best first, so the ones kept expanded are those worth the
+	// least. Aliases the input already has count toward the limit.
+	// Default: 0 (unlimited)
+	MaxAliasRatio float64
+
+	// Comments controls whether comments are part of a structure's identity
+	// and what happens to the comments of occurrences replaced by aliases.
+	// Default: CommentsDrop
+	Comments CommentMode
+
+	// AnchorFingerprints adds a "# yamlmin:fingerprint=<hash>" comment above
+	// each duplicate anchor, giving the shared content an identity external
+	// tools can track across renders even when its anchor name changes.
+	// Default: false
+	AnchorFingerprints bool
+
+	// SectionAnchorLimits caps the number of duplicate anchors defined under
+	// each listed top-level key, e.g. {"jobs": 5}. When a section's budget is
+	// spent, its lower-scoring duplicates stay expanded. An anchor belongs to
+	// the section of its first occurrence; aliases elsewhere don't count.
+	// Default: nil (unlimited)
+	SectionAnchorLimits map[string]int
+
+	// ExcludeKeys lists top-level keys whose values are left as they are:
+	// nothing under them is anchored or aliased. Use it for sections that
+	// other tooling reads or rewrites on its own.
+	// Default: nil
+	ExcludeKeys []string
+
+	// ScopeKeys, when set, limits anchoring to the values of mapping keys in
+	// the list, at any depth, such as per-environment or per-service blocks.
+	// Nothing outside them is anchored or aliased.
+	// Default: nil (the whole document)
+	ScopeKeys []string
+
+	// AnchorNaming selects how new anchors are named; see AnchorNamingField
+	// and AnchorNamingContext for names taken from the data and
+	// AnchorNamingHash for names that stay the same across revisions.
+	// Default: AnchorNamingCounter
+	AnchorNaming AnchorNaming
+
+	// AnchorNameKeys are the fields AnchorNamingField and AnchorNamingContext
+	// name anchors after, in order of preference.
+	// Default: nil (name, then id)
+	AnchorNameKeys []string
+
+	// RefMode enables spec-aware deduplication: duplicate fragments are hoisted
+	// into the spec's definitions section and replaced with $ref objects
+	// instead of anchors. The anchor options above are ignored in a RefMode.
+	// Default: RefModeNone
+	RefMode RefMode
+
+	// Select, when set, chooses which documents of a stream read by a Decoder
+	// are minified; the others are re-encoded without deduplication. See
+	// ParseSelector for a ready-made predicate.
+	// Default: nil (every document)
+	Select func(doc *yaml.Node) bool
+
+	// Passthrough makes a Decoder return the original bytes of documents it
+	// leaves alone: those rejected by Select, those processing doesn't change
+	// or shrink, and those that aren't valid YAML (instead of failing). Diffs
+	// then only touch documents that were actually minified. The stream is
+	// read fully before the first document is returned.
+	// Default: false
+	Passthrough bool
+
+	// ConcatSafe makes every marshaled or decoded document start with a "---"
+	// marker and end with a newline, so separately minified outputs can be
+	// concatenated into one stream without running together (and sharing
+	// anchors). Stream writers don't add markers before such documents.
+	// Default: false
+	ConcatSafe bool
+
+	// UniqueAnchors prefixes the anchor names of the nth document read by a
+	// Decoder with "doc<n>_" (counting from 1), so names stay unique across a
+	// stream and tools that naively merge its documents can't cross-wire
+	// aliases. Documents Passthrough returns unchanged keep their names.
+	// Default: false
+	UniqueAnchors bool
+
+	// MaxOutputBytes is a hard cap on the size of each minified document, for
+	// systems with strict payload limits. A document still larger after
+	// deduplication has its largest subtrees replaced by TruncatedValue until
+	// it fits; subtrees holding anchors are kept. Each cut is reported in
+	// Result.Truncated and in a TruncatedPrefix comment at the end of the
+	// document. A document that cannot be cut down fails with ErrTooLarge.
+	// Default: 0 (no cap)
+	MaxOutputBytes int
+
+	// Verify compares nodes structurally before aliasing them, instead of
+	// trusting the 64-bit hash alone. Buckets holding distinct structures are
+	// counted in Result.HashCollisions and logged to Logger.
+	// Default: false
+	Verify bool
+
+	// Strict rejects trees holding node kinds or constructs the minifier
+	// cannot safely handle, such as unknown kinds or aliases without a valid
+	// target, with an *UnsupportedNodeError instead of skipping them. It also
+	// fails with a *LimitError, instead of returning partly deduplicated
+	// output, when MaxDepth, MaxWidth, or TimeLimit is reached.
+	// Default: false
+	Strict bool
+
+	// Decisions are reviewed decisions about duplicate groups, by hash, that
+	// reject groups or name their anchors. Default: nil
+	Decisions Decisions
+
+	// Logger receives debug logging. Default: nil (no logging)
+	Logger *slog.Logger
+}
+
+// DuplicateGroup describes a set of structurally identical nodes that are
+// candidates for sharing a single anchor.
+type DuplicateGroup struct {
+	// Kind is the node kind shared by every occurrence.
+	Kind yaml.Kind
+
+	// Hash is the structural hash shared by every occurrence.
+	Hash uint64
+
+	// Occurrences is the number of times the structure appears.
+	Occurrences int
+
+	// Size is the estimated size (in chars) of a single occurrence.
+	Size int
+
+	// Nodes is the number of nodes, mapping keys included, in a single
+	// occurrence.
+	Nodes int
+}
+
+// SizeScore is the default Score, preferring fewer and larger anchors.
+func SizeScore(group DuplicateGroup) float64 {
+	return float64(group.Size)
 }
 
 // DefaultOptions returns options with default values.
@@ -71,12 +341,135 @@ func Marshal(in interface{}) ([]byte, error) {
 
 // MarshalWithOptions accepts a custom configuration and returns minified YAML.
 func MarshalWithOptions(in interface{}, opts Options) ([]byte, error) {
-	var root yaml.Node
-	if err := root.Encode(in); err != nil {
-		return nil, fmt.Errorf("encoding to YAML nodes: %w", err)
+	root, err := encodeValue(in)
+	if err != nil {
+		return nil, err
 	}
 
-	return marshalNode(&root, opts)
+	return marshalNode(root, opts)
+}
+
+// MarshalTo is MarshalWithOptions writing to w as the output is encoded,
+// rather than building it in memory first. Options that rewrite the encoded
+// text (DedupKeys, FoldWidth, MaxOutputBytes, and ConcatSafe) need all of
+// it, so with any of them set the output is buffered before it is written.
+func MarshalTo(w io.Writer, in interface{}, opts Options) error {
+	root, err := encodeValue(in)
+	if err != nil {
+		return err
+	}
+	if _, err := process(root, opts); err != nil {
+		return err
+	}
+	if !opts.DedupKeys && opts.FoldWidth <= 0 && opts.MaxOutputBytes <= 0 && !opts.ConcatSafe {
+		return encodeTo(w, root, opts)
+	}
+
+	out, _, err := encodeOutput(root, opts)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// MarshalAppend is MarshalWithOptions appending the output to dst and
+// returning the extended slice, so callers can reuse one buffer across calls:
+//
+//	buf, err = yamlmin.MarshalAppend(buf[:0], v, opts)
+//
+// On error dst is returned unchanged.
+func MarshalAppend(dst []byte, in interface{}, opts Options) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	if err := MarshalTo(buf, in, opts); err != nil {
+		return dst, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Stats describes the output of MarshalWithStats.
+type Stats struct {
+	Result
+
+	// AnchorSavings estimates the bytes each anchor saves, by anchor name:
+	// the size of its content for every alias to it, less the anchor and
+	// alias markers. Sizes count scalar text only, as Options.MinSize does.
+	AnchorSavings map[string]int
+}
+
+// MarshalWithStats is MarshalWithOptions that also reports statistics about
+// the output, so callers need not count anchors and aliases in it.
+func MarshalWithStats(in interface{}, opts Options) ([]byte, Stats, error) {
+	root, err := encodeValue(in)
+	if err != nil {
+		return nil, Stats{}, err
+	}
+	before, err := encodeNode(root, opts)
+	if err != nil {
+		return nil, Stats{}, err
+	}
+
+	df := newDuplicateFinder(opts)
+	res, err := df.process(root, opts)
+	if err != nil {
+		return nil, Stats{}, err
+	}
+	out, truncated, err := encodeOutput(root, opts)
+	if err != nil {
+		return nil, Stats{}, err
+	}
+
+	res.InputBytes, res.OutputBytes = len(before), len(out)
+	res.Anchors, res.Aliases = countRefs(root)
+	res.Truncated = truncated
+	return out, Stats{Result: res, AnchorSavings: df.anchorSavings(root)}, nil
+}
+
+// MarshalAll marshals each of docs as its own document of a YAML stream,
+// deduplicating within each document, using default options.
+func MarshalAll(docs []interface{}) ([]byte, error) {
+	return MarshalAllWithOptions(docs, DefaultOptions())
+}
+
+// MarshalAllWithOptions is MarshalAll with a custom configuration. Anchors
+// never span documents; see Options.UniqueAnchors to also keep their names
+// distinct. To minify an existing stream, use MinifyBytes or Minify.
+func MarshalAllWithOptions(docs []interface{}, opts Options) ([]byte, error) {
+	var out bytes.Buffer
+	for i, in := range docs {
+		root, err := encodeValue(in)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		if _, err := process(root, opts); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		if opts.UniqueAnchors {
+			prefixAnchors(root, "doc"+strconv.Itoa(i+1)+"_")
+		}
+		doc, err := encodeNode(root, opts)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		if opts.ConcatSafe {
+			doc = concatSafe(doc)
+		}
+		if i > 0 && !StartsDocument(doc) {
+			out.WriteString("---\n")
+		}
+		out.Write(doc)
+	}
+	return out.Bytes(), nil
+}
+
+// ProcessNode deduplicates root in place, for callers that parse and encode
+// yaml.Node trees themselves. root may be a document node or any node within
+// one. Options that concern reading or writing streams (Select, Passthrough,
+// ConcatSafe, UniqueAnchors, Indent) have no effect. With DedupKeys, note that
+// yaml.v3 writes alias keys as "*a:", which parsers read as the alias "a:".
+func ProcessNode(root *yaml.Node, opts Options) error {
+	_, err := process(root, opts)
+	return err
 }
 
 // K8sMarshal first uses JSON tags to marshal, then deduplicates.
@@ -86,58 +479,221 @@ func K8sMarshal(in interface{}) ([]byte, error) {
 
 // K8sMarshalWithOptions accepts custom options and uses JSON tags to marshal.
 func K8sMarshalWithOptions(in interface{}, opts Options) ([]byte, error) {
+	root, err := jsonTagNode(in)
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalNode(root, opts)
+}
+
+// encodeValue converts a Go value to a node tree using YAML tags.
+func encodeValue(in interface{}) (*yaml.Node, error) {
 	var root yaml.Node
+	if err := root.Encode(in); err != nil {
+		return nil, fmt.Errorf("encoding to YAML nodes: %w", err)
+	}
+	return &root, nil
+}
+
+// jsonTagNode converts a Go value to a node tree using JSON tags.
+func jsonTagNode(in interface{}) (*yaml.Node, error) {
 	y, err := json.Marshal(in)
 	if err != nil {
 		return nil, fmt.Errorf("k8s marshaling: %w", err)
 	}
-	if err := yaml.Unmarshal(y, &root); err != nil {
-		return nil, fmt.Errorf("parsing k8s YAML: %w", err)
+	root, err := jsonToNode(y)
+	if err != nil {
+		return nil, fmt.Errorf("parsing k8s JSON: %w", err)
 	}
-
-	return marshalNode(&root, opts)
+	return root, nil
 }
 
 func marshalNode(root *yaml.Node, opts Options) ([]byte, error) {
-	process(root, opts)
+	if _, err := process(root, opts); err != nil {
+		return nil, err
+	}
+	out, _, err := encodeOutput(root, opts)
+	return out, err
+}
+
+// encodeOutput encodes a processed document, applying Options.MaxOutputBytes
+// and Options.ConcatSafe. It also returns the paths of any truncated subtrees.
+func encodeOutput(root *yaml.Node, opts Options) ([]byte, []string, error) {
+	out, err := encodeNode(root, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, truncated, err := fitOutput(root, opts, out)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.ConcatSafe {
+		out = concatSafe(out)
+	}
+	return out, truncated, nil
+}
 
+func encodeNode(root *yaml.Node, opts Options) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeTo(&buf, root, opts); err != nil {
+		return nil, err
+	}
+
+	out := buf.Bytes()
+	if opts.DedupKeys {
+		names := make(map[string]bool)
+		aliasKeyNames(root, names)
+		if len(names) > 0 {
+			out = spaceAliasKeys(out, names)
+		}
+	}
+	if opts.FoldWidth > 0 {
+		values := make(map[string]bool)
+		foldedValues(root, opts.FoldWidth, values)
+		if len(values) > 0 {
+			out = wrapFolded(out, values, opts.FoldWidth)
+		}
+	}
+	return out, nil
+}
+
+// encodeTo encodes root to w with the configured indentation.
+func encodeTo(w io.Writer, root *yaml.Node, opts Options) error {
 	indent := opts.Indent
 	if indent <= 0 {
 		indent = 2
 	}
 
-	var buf bytes.Buffer
-	encoder := yaml.NewEncoder(&buf)
+	encoder := yaml.NewEncoder(w)
 	encoder.SetIndent(indent)
 	if err := encoder.Encode(root); err != nil {
-		return nil, fmt.Errorf("marshaling YAML: %w", err)
+		return fmt.Errorf("marshaling YAML: %w", err)
 	}
 	if err := encoder.Close(); err != nil {
-		return nil, fmt.Errorf("closing encoder: %w", err)
+		return fmt.Errorf("closing encoder: %w", err)
 	}
+	return nil
+}
 
-	return buf.Bytes(), nil
+// process deduplicates root in place. The returned Result carries the
+// statistics gathered during processing; byte counts are left to callers.
+func process(root *yaml.Node, opts Options) (Result, error) {
+	return newDuplicateFinder(opts).process(root, opts)
 }
 
-func process(root *yaml.Node, opts Options) {
-	df := newDuplicateFinder(opts)
+// process is process using df, which must be new or reset.
+func (df *duplicateFinder) process(root *yaml.Node, opts Options) (Result, error) {
 	if opts.TimeLimit > 0 {
+		df.timeLimit = opts.TimeLimit
 		df.deadline = time.Now().Add(opts.TimeLimit)
 	}
 
+	if opts.Strict {
+		if err := checkNodes(root); err != nil {
+			return Result{}, err
+		}
+	}
+
+	if opts.RefMode != RefModeNone {
+		return Result{}, df.processRefs(root, opts.RefMode)
+	}
+
+	if err := opts.Comments.validate(); err != nil {
+		return Result{}, err
+	}
+	if err := opts.AnchorNaming.validate(); err != nil {
+		return Result{}, err
+	}
+	if err := opts.Decisions.validate(); err != nil {
+		return Result{}, err
+	}
+	switch opts.YAMLVersion {
+	case YAMLVersionNone:
+	case YAML11, YAML12:
+		df.quoteAmbiguous(root)
+	default:
+		return Result{}, fmt.Errorf("unknown YAML version %q", opts.YAMLVersion)
+	}
+	if len(opts.SetKeys) > 0 {
+		df.canonicalizeSets(root, setOf(opts.SetKeys))
+	}
+
+	df.adoptAnchors(root)
+	if len(df.scopeKeys) > 0 {
+		df.markScope(root, false)
+	}
+	if _, err := df.markDirectives(root, directives{}); err != nil {
+		return Result{}, err
+	}
+	df.reserveDecidedNames()
 	df.scanNode(root, 0)
+	if opts.Strict {
+		// Paths are only complete before aliases replace subtrees.
+		if err := df.limitError(root); err != nil {
+			return Result{}, err
+		}
+	}
+	df.indexCandidates()
+	if df.maxAliasRatio > 0 {
+		df.countAliases(root)
+	}
 	df.markDuplicates()
 
-	visited := make(map[uint64]*yaml.Node)
-	df.replaceWithAliases(root, visited, 0)
+	df.replaceWithAliases(root, &shardedMap[*yaml.Node]{}, 0)
 
 	df.removeUnusedAnchors()
+
+	mergePasses := 0
+	if opts.MergeSubsets || opts.SequenceRuns {
+		mergePasses = df.mergeUntilStable(root)
+	}
+
+	if opts.HoistScalars {
+		key := opts.HoistKey
+		if key == "" {
+			key = DefaultHoistKey
+		}
+		hoistScalars(root, key)
+	}
+
+	if opts.MaxAliasDistance > 0 {
+		if err := limitAliasDistance(root, opts, opts.MaxAliasDistance); err != nil {
+			return Result{}, err
+		}
+	}
+
+	if opts.FoldWidth > 0 {
+		foldScalars(root, opts.FoldWidth)
+	}
+
+	if opts.AnchorFingerprints {
+		df.annotateFingerprints()
+	}
+	if df.isDeadlineExceeded() {
+		df.skipTimeLimit(root)
+		if opts.Strict {
+			return Result{}, df.limitError(root)
+		}
+	}
+	return Result{
+		HashCollisions: df.collisions,
+		NodesScanned:   df.scanned,
+		Candidates:     df.hashed,
+		IndexEntries:   df.nodesByHash.len(),
+		AuxBytes:       df.auxBytes(),
+		MergePasses:    mergePasses,
+		TimedOut:       df.timedOut,
+	}, nil
 }
 
 // anchorInfo tracks an anchor node and its reference count.
 type anchorInfo struct {
 	node     *yaml.Node
 	refCount int
+	hash     uint64
+	holder   *yaml.Node // node whose head comment describes the anchor
+	existing bool       // the input already anchored the node
 }
 
 var hasherPool = sync.Pool{
@@ -162,35 +718,88 @@ var kvSlicePool = sync.Pool{
 // duplicateFinder tracks duplicate YAML structures.
 type duplicateFinder struct {
 	minOccurrences int
+	minOccByKind   map[yaml.Kind]int
+	sectionLimits  map[string]int
+	excludeKeys    map[string]bool
+	dedupKeys      bool
+	yamlVersion    YAMLVersion
+	parallel       bool
+	comments       CommentMode
+	candidates     []candidateNode // filled by scanNode, hashed by indexCandidates
 	minSize        int
 	maxDepth       int
 	maxWidth       int
 	deadline       time.Time
+	timeLimit      time.Duration
+	timedOut       bool // a TimeLimit warning was recorded
+	score          func(DuplicateGroup) float64
+	sizeScored     bool // the default SizeScore picks anchors in size order
+	bySavings      bool
+	verify         bool
+	logger         *slog.Logger
+	collisions     int
+	scanned        int                     // nodes visited by scanNode
+	skipped        []skippedNode           // subtrees a limit kept from being scanned
+	hashed         int                     // candidates hashed by indexCandidates
+	index          map[uint64]*IndexBucket // decisions, recorded only by DumpIndex
+	decisions      Decisions
+	scopeKeys      map[string]bool
+	inScope        map[*yaml.Node]bool       // nodes under scopeKeys, when any are set
+	nameKeys       []string                  // fields anchors are named after, if any
+	hashNames      bool                      // name anchors per AnchorNamingHash
+	contextNames   bool                      // name anchors per AnchorNamingContext
+	directed       map[*yaml.Node]directives // nodes tuned by yamlmin: comments
+	reserved       map[string]bool           // taken names held for the groups that asked for them
+	noSequences    bool
+	mergeSubsets   bool
+	sequenceRuns   bool
+	noNested       bool
+	maxAliasRatio  float64
+	docNodes       int // nodes in the document, with MaxAliasRatio
+	docAliases     int // aliases among them
+	multilineOnly  bool
 
-	nodesByHash map[uint64][]*yaml.Node
-	isDuplicate map[uint64]bool        // tracks which hashes have duplicates
-	anchorNodes map[string]*anchorInfo // tracks anchors we create for cleanup
+	nodesByHash shardedMap[[]*yaml.Node]
+	hashOrder   []uint64                  // hashes in order of first occurrence
+	parents     map[*yaml.Node]*yaml.Node // scanned nodes to their parent
+	isDuplicate map[uint64]bool           // tracks which hashes have duplicates
+	counted     map[uint64]bool           // groups that take a counter name even unselected, see skipName
+	anchorNodes map[string]*anchorInfo    // tracks anchors we create for cleanup
 	mapCounter  int
 	listCounter int
 	strCounter  int
+	baseCounter int
+	runCounter  int
+	taken       map[string]bool // anchor names the input or this run already uses
+
+	scratch *scratch // reused buffers, kept only by a Minifier
 }
 
 // nextAnchorName returns a type-based anchor name like "list1", "map1", "str1", etc.
+// Names the input already uses are skipped.
 func (df *duplicateFinder) nextAnchorName(node *yaml.Node) string {
 	switch node.Kind {
 	case yaml.SequenceNode:
-		df.listCounter++
-		return "list" + strconv.Itoa(df.listCounter)
+		return df.freshName("list", &df.listCounter)
 	case yaml.MappingNode:
-		df.mapCounter++
-		return "map" + strconv.Itoa(df.mapCounter)
+		return df.freshName("map", &df.mapCounter)
 	case yaml.ScalarNode:
-		df.strCounter++
-		return "str" + strconv.Itoa(df.strCounter)
+		return df.freshName("str", &df.strCounter)
 	default:
 		// Fallback for unexpected types
-		df.mapCounter++
-		return "anchor" + strconv.Itoa(df.mapCounter)
+		return df.freshName("anchor", &df.mapCounter)
+	}
+}
+
+// freshName advances *counter until prefix and counter name an anchor not
+// yet taken, and takes it.
+func (df *duplicateFinder) freshName(prefix string, counter *int) string {
+	for {
+		*counter++
+		if name := prefix + strconv.Itoa(*counter); !df.taken[name] {
+			df.taken[name] = true
+			return name
+		}
 	}
 }
 
@@ -215,13 +824,49 @@ func newDuplicateFinder(opts Options) *duplicateFinder {
 		maxWidth = 10000
 	}
 
+	score := opts.Score
+	if score == nil {
+		score = SizeScore
+		if opts.MaximizeSavings {
+			score = SavingsScore
+		}
+	}
+
 	return &duplicateFinder{
+		taken:          make(map[string]bool),
 		minOccurrences: minOccurrences,
+		minOccByKind:   opts.MinOccurrencesByKind,
+		sectionLimits:  opts.SectionAnchorLimits,
+		excludeKeys:    setOf(opts.ExcludeKeys),
+		scopeKeys:      setOf(opts.ScopeKeys),
+		inScope:        make(map[*yaml.Node]bool),
+		directed:       make(map[*yaml.Node]directives),
+		reserved:       make(map[string]bool),
+		bySavings:      opts.MaximizeSavings,
+		nameKeys:       nameKeys(opts),
+		hashNames:      opts.AnchorNaming == AnchorNamingHash,
+		contextNames:   opts.AnchorNaming == AnchorNamingContext,
+		decisions:      opts.Decisions,
+		dedupKeys:      opts.DedupKeys,
+		yamlVersion:    opts.YAMLVersion,
+		parallel:       opts.Parallel,
+		comments:       opts.Comments,
 		minSize:        minSize,
 		maxDepth:       maxDepth,
 		maxWidth:       maxWidth,
-		nodesByHash:    make(map[uint64][]*yaml.Node),
+		score:          score,
+		sizeScored:     opts.Score == nil && !opts.MaximizeSavings,
+		verify:         opts.Verify,
+		logger:         opts.Logger,
+		noSequences:    opts.NoSequenceAnchors,
+		mergeSubsets:   opts.MergeSubsets,
+		maxAliasRatio:  opts.MaxAliasRatio,
+		sequenceRuns:   opts.SequenceRuns,
+		noNested:       opts.NoNestedAnchors,
+		multilineOnly:  opts.MultilineScalarsOnly,
+		parents:        make(map[*yaml.Node]*yaml.Node),
 		isDuplicate:    make(map[uint64]bool),
+		counted:        make(map[uint64]bool),
 		anchorNodes:    make(map[string]*anchorInfo),
 	}
 }
@@ -234,15 +879,14 @@ func (df *duplicateFinder) isDeadlineExceeded() bool {
 }
 
 func (df *duplicateFinder) hashNode(node *yaml.Node, depth int) (uint64, error) {
-	h := hasherPool.Get().(interface {
-		Write([]byte) (int, error)
-		Sum64() uint64
-		Reset()
-	})
-	defer func() {
-		h.Reset()
-		hasherPool.Put(h)
-	}()
+	var h hash.Hash64
+	if df.scratch != nil && !df.parallel {
+		h = df.scratch.hasher
+	} else {
+		h = hasherPool.Get().(hash.Hash64)
+		defer hasherPool.Put(h)
+	}
+	defer h.Reset()
 
 	if err := df.writeNodeToHash(h, node, depth); err != nil {
 		return 0, err
@@ -267,9 +911,30 @@ func (df *duplicateFinder) writeNodeToHash(h interface{ Write([]byte) (int, erro
 		return nil
 	}
 
+	// Aliases hash as the node they refer to, so a structure equals its alias.
+	if node.Kind == yaml.AliasNode {
+		if node.Alias == nil {
+			return nil
+		}
+		return df.writeNodeToHash(h, node.Alias, depth)
+	}
+	// So does a mapping holding nothing but a merge of one, as MergeSubsets
+	// leaves mappings made up entirely of the extracted pairs.
+	if base := mergeOnly(node); base != nil {
+		return df.writeNodeToHash(h, base, depth)
+	}
+
 	if _, err := h.Write([]byte{byte(node.Kind)}); err != nil {
 		return err
 	}
+	if err := df.writeCommentsToHash(h, node); err != nil {
+		return err
+	}
+	if tag := localTag(node); tag != "" {
+		if _, err := h.Write(append([]byte(tag), 0)); err != nil {
+			return err
+		}
+	}
 
 	switch node.Kind {
 	case yaml.DocumentNode:
@@ -285,30 +950,32 @@ func (df *duplicateFinder) writeNodeToHash(h interface{ Write([]byte) (int, erro
 		}
 
 		// Get pooled slice
-		pairsPtr := kvSlicePool.Get().(*[]kvPair)
+		pairsPtr := df.getPairs()
 		pairs := (*pairsPtr)[:0]
 
 		for i := 0; i < len(node.Content); i += 2 {
 			pairs = append(pairs, kvPair{node.Content[i], node.Content[i+1]})
 		}
 		sort.Slice(pairs, func(i, j int) bool {
-			return pairs[i].key.Value < pairs[j].key.Value
+			return keyString(pairs[i].key) < keyString(pairs[j].key)
 		})
 
 		for _, p := range pairs {
-			if _, err := h.Write([]byte(p.key.Value)); err != nil {
+			if err := df.writeKeyToHash(h, p.key, depth+1); err != nil {
+				*pairsPtr = pairs[:0]
+				df.putPairs(pairsPtr)
 				return err
 			}
 			if err := df.writeNodeToHash(h, p.value, depth+1); err != nil {
 				*pairsPtr = pairs[:0]
-				kvSlicePool.Put(pairsPtr)
+				df.putPairs(pairsPtr)
 				return err
 			}
 		}
 
 		// Return slice to pool
 		*pairsPtr = pairs[:0]
-		kvSlicePool.Put(pairsPtr)
+		df.putPairs(pairsPtr)
 	case yaml.SequenceNode:
 		if len(node.Content) > df.maxWidth {
 			return errLimitHit
@@ -319,15 +986,14 @@ func (df *duplicateFinder) writeNodeToHash(h interface{ Write([]byte) (int, erro
 			}
 		}
 	case yaml.ScalarNode:
-		if _, err := h.Write([]byte(node.Value)); err != nil {
-			return err
-		}
-	case yaml.AliasNode:
-		if node.Alias != nil {
-			if err := df.writeNodeToHash(h, node.Alias, depth+1); err != nil {
+		if df.yamlVersion != YAMLVersionNone {
+			if _, err := h.Write(append([]byte(df.scalarTag(node)), 0)); err != nil {
 				return err
 			}
 		}
+		if _, err := h.Write([]byte(node.Value)); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -339,6 +1005,9 @@ func (df *duplicateFinder) estimateSize(node *yaml.Node, depth int) int {
 	if node == nil {
 		return 0
 	}
+	if base := mergeOnly(node); base != nil {
+		return df.estimateSize(base, depth)
+	}
 
 	size := len(node.Value)
 	switch node.Kind {
@@ -365,63 +1034,326 @@ func (df *duplicateFinder) estimateSize(node *yaml.Node, depth int) int {
 }
 
 func (df *duplicateFinder) shouldAnchor(node *yaml.Node, depth int) bool {
-	if node.Kind == yaml.ScalarNode {
+	if len(df.scopeKeys) > 0 && !df.inScope[node] {
+		return false
+	}
+	if df.noNested && df.inAnchored(node) {
+		return false
+	}
+	minSize := df.minSize
+	if d, ok := df.directed[node]; ok {
+		if d.ignore || d.holdsIgnored {
+			return false
+		}
+		if d.minSize > 0 {
+			minSize = d.minSize
+		}
+	}
+	switch node.Kind {
+	case yaml.ScalarNode:
 		// Only deduplicate strings for now, and only if they meet size requirements
-		if node.Tag != "!!str" {
+		if df.scalarTag(node) != "!!str" {
+			return false
+		}
+		if df.multilineOnly && !strings.Contains(node.Value, "\n") {
+			return false
+		}
+	case yaml.SequenceNode:
+		if df.noSequences {
 			return false
 		}
-	} else if node.Kind != yaml.MappingNode && node.Kind != yaml.SequenceNode {
+	case yaml.MappingNode:
+	default:
 		return false
 	}
-	return df.estimateSize(node, depth) >= df.minSize
+	return df.estimateSize(node, depth) >= minSize
 }
 
 func (df *duplicateFinder) scanNode(node *yaml.Node, depth int) {
-	if depth > df.maxDepth || df.isDeadlineExceeded() {
+	if node == nil {
 		return
 	}
-	if node == nil {
+	if depth > df.maxDepth {
+		df.skip(node, WarningMaxDepth, strconv.Itoa(df.maxDepth))
 		return
 	}
+	if df.isDeadlineExceeded() {
+		df.skipTimeLimit(node)
+		return
+	}
+	df.scanned++
 
 	if df.shouldAnchor(node, depth) {
-		// If hashing fails (due to limits), we just skip this node as a duplicate candidate
-		if hash, err := df.hashNode(node, depth); err == nil {
-			df.nodesByHash[hash] = append(df.nodesByHash[hash], node)
-		}
+		df.candidates = append(df.candidates, candidateNode{node, depth})
 	}
 
 	switch node.Kind {
 	case yaml.DocumentNode:
 		for _, child := range node.Content {
+			df.parents[child] = node
 			df.scanNode(child, depth)
 		}
 	case yaml.MappingNode:
-		for i := 1; i < len(node.Content); i += 2 {
+		for i := df.firstMappingChild(); i < len(node.Content); i += df.mappingStep() {
 			if i/2 >= df.maxWidth {
+				df.skip(node, WarningMaxWidth, strconv.Itoa(df.maxWidth))
 				break
 			}
+			if df.excluded(node, i) {
+				continue
+			}
+			df.parents[node.Content[i]] = node
 			df.scanNode(node.Content[i], depth+1)
 		}
 	case yaml.SequenceNode:
 		for i, child := range node.Content {
 			if i >= df.maxWidth {
+				df.skip(node, WarningMaxWidth, strconv.Itoa(df.maxWidth))
 				break
 			}
+			df.parents[child] = node
 			df.scanNode(child, depth+1)
 		}
 	}
 }
 
+// minOccurrencesFor returns the occurrence threshold for the given node kind.
+func (df *duplicateFinder) minOccurrencesFor(kind yaml.Kind) int {
+	if n, ok := df.minOccByKind[kind]; ok && n > 0 {
+		return n
+	}
+	return df.minOccurrences
+}
+
+// hasAncestorIn reports whether any ancestor of node is in set.
+func (df *duplicateFinder) hasAncestorIn(node *yaml.Node, set map[*yaml.Node]bool) bool {
+	for p := df.parents[node]; p != nil; p = df.parents[p] {
+		if set[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// inAnchored reports whether an ancestor of node has an anchor.
+func (df *duplicateFinder) inAnchored(node *yaml.Node) bool {
+	for p := df.parents[node]; p != nil; p = df.parents[p] {
+		if p.Anchor != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// outsideAnchored returns the nodes of live not inside one of anchored, the
+// first occurrences of groups selected so far, for NoNestedAnchors.
+func (df *duplicateFinder) outsideAnchored(live []*yaml.Node, anchored map[*yaml.Node]bool) []*yaml.Node {
+	var outside []*yaml.Node
+	for _, n := range live {
+		if !df.hasAncestorIn(n, anchored) {
+			outside = append(outside, n)
+		}
+	}
+	return outside
+}
+
+// firstMappingChild and mappingStep select which mapping children are
+// candidates: values only, or keys and values with DedupKeys.
+func (df *duplicateFinder) firstMappingChild() int {
+	if df.dedupKeys {
+		return 0
+	}
+	return 1
+}
+
+func (df *duplicateFinder) mappingStep() int {
+	if df.dedupKeys {
+		return 1
+	}
+	return 2
+}
+
+// keyString returns the text of a scalar mapping key, looking through an
+// alias to its anchor.
+func keyString(key *yaml.Node) string {
+	if key.Kind == yaml.AliasNode && key.Alias != nil {
+		return key.Alias.Value
+	}
+	return key.Value
+}
+
+// writeKeyToHash hashes a mapping key. Scalar keys hash as their text, as
+// they always have; aliases and complex keys hash structurally.
+func (df *duplicateFinder) writeKeyToHash(h interface{ Write([]byte) (int, error) }, key *yaml.Node, depth int) error {
+	if key.Kind == yaml.AliasNode && key.Alias != nil {
+		key = key.Alias
+	}
+	if key.Kind == yaml.ScalarNode {
+		if err := df.writeCommentsToHash(h, key); err != nil {
+			return err
+		}
+		_, err := h.Write([]byte(key.Value))
+		return err
+	}
+	return df.writeNodeToHash(h, key, depth)
+}
+
+// excluded reports whether the i'th child of mapping is under a top-level key
+// listed in Options.ExcludeKeys.
+func (df *duplicateFinder) excluded(mapping *yaml.Node, i int) bool {
+	if len(df.excludeKeys) == 0 {
+		return false
+	}
+	if p := df.parents[mapping]; p != nil && p.Kind != yaml.DocumentNode {
+		return false
+	}
+	return df.excludeKeys[keyString(mapping.Content[i-i%2])]
+}
+
+// localTag returns node's tag when it is a local tag, such as CloudFormation's
+// "!Ref" or "!GetAtt", which gives a node a meaning its content alone doesn't
+// carry. Local tags are part of a node's identity in every YAMLVersion.
+func localTag(node *yaml.Node) string {
+	if strings.HasPrefix(node.Tag, "!") && !strings.HasPrefix(node.Tag, "!!") {
+		return node.Tag
+	}
+	return ""
+}
+
+// sectionOf returns the top-level mapping key whose value contains node, or
+// "" when node is not under a top-level mapping.
+func (df *duplicateFinder) sectionOf(node *yaml.Node) string {
+	for p := df.parents[node]; p != nil; node, p = p, df.parents[p] {
+		if gp := df.parents[p]; gp == nil || gp.Kind == yaml.DocumentNode {
+			if p.Kind != yaml.MappingNode {
+				return ""
+			}
+			for i, child := range p.Content {
+				if child == node {
+					return keyString(p.Content[i-i%2])
+				}
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// markDuplicates greedily selects which duplicate groups get anchors, visiting
+// groups in descending score order.
 func (df *duplicateFinder) markDuplicates() {
-	for hash, nodes := range df.nodesByHash {
-		if len(nodes) >= df.minOccurrences {
-			df.isDuplicate[hash] = true
+	var candidates []scoredGroup
+	for _, hash := range df.hashOrder {
+		nodes, _ := df.nodesByHash.get(hash)
+		if df.verify {
+			df.checkBucket(hash, nodes)
+		}
+		group := DuplicateGroup{
+			Kind:        nodes[0].Kind,
+			Hash:        hash,
+			Occurrences: len(nodes),
+			Size:        df.estimateSize(nodes[0], 0),
+			Nodes:       df.countNodes(nodes[0], 0),
+		}
+		if len(nodes) < df.minOccurrencesFor(nodes[0].Kind) {
+			df.decide(group, nodes, 0, DecisionTooFew)
+			continue
+		}
+		if df.sizeScored {
+			df.counted[hash] = true
+		}
+		if df.rejected(hash) {
+			df.decide(group, nodes, 0, DecisionRejected)
+			continue
+		}
+		score := df.score(group)
+		if score <= 0 {
+			df.decide(group, nodes, score, DecisionNoScore)
+			continue
+		}
+		df.decide(group, nodes, score, "")
+		candidates = append(candidates, scoredGroup{hash, score, group})
+	}
+	// Stable sort keeps first-occurrence order for ties, so parents win over
+	// equally sized children.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	if df.bySavings {
+		df.selectBySavings(candidates)
+		return
+	}
+
+	aliased := make(map[*yaml.Node]bool)   // non-first occurrences of selected groups
+	anchored := make(map[*yaml.Node]bool)  // first occurrences of selected groups
+	enclosing := make(map[*yaml.Node]bool) // ancestors of selected occurrences
+	sectionAnchors := make(map[string]int) // anchors selected per top-level key
+	aliases, shrink := 0, 0                // added by selected groups, for maxAliasRatio
+	for _, c := range candidates {
+		nodes, _ := df.nodesByHash.get(c.hash)
+
+		var live []*yaml.Node
+		for _, n := range nodes {
+			if !df.hasAncestorIn(n, aliased) {
+				live = append(live, n)
+			}
+		}
+		if len(live) < df.minOccurrencesFor(nodes[0].Kind) {
+			df.redecide(c.hash, DecisionAliased)
+			continue
+		}
+		if df.noNested {
+			if live = df.outsideAnchored(live, anchored); len(live) < df.minOccurrencesFor(nodes[0].Kind) {
+				df.redecide(c.hash, DecisionNested)
+				continue
+			}
+		}
+
+		conflict := false
+		for _, n := range live {
+			if enclosing[n] {
+				conflict = true
+				break
+			}
+		}
+		if conflict {
+			df.redecide(c.hash, DecisionEnclosing)
+			continue
+		}
+
+		a, sh := 0, 0
+		if df.maxAliasRatio > 0 {
+			if a, sh = df.aliasCost(live); df.overAliasRatio(aliases+a, shrink+sh) {
+				df.redecide(c.hash, DecisionAliasRatio)
+				continue
+			}
+		}
+
+		section := df.sectionOf(live[0])
+		if limit, ok := df.sectionLimits[section]; ok {
+			if sectionAnchors[section] >= limit {
+				df.redecide(c.hash, DecisionSectionBudget)
+				continue
+			}
+			sectionAnchors[section]++
+		}
+
+		aliases, shrink = aliases+a, shrink+sh
+		df.isDuplicate[c.hash] = true
+		df.redecide(c.hash, DecisionAnchored)
+		anchored[live[0]] = true
+		for i, n := range live {
+			if i > 0 {
+				aliased[n] = true
+			}
+			for p := df.parents[n]; p != nil; p = df.parents[p] {
+				enclosing[p] = true
+			}
 		}
 	}
 }
 
-func (df *duplicateFinder) replaceWithAliases(node *yaml.Node, visited map[uint64]*yaml.Node, depth int) {
+func (df *duplicateFinder) replaceWithAliases(node *yaml.Node, visited *shardedMap[*yaml.Node], depth int) {
 	if depth > df.maxDepth || df.isDeadlineExceeded() {
 		return
 	}
@@ -435,32 +1367,42 @@ func (df *duplicateFinder) replaceWithAliases(node *yaml.Node, visited map[uint6
 			df.replaceWithAliases(child, visited, depth)
 		}
 	case yaml.MappingNode:
-		for i := 1; i < len(node.Content); i += 2 {
+		for i := df.firstMappingChild(); i < len(node.Content); i += df.mappingStep() {
 			if i/2 >= df.maxWidth {
 				break
 			}
+			if df.excluded(node, i) {
+				continue
+			}
 			value := node.Content[i]
 
 			if df.shouldAnchor(value, depth) {
 				// If hash fails, we can't safely replace, so skip
 				if hash, err := df.hashNode(value, depth); err == nil {
-					if firstNode, exists := visited[hash]; exists && firstNode.Anchor != "" {
-						if value != firstNode {
+					if firstNode, exists := visited.get(hash); exists && firstNode.Anchor != "" {
+						// Replacing a node would orphan aliases of anchors inside it.
+						if value != firstNode && !containsAnchor(value) && df.verified(value, firstNode) {
 							aliasNode := &yaml.Node{
 								Kind:  yaml.AliasNode,
 								Value: firstNode.Anchor,
 								Alias: firstNode,
 							}
 							node.Content[i] = aliasNode
+							df.carryComments(aliasNode, value, node.Content[i-i%2])
 							df.anchorNodes[firstNode.Anchor].refCount++
 							continue
 						}
 					} else if !exists {
 						// Only create anchor if this hash has duplicates
 						if df.isDuplicate[hash] {
-							value.Anchor = df.nextAnchorName(value)
-							df.anchorNodes[value.Anchor] = &anchorInfo{node: value, refCount: 0}
-							visited[hash] = value
+							existing := value.Anchor != ""
+							if !existing {
+								value.Anchor = df.anchorName(value, hash)
+							}
+							df.anchorNodes[value.Anchor] = &anchorInfo{node: value, refCount: 0, hash: hash, holder: node.Content[i-i%2], existing: existing}
+							visited.set(hash, value)
+						} else if df.counted[hash] {
+							df.skipName(value, hash, visited)
 						}
 					}
 				}
@@ -475,22 +1417,29 @@ func (df *duplicateFinder) replaceWithAliases(node *yaml.Node, visited map[uint6
 			}
 			if df.shouldAnchor(child, depth) {
 				if hash, err := df.hashNode(child, depth); err == nil {
-					if firstNode, exists := visited[hash]; exists && firstNode.Anchor != "" {
-						if child != firstNode {
+					if firstNode, exists := visited.get(hash); exists && firstNode.Anchor != "" {
+						// Replacing a node would orphan aliases of anchors inside it.
+						if child != firstNode && !containsAnchor(child) && df.verified(child, firstNode) {
 							aliasNode := &yaml.Node{
 								Kind:  yaml.AliasNode,
 								Value: firstNode.Anchor,
 								Alias: firstNode,
 							}
 							node.Content[i] = aliasNode
+							df.carryComments(aliasNode, child, aliasNode)
 							df.anchorNodes[firstNode.Anchor].refCount++
 							continue
 						}
 					} else if !exists {
 						if df.isDuplicate[hash] {
-							child.Anchor = df.nextAnchorName(child)
-							df.anchorNodes[child.Anchor] = &anchorInfo{node: child, refCount: 0}
-							visited[hash] = child
+							existing := child.Anchor != ""
+							if !existing {
+								child.Anchor = df.anchorName(child, hash)
+							}
+							df.anchorNodes[child.Anchor] = &anchorInfo{node: child, refCount: 0, hash: hash, holder: child, existing: existing}
+							visited.set(hash, child)
+						} else if df.counted[hash] {
+							df.skipName(child, hash, visited)
 						}
 					}
 				}
@@ -501,11 +1450,49 @@ func (df *duplicateFinder) replaceWithAliases(node *yaml.Node, visited map[uint6
 	}
 }
 
+// skipName consumes the counter name of a group that has enough occurrences
+// but was not selected. Under the default size score every such group is
+// numbered in order of first occurrence, selected or not, so the name a
+// selected group gets does not depend on which other groups were selected.
+func (df *duplicateFinder) skipName(node *yaml.Node, hash uint64, visited *shardedMap[*yaml.Node]) {
+	if node.Anchor == "" && df.nameKeys == nil && !df.hashNames && !df.contextNames {
+		df.nextAnchorName(node)
+	}
+	visited.set(hash, node)
+}
+
+// FingerprintPrefix starts the comment written by Options.AnchorFingerprints;
+// the structural hash follows as 16 hex digits. With default options it is
+// the node's Fingerprint.
+const FingerprintPrefix = "# yamlmin:fingerprint="
+
+// annotateFingerprints adds a fingerprint comment for every anchor that
+// survived processing.
+func (df *duplicateFinder) annotateFingerprints() {
+	for _, info := range df.anchorNodes {
+		if info.node.Anchor == "" {
+			continue
+		}
+		if info.existing {
+			hash, err := df.hashNode(info.node, 0)
+			if err != nil {
+				continue
+			}
+			info.hash = hash
+		}
+		comment := fmt.Sprintf("%s%016x", FingerprintPrefix, info.hash)
+		if info.holder.HeadComment != "" {
+			comment = info.holder.HeadComment + "\n" + comment
+		}
+		info.holder.HeadComment = comment
+	}
+}
+
 // removeUnusedAnchors clears anchors that have no aliases pointing to them.
 // Uses O(m) map iteration instead of O(n) tree traversal.
 func (df *duplicateFinder) removeUnusedAnchors() {
 	for _, info := range df.anchorNodes {
-		if info.refCount == 0 {
+		if info.refCount == 0 && !info.existing {
 			info.node.Anchor = ""
 		}
 	}
@@ -1,6 +1,8 @@
 package yamlmin_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"os"
 	"testing"
 
@@ -118,3 +120,279 @@ data:
 
 	assert.Less(t, len(output), len(input))
 }
+
+func TestMarshalAll(t *testing.T) {
+	shared := map[string]string{"a": "a long repeated string", "b": "a long repeated string"}
+	out, err := yamlmin.MarshalAll([]interface{}{shared, []int{1, 2}, shared})
+	require.NoError(t, err)
+	assert.Equal(t, `a: &str1 a long repeated string
+b: *str1
+---
+- 1
+- 2
+---
+a: &str1 a long repeated string
+b: *str1
+`, string(out))
+
+	opts := yamlmin.DefaultOptions()
+	opts.UniqueAnchors = true
+	opts.ConcatSafe = true
+	out, err = yamlmin.MarshalAllWithOptions([]interface{}{shared, shared}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "---\na: &doc1_str1 a long repeated string\nb: *doc1_str1\n---\na: &doc2_str1 a long repeated string\nb: *doc2_str1\n", string(out))
+
+	out, err = yamlmin.MarshalAll(nil)
+	require.NoError(t, err)
+	assert.Empty(t, out)
+}
+
+func TestProcessNode(t *testing.T) {
+	var root yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte("a: a long repeated string # note\nb: a long repeated string\n"), &root))
+	require.NoError(t, yamlmin.ProcessNode(&root, yamlmin.DefaultOptions()))
+
+	out, err := yaml.Marshal(&root)
+	require.NoError(t, err)
+	assert.Equal(t, "a: &str1 a long repeated string # note\nb: *str1\n", string(out))
+
+	assert.Error(t, yamlmin.ProcessNode(&root, yamlmin.Options{Comments: "bogus"}))
+}
+
+func TestMinifier(t *testing.T) {
+	data, err := os.ReadFile("testdata/fixture.yaml")
+	require.NoError(t, err)
+	var value interface{}
+	require.NoError(t, yaml.Unmarshal(data, &value))
+
+	opts := yamlmin.DefaultOptions()
+	opts.Verify = true
+	wantMarshal, err := yamlmin.MarshalWithOptions(value, opts)
+	require.NoError(t, err)
+	wantBytes, err := yamlmin.MinifyBytes(data, opts)
+	require.NoError(t, err)
+
+	m := yamlmin.NewMinifier(opts)
+	for i := range 3 {
+		out, err := m.Marshal(value)
+		require.NoError(t, err)
+		assert.Equal(t, string(wantMarshal), string(out), "call %d", i)
+
+		out, err = m.MinifyBytes(data)
+		require.NoError(t, err)
+		assert.Equal(t, string(wantBytes), string(out), "call %d", i)
+
+		m.Reset()
+	}
+
+	out, err := m.MinifyBytes([]byte("a: a long repeated string\nb: a long repeated string\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "a: &str1 a long repeated string\nb: *str1\n", string(out))
+}
+
+func TestIdempotent(t *testing.T) {
+	data, err := os.ReadFile("testdata/fixture.yaml")
+	require.NoError(t, err)
+
+	for name, set := range map[string]func(*yamlmin.Options){
+		"default":      func(*yamlmin.Options) {},
+		"hoist":        func(o *yamlmin.Options) { o.HoistScalars = true },
+		"merge":        func(o *yamlmin.Options) { o.MergeSubsets = true },
+		"dedupKeys":    func(o *yamlmin.Options) { o.DedupKeys = true },
+		"unique":       func(o *yamlmin.Options) { o.UniqueAnchors = true },
+		"fingerprints": func(o *yamlmin.Options) { o.AnchorFingerprints = true },
+		"distance":     func(o *yamlmin.Options) { o.MaxAliasDistance = 30 },
+		"comments":     func(o *yamlmin.Options) { o.Comments = yamlmin.CommentsKeep },
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := yamlmin.DefaultOptions()
+			set(&opts)
+			once, err := yamlmin.MinifyBytes(data, opts)
+			require.NoError(t, err)
+			twice, err := yamlmin.MinifyBytes(once, opts)
+			require.NoError(t, err)
+			assert.Equal(t, string(once), string(twice))
+		})
+	}
+
+	// Anchors in the input keep their names and take new copies; new
+	// anchors skip names already in use.
+	out, err := yamlmin.MinifyBytes([]byte("a: &map1\n  name: a long repeated string\nb: *map1\n"+
+		"c:\n  name: a long repeated string\nd: &list1 [x]\ne: *list1\n"+
+		"f: [one long list item value, two]\ng: [one long list item value, two]\n"), yamlmin.DefaultOptions())
+	require.NoError(t, err)
+	assert.Equal(t, "a: &map1\n  name: a long repeated string\nb: *map1\nc: *map1\n"+
+		"d: &list1 [x]\ne: *list1\nf: &list2 [one long list item value, two]\ng: *list2\n", string(out))
+}
+
+func TestMarshalWithStats(t *testing.T) {
+	shared := map[string]string{"image": "nginx:1.25", "pull": "IfNotPresent"}
+	data := map[string]interface{}{"a": shared, "b": shared, "c": shared}
+
+	out, stats, err := yamlmin.MarshalWithStats(data, yamlmin.DefaultOptions())
+	require.NoError(t, err)
+	assert.Equal(t, `a: &map1
+  image: nginx:1.25
+  pull: IfNotPresent
+b: *map1
+c: *map1
+`, string(out))
+	assert.Equal(t, len(out), stats.OutputBytes)
+	assert.Greater(t, stats.InputBytes, stats.OutputBytes)
+	assert.Equal(t, 1, stats.Anchors)
+	assert.Equal(t, 2, stats.Aliases)
+	assert.Equal(t, 10, stats.NodesScanned)
+	// Content "imagenginx:1.25pullIfNotPresent" is 31 bytes; each alias
+	// costs 5 ("*map1") and the anchor 6 ("&map1 ").
+	assert.Equal(t, map[string]int{"map1": 2*(31-5) - 6}, stats.AnchorSavings)
+}
+
+func TestMarshalTo(t *testing.T) {
+	data := map[string]string{"a": "a long repeated string", "b": "a long repeated string"}
+	for _, opts := range []yamlmin.Options{
+		yamlmin.DefaultOptions(),
+		yamlmin.NewOptions(func(o *yamlmin.Options) { o.ConcatSafe = true }),
+	} {
+		want, err := yamlmin.MarshalWithOptions(data, opts)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, yamlmin.MarshalTo(&buf, data, opts))
+		assert.Equal(t, string(want), buf.String())
+	}
+}
+
+func TestMarshalAppend(t *testing.T) {
+	data := map[string]string{"a": "a long repeated string", "b": "a long repeated string"}
+	want, err := yamlmin.Marshal(data)
+	require.NoError(t, err)
+
+	buf := make([]byte, 0, 256)
+	for range 2 {
+		buf, err = yamlmin.MarshalAppend(buf[:0], data, yamlmin.DefaultOptions())
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(buf))
+		assert.Equal(t, 256, cap(buf))
+	}
+
+	out, err := yamlmin.MarshalAppend([]byte("# header\n"), data, yamlmin.DefaultOptions())
+	require.NoError(t, err)
+	assert.Equal(t, "# header\n"+string(want), string(out))
+}
+
+func TestK8sMarshalMatchesYAMLParse(t *testing.T) {
+	data := map[string]interface{}{
+		"text":    "a <b> & \"c\"   é",
+		"numbers": []interface{}{0, -1, 1.5, 1e21, uint64(1) << 63, 1e-7},
+		"flags":   []interface{}{true, false, nil},
+		"empty":   map[string]interface{}{"list": []int{}, "map": map[string]int{}},
+		"nested":  []interface{}{map[string]string{"k": "a long repeated string"}, map[string]string{"k": "a long repeated string"}},
+		"yes":     "yes",
+	}
+
+	y, err := json.Marshal(data)
+	require.NoError(t, err)
+	var root yaml.Node
+	require.NoError(t, yaml.Unmarshal(y, &root))
+	require.NoError(t, yamlmin.ProcessNode(&root, yamlmin.DefaultOptions()))
+	var want bytes.Buffer
+	enc := yaml.NewEncoder(&want)
+	enc.SetIndent(2)
+	require.NoError(t, enc.Encode(&root))
+	require.NoError(t, enc.Close())
+
+	out, err := yamlmin.K8sMarshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, want.String(), string(out))
+}
+
+func TestDirectives(t *testing.T) {
+	input := `a: {image: nginx, pull: IfNotPresent}
+# yamlmin:ignore kept literal for the deploy script
+b: {image: nginx, pull: IfNotPresent}
+c: {image: nginx, pull: IfNotPresent}
+items:
+  # yamlmin:ignore
+  - {image: nginx, pull: IfNotPresent}
+  - nested: {image: nginx, pull: IfNotPresent} # yamlmin:ignore
+d: {image: nginx, pull: IfNotPresent} # yamlmin:ignored is not the directive
+`
+	out, err := yamlmin.MinifyBytes([]byte(input), yamlmin.DefaultOptions())
+	require.NoError(t, err)
+	assert.Equal(t, `a: &map1 {image: nginx, pull: IfNotPresent}
+# yamlmin:ignore kept literal for the deploy script
+b: {image: nginx, pull: IfNotPresent}
+c: *map1
+items:
+  # yamlmin:ignore
+  - {image: nginx, pull: IfNotPresent}
+  - nested: {image: nginx, pull: IfNotPresent} # yamlmin:ignore
+d: *map1
+`, string(out))
+
+	// A block holding an ignored node is not aliased in its place either.
+	input = `a:
+  labels: {app: web, tier: frontend}
+  replicas: 3
+b:
+  # yamlmin:ignore
+  labels: {app: web, tier: frontend}
+  replicas: 3
+`
+	out, err = yamlmin.MinifyBytes([]byte(input), yamlmin.DefaultOptions())
+	require.NoError(t, err)
+	assert.Equal(t, input, string(out))
+
+	input = `# yamlmin:min-size=5
+ports:
+  a: [80, 443]
+  b: [80, 443]
+  c: {name: x}
+  # yamlmin:anchor-name=named
+  d: {name: x}
+other:
+  a: [80, 443]
+  b: {name: x}
+`
+	out, err = yamlmin.MinifyBytes([]byte(input), yamlmin.DefaultOptions())
+	require.NoError(t, err)
+	assert.Equal(t, `# yamlmin:min-size=5
+ports:
+  a: &list1 [80, 443]
+  b: *list1
+  c: &named {name: x}
+  # yamlmin:anchor-name=named
+  d: *named
+other:
+  a: [80, 443]
+  b: {name: x}
+`, string(out))
+
+	// A directive's name is held for its group even when a counter would
+	// reach it first.
+	input = `a:
+  x: {image: nginx, pull: IfNotPresent}
+b:
+  # yamlmin:anchor-name=map1
+  x: {p: 1111111111, q: 2222222222}
+c:
+  x: {image: nginx, pull: IfNotPresent}
+  y: {p: 1111111111, q: 2222222222}
+`
+	out, err = yamlmin.MinifyBytes([]byte(input), yamlmin.DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "x: &map2 {image: nginx")
+	assert.Contains(t, string(out), "x: &map1 {p: 1111111111")
+	equal, diff, err := yamlmin.Equivalent([]byte(input), out)
+	require.NoError(t, err)
+	assert.True(t, equal, diff)
+
+	for directive, msg := range map[string]string{
+		"yamlmin:min-size=small":     `line 3: yamlmin:min-size must be a positive integer, got "small"`,
+		"yamlmin:anchor-name=*x":     `line 3: invalid yamlmin:anchor-name "*x": use only letters, digits, '_', and '-'`,
+		"yamlmin:anchor-name=web.v1": `line 3: invalid yamlmin:anchor-name "web.v1": use only letters, digits, '_', and '-'`,
+	} {
+		_, err := yamlmin.MinifyBytes([]byte("a: 1\n# "+directive+"\nb: 2\n"), yamlmin.DefaultOptions())
+		assert.EqualError(t, err, msg, directive)
+	}
+}
@@ -0,0 +1,24 @@
+package yamlmin
+
+// Approximate per-entry costs, in bytes, of the bookkeeping a
+// duplicateFinder keeps while processing, on a 64-bit platform and counting
+// map overhead.
+const (
+	parentEntryBytes = 48 // parents: node and parent pointers
+	candidateBytes   = 25 // candidates, plus its hash and ok flag
+	indexEntryBytes  = 64 // nodesByHash key and bucket header, hashOrder
+	indexedNodeBytes = 8  // a node pointer in a nodesByHash bucket
+)
+
+// auxBytes estimates the peak memory held by df's scan and index
+// bookkeeping, which is reached once every candidate has been hashed.
+func (df *duplicateFinder) auxBytes() int {
+	indexed := 0
+	for _, nodes := range df.nodesByHash.all() {
+		indexed += len(nodes)
+	}
+	return len(df.parents)*parentEntryBytes +
+		df.hashed*candidateBytes +
+		df.nodesByHash.len()*indexEntryBytes +
+		indexed*indexedNodeBytes
+}
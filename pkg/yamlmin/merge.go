@@ -0,0 +1,280 @@
+package yamlmin
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// subsetGroup is a set of mappings sharing the same frequent key/value pairs.
+type subsetGroup struct {
+	pairs []uint64 // sorted pair hashes forming the shared subset
+	maps  []*yaml.Node
+	size  int
+}
+
+// maxMergePasses bounds the detection passes of Options.MergeSubsets.
+const maxMergePasses = 4
+
+// mergeUntilStable extracts shared subsets and sequence runs and then
+// deduplicates again, as what is left after a base or run is pulled out can
+// be duplicates itself, until a pass extracts nothing or maxMergePasses is
+// reached. It returns the number of passes, counting the deduplication
+// process already did.
+func (df *duplicateFinder) mergeUntilStable(root *yaml.Node) int {
+	passes := 1
+	for df.extractShared(root) && passes < maxMergePasses && !df.isDeadlineExceeded() {
+		passes++
+		pruneAnchors(root)
+		orderAnchors(root)
+
+		df.nodesByHash.clear()
+		clear(df.parents)
+		clear(df.isDuplicate)
+		df.hashOrder = df.hashOrder[:0]
+		df.adoptAnchors(root)
+		df.scanNode(root, 0)
+		df.indexCandidates()
+		if df.maxAliasRatio > 0 {
+			df.countAliases(root)
+		}
+		df.markDuplicates()
+		df.replaceWithAliases(root, &shardedMap[*yaml.Node]{}, 0)
+		df.removeUnusedAnchors()
+	}
+	pruneAnchors(root)
+	orderAnchors(root)
+	return passes
+}
+
+// extractShared runs the enabled extractions and reports whether any of them
+// extracted something.
+func (df *duplicateFinder) extractShared(root *yaml.Node) bool {
+	extracted := false
+	if df.mergeSubsets && df.extractSubsets(root) {
+		extracted = true
+	}
+	if df.sequenceRuns && df.extractRuns(root) {
+		extracted = true
+	}
+	return extracted
+}
+
+// extractSubsets moves key/value pairs shared by several mappings into a base
+// mapping anchored at its first use and merged everywhere with "<<". It
+// reports whether it extracted any.
+func (df *duplicateFinder) extractSubsets(root *yaml.Node) bool {
+	var maps []*yaml.Node
+	pairHashes := make(map[*yaml.Node][]uint64) // per map, aligned with its pairs
+	df.collectMaps(root, 0, &maps)
+
+	counts := make(map[uint64]int)
+	for _, m := range maps {
+		hashes := make([]uint64, len(m.Content)/2)
+		seen := make(map[uint64]bool)
+		for i := 0; i < len(m.Content); i += 2 {
+			h, ok := df.pairHash(m.Content[i], m.Content[i+1])
+			if !ok {
+				continue
+			}
+			hashes[i/2] = h
+			if !seen[h] {
+				seen[h] = true
+				counts[h]++
+			}
+		}
+		pairHashes[m] = hashes
+	}
+
+	minOcc := df.minOccurrencesFor(yaml.MappingNode)
+	groups := make(map[uint64]*subsetGroup)
+	var order []*subsetGroup
+	for _, m := range maps {
+		var subset []uint64
+		size := 0
+		for i, h := range pairHashes[m] {
+			if h != 0 && counts[h] >= minOcc {
+				subset = append(subset, h)
+				size += df.estimateSize(m.Content[2*i], 0) + df.estimateSize(m.Content[2*i+1], 0)
+			}
+		}
+		if len(subset) == 0 || size < df.minSize {
+			continue
+		}
+		sort.Slice(subset, func(i, j int) bool { return subset[i] < subset[j] })
+		sig := fnv.New64a()
+		for _, h := range subset {
+			_ = binary.Write(sig, binary.LittleEndian, h)
+		}
+		key := sig.Sum64()
+		g, ok := groups[key]
+		if !ok {
+			g = &subsetGroup{pairs: subset, size: size}
+			groups[key] = g
+			order = append(order, g)
+		}
+		g.maps = append(g.maps, m)
+	}
+
+	// Larger subsets first; ties keep document order.
+	sort.SliceStable(order, func(i, j int) bool { return order[i].size > order[j].size })
+
+	gone := make(map[*yaml.Node]bool) // nodes dropped from non-first mappings
+	extracted := false
+	for _, g := range order {
+		var live []*yaml.Node
+		for _, m := range g.maps {
+			if !gone[m] {
+				live = append(live, m)
+			}
+		}
+		if len(live) < minOcc {
+			continue
+		}
+		if df.applySubset(g, live, pairHashes, gone) {
+			extracted = true
+		}
+	}
+	return extracted
+}
+
+// applySubset rewrites the mappings of one group to merge a shared base, and
+// reports whether it did.
+func (df *duplicateFinder) applySubset(g *subsetGroup, maps []*yaml.Node, pairHashes map[*yaml.Node][]uint64, gone map[*yaml.Node]bool) bool {
+	inSubset := make(map[uint64]bool, len(g.pairs))
+	for _, h := range g.pairs {
+		inSubset[h] = true
+	}
+
+	// Dropping pairs from later mappings must not remove an anchor still in use.
+	var rewrite []*yaml.Node
+	for i, m := range maps {
+		if i > 0 {
+			safe := true
+			for j, h := range pairHashes[m] {
+				if inSubset[h] && containsAnchor(m.Content[2*j+1]) {
+					safe = false
+					break
+				}
+			}
+			if !safe {
+				continue
+			}
+		}
+		rewrite = append(rewrite, m)
+	}
+	if len(rewrite) < df.minOccurrencesFor(yaml.MappingNode) {
+		return false
+	}
+
+	base := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	var aliases []*yaml.Node
+	for i, m := range rewrite {
+		var rest []*yaml.Node
+		for j, h := range pairHashes[m] {
+			key, value := m.Content[2*j], m.Content[2*j+1]
+			switch {
+			case !inSubset[h]:
+				rest = append(rest, key, value)
+			case i == 0:
+				base.Content = append(base.Content, key, value)
+			default:
+				markGone(value, gone)
+			}
+		}
+
+		merged := base
+		if i > 0 {
+			merged = &yaml.Node{Kind: yaml.AliasNode, Alias: base}
+			aliases = append(aliases, merged)
+		}
+		mergeKey := &yaml.Node{Kind: yaml.ScalarNode, Value: "<<"}
+		m.Content = append([]*yaml.Node{mergeKey, merged}, rest...)
+	}
+	base.Anchor = df.structureName(base, "base", &df.baseCounter)
+	for _, alias := range aliases {
+		alias.Value = base.Anchor
+	}
+	return true
+}
+
+// collectMaps gathers mappings eligible for subset extraction in document order.
+func (df *duplicateFinder) collectMaps(node *yaml.Node, depth int, maps *[]*yaml.Node) {
+	if node == nil || depth > df.maxDepth || df.isDeadlineExceeded() {
+		return
+	}
+	if node.Kind == yaml.MappingNode && len(node.Content)/2 <= df.maxWidth && !hasMergeKey(node) {
+		*maps = append(*maps, node)
+	}
+	for i, child := range node.Content {
+		if node.Kind == yaml.MappingNode && df.excluded(node, i) {
+			continue
+		}
+		if node.Kind == yaml.MappingNode && i%2 == 1 && isMergeKey(node.Content[i-1]) {
+			// A base stays whole; only maps nested in it are candidates.
+			for _, c := range child.Content {
+				df.collectMaps(c, depth+2, maps)
+			}
+			continue
+		}
+		df.collectMaps(child, depth+1, maps)
+	}
+}
+
+// pairHash combines a scalar key with the structural hash of its value.
+func (df *duplicateFinder) pairHash(key, value *yaml.Node) (uint64, bool) {
+	if key.Kind != yaml.ScalarNode {
+		return 0, false
+	}
+	vh, err := df.hashNode(value, 0)
+	if err != nil {
+		return 0, false
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key.Value))
+	_ = binary.Write(h, binary.LittleEndian, vh)
+	return h.Sum64(), true
+}
+
+// mergeOnly returns the mapping node merges when that is all node holds, as
+// in {<<: *base1}, or nil.
+func mergeOnly(node *yaml.Node) *yaml.Node {
+	if node.Kind != yaml.MappingNode || len(node.Content) != 2 || !isMergeKey(node.Content[0]) ||
+		localTag(node) != "" || hasComments(node) || hasComments(node.Content[0]) {
+		return nil
+	}
+	if value := node.Content[1]; value.Kind == yaml.AliasNode && value.Alias != nil && value.Alias.Kind == yaml.MappingNode {
+		return value.Alias
+	}
+	return nil
+}
+
+func hasMergeKey(node *yaml.Node) bool {
+	for i := 0; i < len(node.Content); i += 2 {
+		if isMergeKey(node.Content[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAnchor(node *yaml.Node) bool {
+	if node.Anchor != "" {
+		return true
+	}
+	for _, child := range node.Content {
+		if containsAnchor(child) {
+			return true
+		}
+	}
+	return false
+}
+
+func markGone(node *yaml.Node, gone map[*yaml.Node]bool) {
+	gone[node] = true
+	for _, child := range node.Content {
+		markGone(child, gone)
+	}
+}
@@ -0,0 +1,150 @@
+package yamlmin_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMergeSubsets(t *testing.T) {
+	container := func(name string) map[string]interface{} {
+		return map[string]interface{}{
+			"name":            name,
+			"image":           "registry.example.com/" + name,
+			"imagePullPolicy": "IfNotPresent",
+			"resources": map[string]interface{}{
+				"limits": map[string]string{"cpu": "500m", "memory": "128Mi"},
+			},
+		}
+	}
+	data := map[string]interface{}{
+		"containers": []interface{}{container("frontend"), container("backend"), container("worker")},
+	}
+
+	opts := yamlmin.DefaultOptions()
+	opts.MergeSubsets = true
+
+	out, err := yamlmin.MarshalWithOptions(data, opts)
+	require.NoError(t, err)
+
+	expected := `containers:
+  - <<: &base1
+      imagePullPolicy: IfNotPresent
+      resources:
+        limits:
+          cpu: 500m
+          memory: 128Mi
+    image: registry.example.com/frontend
+    name: frontend
+  - <<: *base1
+    image: registry.example.com/backend
+    name: backend
+  - <<: *base1
+    image: registry.example.com/worker
+    name: worker
+`
+	assert.Equal(t, expected, string(out))
+
+	expectedBytes, err := yaml.Marshal(data)
+	require.NoError(t, err)
+	equal, diff, err := yamlmin.Equivalent(expectedBytes, out)
+	require.NoError(t, err)
+	assert.True(t, equal, diff.String())
+}
+
+func TestMergePasses(t *testing.T) {
+	input := `containers:
+  - {name: a, image: nginx, pull: IfNotPresent, limits: {cpu: 500m, memory: 128Mi}}
+  - {name: b, image: nginx, pull: IfNotPresent, limits: {cpu: 500m, memory: 128Mi}}
+  - {name: c, image: nginx, pull: IfNotPresent, limits: {cpu: 500m, memory: 128Mi}}
+defaults: {image: nginx, pull: IfNotPresent, limits: {cpu: 500m, memory: 128Mi}}
+`
+	opts := yamlmin.DefaultOptions()
+	opts.MergeSubsets = true
+	dec := yamlmin.NewDecoder(strings.NewReader(input), opts)
+	out, res, err := dec.Decode()
+	require.NoError(t, err)
+
+	// Extracting the base leaves defaults as a bare merge of it, which the
+	// second pass aliases.
+	assert.Equal(t, `containers:
+  - {<<: &base1 {image: nginx, pull: IfNotPresent, limits: {cpu: 500m, memory: 128Mi}}, name: a}
+  - {<<: *base1, name: b}
+  - {<<: *base1, name: c}
+defaults: *base1
+`, string(out))
+	assert.Equal(t, 2, res.MergePasses)
+	equal, diff, err := yamlmin.Equivalent([]byte(input), out)
+	require.NoError(t, err)
+	assert.True(t, equal, diff.String())
+
+	again, err := yamlmin.MinifyBytes(out, opts)
+	require.NoError(t, err)
+	assert.Equal(t, string(out), string(again))
+
+	_, res, err = yamlmin.NewDecoder(strings.NewReader(input), yamlmin.DefaultOptions()).Decode()
+	require.NoError(t, err)
+	assert.Zero(t, res.MergePasses)
+}
+
+func TestSequenceRuns(t *testing.T) {
+	input := `build:
+  - make deps
+  - make build
+  - make test
+  - make package
+check:
+  - echo checking
+  - make deps
+  - make build
+  - make test
+`
+	opts := yamlmin.DefaultOptions()
+	opts.SequenceRuns = true
+	out, res, err := yamlmin.NewDecoder(strings.NewReader(input), opts).Decode()
+	require.NoError(t, err)
+	assert.Equal(t, `build:
+  - &run1
+    - make deps
+    - make build
+    - make test
+  - make package
+check:
+  - echo checking
+  - *run1
+`, string(out))
+	assert.Equal(t, 2, res.MergePasses)
+
+	// The run nests, so the output only matches once nested lists are flattened.
+	var want, got map[string][]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(input), &want))
+	require.NoError(t, yaml.Unmarshal(out, &got))
+	for key, items := range got {
+		assert.Equal(t, want[key], flatten(items), key)
+	}
+
+	again, err := yamlmin.MinifyBytes(out, opts)
+	require.NoError(t, err)
+	assert.Equal(t, string(out), string(again))
+
+	opts.NoSequenceAnchors = true
+	out, err = yamlmin.MinifyBytes([]byte(input), opts)
+	require.NoError(t, err)
+	assert.Equal(t, input, string(out))
+}
+
+func flatten(items []interface{}) []interface{} {
+	var flat []interface{}
+	for _, item := range items {
+		if list, ok := item.([]interface{}); ok {
+			flat = append(flat, flatten(list)...)
+		} else {
+			flat = append(flat, item)
+		}
+	}
+	return flat
+}
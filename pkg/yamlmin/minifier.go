@@ -0,0 +1,120 @@
+package yamlmin
+
+import (
+	"bytes"
+	"hash"
+	"hash/fnv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Minifier deduplicates documents with fixed Options. Unlike the
+// package-level functions it keeps its hash maps, hasher, and scratch slices
+// between calls, so services minifying many documents avoid reallocating
+// them and contending on shared pools.
+//
+// A Minifier is not safe for concurrent use; give each goroutine its own.
+type Minifier struct {
+	opts Options
+	df   *duplicateFinder
+}
+
+// NewMinifier returns a Minifier using opts.
+func NewMinifier(opts Options) *Minifier {
+	m := &Minifier{opts: opts}
+	m.Reset()
+	return m
+}
+
+// Marshal is MarshalWithOptions with m's options.
+func (m *Minifier) Marshal(in interface{}) ([]byte, error) {
+	root, err := encodeValue(in)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.process(root); err != nil {
+		return nil, err
+	}
+	out, _, err := encodeOutput(root, m.opts)
+	return out, err
+}
+
+// MinifyBytes is MinifyBytes with m's options.
+func (m *Minifier) MinifyBytes(data []byte) ([]byte, error) {
+	dec := NewDecoder(bytes.NewReader(data), m.opts)
+	dec.min = m
+	var out bytes.Buffer
+	if _, err := dec.WriteStream(&out, nil); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Reset releases the memory m has kept from earlier calls. Cleared maps never
+// shrink, so call it after an unusually large document to avoid holding on
+// to its footprint.
+func (m *Minifier) Reset() {
+	m.df = newDuplicateFinder(m.opts)
+	m.df.scratch = &scratch{hasher: fnv.New64a()}
+}
+
+// process deduplicates root, reusing what the previous call allocated.
+func (m *Minifier) process(root *yaml.Node) (Result, error) {
+	m.df.reset()
+	return m.df.process(root, m.opts)
+}
+
+// scratch holds buffers a duplicateFinder reuses across documents in place of
+// hasherPool and kvSlicePool. Parallel hashing still uses the pools.
+type scratch struct {
+	hasher hash.Hash64
+	pairs  []*[]kvPair // free kvPair slices
+}
+
+// reset clears df's per-document state, keeping its maps and slices
+// allocated, so it can process another document.
+func (df *duplicateFinder) reset() {
+	df.nodesByHash.clear()
+	clear(df.parents)
+	clear(df.isDuplicate)
+	clear(df.counted)
+	clear(df.anchorNodes)
+	clear(df.taken)
+	clear(df.inScope)
+	clear(df.directed)
+	clear(df.reserved)
+	df.hashOrder = df.hashOrder[:0]
+	df.deadline, df.timeLimit, df.timedOut = time.Time{}, 0, false
+	df.skipped = df.skipped[:0]
+	df.collisions = 0
+	df.scanned = 0
+	df.hashed = 0
+	df.index = nil
+	df.mapCounter, df.listCounter, df.strCounter, df.baseCounter, df.runCounter = 0, 0, 0, 0, 0
+}
+
+// getPairs returns an empty kvPair slice from df's scratch space, or from
+// kvSlicePool when it has none or hashes in parallel.
+func (df *duplicateFinder) getPairs() *[]kvPair {
+	s := df.scratch
+	if s == nil || df.parallel {
+		return kvSlicePool.Get().(*[]kvPair)
+	}
+	if n := len(s.pairs); n > 0 {
+		p := s.pairs[n-1]
+		s.pairs = s.pairs[:n-1]
+		return p
+	}
+	p := make([]kvPair, 0, 16)
+	return &p
+}
+
+// putPairs returns a slice obtained from getPairs.
+func (df *duplicateFinder) putPairs(p *[]kvPair) {
+	if s := df.scratch; s != nil && !df.parallel {
+		s.pairs = append(s.pairs, p)
+		return
+	}
+	kvSlicePool.Put(p)
+}
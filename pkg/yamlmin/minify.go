@@ -0,0 +1,61 @@
+package yamlmin
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// MinifyString minifies every document in a YAML stream. optsJSON holds
+// options in the canonical JSON form (see ParseOptions); fields it omits keep
+// their defaults and an empty string means DefaultOptions.
+//
+// MinifyString has no file or OS dependencies, making it a convenient entry
+// point for GOOS=js and wasip1 builds.
+func MinifyString(in string, optsJSON string) (string, error) {
+	opts, err := ParseOptions([]byte(optsJSON))
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	if _, err := NewDecoder(strings.NewReader(in), opts).WriteStream(&out, nil); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// MinifyBytes deduplicates every document in a YAML stream. Documents are
+// parsed straight into nodes rather than through Go values, so comments
+// (subject to Options.Comments), scalar and flow styles, tags, and key order
+// survive as they were. A comment above or beside a mapping key or sequence
+// item tunes deduplication of it:
+//
+//	# yamlmin:ignore           never anchor or alias anything in it
+//	# yamlmin:min-size=5       use MinSize 5 for everything in it
+//	# yamlmin:anchor-name=foo  name the anchor for it and its copies foo
+//
+// Text after a directive and a space is ignored, so it can give a reason.
+// Other words after "yamlmin:" are ordinary comments; a malformed directive
+// above, such as an invalid anchor name, is an error.
+//
+// Minifying is idempotent: given its own output and the same options,
+// MinifyBytes returns it byte for byte. Anchors already in the input keep
+// their names, new copies of their content become aliases to them, and new
+// anchors never reuse their names.
+func MinifyBytes(data []byte, opts Options) ([]byte, error) {
+	var out bytes.Buffer
+	if err := Minify(bytes.NewReader(data), &out, opts); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Minify reads a YAML stream from r and writes it to w with every document
+// deduplicated. Documents are written as they are processed, so only one is
+// held in memory at a time (unless Options.Passthrough is set, which reads the
+// whole stream first).
+func Minify(r io.Reader, w io.Writer, opts Options) error {
+	_, err := NewDecoder(r, opts).WriteStream(w, nil)
+	return err
+}
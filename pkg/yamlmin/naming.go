@@ -0,0 +1,228 @@
+package yamlmin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AnchorNaming selects how new anchors are named.
+type AnchorNaming string
+
+const (
+	// AnchorNamingCounter names anchors by kind and a counter: map1, list1,
+	// str1.
+	AnchorNamingCounter AnchorNaming = ""
+
+	// AnchorNamingField names an anchored mapping after the value of its
+	// first Options.AnchorNameKeys field, so a mapping holding
+	// "name: frontend-deployment" becomes &frontend-deployment. A node whose
+	// parent mapping has such a field is named after it and the node's key,
+	// like &frontend-deployment-resources. Nodes with neither, and names
+	// already used, fall back to counters.
+	AnchorNamingField AnchorNaming = "field"
+
+	// AnchorNamingHash names anchors by kind and the start of the anchored
+	// structure's fingerprint, like &map-4f2a9c, so the same structure gets
+	// the same name in every run and revision and adding or removing other
+	// duplicates leaves it alone. The hex part grows when two structures
+	// share a prefix.
+	AnchorNamingHash AnchorNaming = "hash"
+
+	// AnchorNamingContext names anchors after where the structure first
+	// appears: the mapping key holding it, like &resources, combined with
+	// its Options.AnchorNameKeys field when it has one, like &web-frontend.
+	// Items of a list use the singular of the list's key, so a container
+	// named nginx becomes &nginx-container. A name already used gets a
+	// numbered suffix (&resources-2), and structures with no key or name
+	// above them fall back to counters.
+	AnchorNamingContext AnchorNaming = "context"
+)
+
+// defaultAnchorNameKeys are the fields AnchorNamingField reads when
+// Options.AnchorNameKeys is empty.
+var defaultAnchorNameKeys = []string{"name", "id"}
+
+// nameKeys returns the fields opts names anchors after, or nil for counters.
+func nameKeys(opts Options) []string {
+	if opts.AnchorNaming != AnchorNamingField && opts.AnchorNaming != AnchorNamingContext {
+		return nil
+	}
+	if len(opts.AnchorNameKeys) == 0 {
+		return defaultAnchorNameKeys
+	}
+	return opts.AnchorNameKeys
+}
+
+func (n AnchorNaming) validate() error {
+	switch n {
+	case AnchorNamingCounter, AnchorNamingField, AnchorNamingHash, AnchorNamingContext:
+		return nil
+	}
+	return fmt.Errorf("unknown anchor naming %q", n)
+}
+
+// fieldName names an anchor on node after its name field, or its parent's,
+// per AnchorNamingField. It returns "" when neither has one or the name is
+// taken.
+func (df *duplicateFinder) fieldName(node *yaml.Node) string {
+	name := anchorSafe(df.nameField(node))
+	if name == "" {
+		if parent := df.parents[node]; parent != nil && parent.Kind == yaml.MappingNode {
+			if v := anchorSafe(df.nameField(parent)); v != "" {
+				for i := 1; i < len(parent.Content); i += 2 {
+					if parent.Content[i] == node {
+						name = anchorSafe(v + "-" + keyString(parent.Content[i-1]))
+						break
+					}
+				}
+			}
+		}
+	}
+	if name == "" || df.taken[name] {
+		return ""
+	}
+	df.taken[name] = true
+	return name
+}
+
+// minHashNameDigits is the fewest hex digits of a hash AnchorNamingHash
+// puts in a name.
+const minHashNameDigits = 6
+
+// hashName names an anchor after prefix and hash per AnchorNamingHash,
+// lengthening the hex part past names already taken. An identical structure
+// anchored twice gets a numbered suffix.
+func (df *duplicateFinder) hashName(prefix string, hash uint64) string {
+	hex := fmt.Sprintf("%016x", hash)
+	name := ""
+	for digits := minHashNameDigits; digits <= len(hex); digits++ {
+		if name = prefix + "-" + hex[:digits]; !df.taken[name] {
+			df.taken[name] = true
+			return name
+		}
+	}
+	for n := 2; ; n++ {
+		if numbered := name + "-" + strconv.Itoa(n); !df.taken[numbered] {
+			df.taken[numbered] = true
+			return numbered
+		}
+	}
+}
+
+// kindPrefix is the name prefix for anchors on node under
+// AnchorNamingHash, matching the counter names.
+func kindPrefix(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		return "list"
+	case yaml.MappingNode:
+		return "map"
+	case yaml.ScalarNode:
+		return "str"
+	}
+	return "anchor"
+}
+
+// structureName names an anchor yamlmin adds on node itself, such as a
+// merge base, after its hash per AnchorNamingHash, or else with prefix and
+// the next value of counter.
+func (df *duplicateFinder) structureName(node *yaml.Node, prefix string, counter *int) string {
+	if df.hashNames {
+		if hash, err := df.hashNode(node, 0); err == nil {
+			return df.hashName(prefix, hash)
+		}
+	}
+	return df.freshName(prefix, counter)
+}
+
+// contextName names an anchor on node per AnchorNamingContext, or returns
+// "" when nothing above or in node gives it a name.
+func (df *duplicateFinder) contextName(node *yaml.Node) string {
+	key, item := df.nearestKey(node)
+	if item {
+		key = singular(key)
+	}
+	name := anchorSafe(df.nameField(node))
+	switch key = anchorSafe(key); {
+	case name == "":
+		name = key
+	case key != "":
+		name += "-" + key
+	}
+	if name == "" {
+		return ""
+	}
+	numbered := name
+	for n := 2; df.taken[numbered]; n++ {
+		numbered = name + "-" + strconv.Itoa(n)
+	}
+	df.taken[numbered] = true
+	return numbered
+}
+
+// nearestKey returns the mapping key holding node, or holding the sequence
+// node is an item of, and whether node is such an item.
+func (df *duplicateFinder) nearestKey(node *yaml.Node) (key string, item bool) {
+	parent := df.parents[node]
+	if parent != nil && parent.Kind == yaml.SequenceNode {
+		node, parent, item = parent, df.parents[parent], true
+	}
+	if parent == nil || parent.Kind != yaml.MappingNode {
+		return "", false
+	}
+	for i := 1; i < len(parent.Content); i += 2 {
+		if parent.Content[i] == node {
+			return keyString(parent.Content[i-1]), item
+		}
+	}
+	return "", false
+}
+
+// singular turns a plural English key like containers or policies into its
+// singular, leaving anything else as it is.
+func singular(key string) string {
+	switch {
+	case strings.HasSuffix(key, "ies") && len(key) > 3:
+		return key[:len(key)-3] + "y"
+	case strings.HasSuffix(key, "s") && !strings.HasSuffix(key, "ss") && len(key) > 1:
+		return key[:len(key)-1]
+	}
+	return key
+}
+
+// nameField returns the scalar value of the first name key mapping holds,
+// or "".
+func (df *duplicateFinder) nameField(mapping *yaml.Node) string {
+	if mapping.Kind != yaml.MappingNode {
+		return ""
+	}
+	for _, key := range df.nameKeys {
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			if k, v := mapping.Content[i], mapping.Content[i+1]; k.Value == key && v.Kind == yaml.ScalarNode && v.Value != "" {
+				return v.Value
+			}
+		}
+	}
+	return ""
+}
+
+// anchorSafe turns s into an anchor name, replacing runs of anything but
+// letters, digits, and '_' with '-', as yaml.v3 accepts nothing else.
+func anchorSafe(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			b.WriteRune(r)
+			dash = false
+		case !dash && b.Len() > 0:
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
@@ -0,0 +1,99 @@
+package yamlmin
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Option sets one field of Options. Options start from DefaultOptions, so
+// only the settings a caller names change:
+//
+//	out, err := yamlmin.MarshalWith(v, yamlmin.WithMinSize(40), yamlmin.WithIndent(4))
+//
+// New settings get new Option functions, leaving existing calls untouched.
+type Option func(*Options)
+
+// NewOptions returns DefaultOptions with opts applied in order.
+func NewOptions(opts ...Option) Options {
+	o := DefaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// MarshalWith is MarshalWithOptions taking Option functions.
+func MarshalWith(in interface{}, opts ...Option) ([]byte, error) {
+	return MarshalWithOptions(in, NewOptions(opts...))
+}
+
+// K8sMarshalWith is K8sMarshalWithOptions taking Option functions.
+func K8sMarshalWith(in interface{}, opts ...Option) ([]byte, error) {
+	return K8sMarshalWithOptions(in, NewOptions(opts...))
+}
+
+// WithOptions replaces every setting with those of o, such as a Preset, so
+// later Options adjust o rather than the defaults.
+func WithOptions(o Options) Option {
+	return func(opts *Options) { *opts = o }
+}
+
+// WithMinOccurrences sets Options.MinOccurrences.
+func WithMinOccurrences(n int) Option {
+	return func(opts *Options) { opts.MinOccurrences = n }
+}
+
+// WithMinSize sets Options.MinSize.
+func WithMinSize(n int) Option {
+	return func(opts *Options) { opts.MinSize = n }
+}
+
+// WithIndent sets Options.Indent.
+func WithIndent(n int) Option {
+	return func(opts *Options) { opts.Indent = n }
+}
+
+// WithMaxDepth sets Options.MaxDepth.
+func WithMaxDepth(n int) Option {
+	return func(opts *Options) { opts.MaxDepth = n }
+}
+
+// WithMaxWidth sets Options.MaxWidth.
+func WithMaxWidth(n int) Option {
+	return func(opts *Options) { opts.MaxWidth = n }
+}
+
+// WithTimeLimit sets Options.TimeLimit.
+func WithTimeLimit(d time.Duration) Option {
+	return func(opts *Options) { opts.TimeLimit = d }
+}
+
+// WithScore sets Options.Score.
+func WithScore(score func(DuplicateGroup) float64) Option {
+	return func(opts *Options) { opts.Score = score }
+}
+
+// WithComments sets Options.Comments.
+func WithComments(mode CommentMode) Option {
+	return func(opts *Options) { opts.Comments = mode }
+}
+
+// WithYAMLVersion sets Options.YAMLVersion.
+func WithYAMLVersion(v YAMLVersion) Option {
+	return func(opts *Options) { opts.YAMLVersion = v }
+}
+
+// WithParallel sets Options.Parallel.
+func WithParallel(parallel bool) Option {
+	return func(opts *Options) { opts.Parallel = parallel }
+}
+
+// WithVerify sets Options.Verify.
+func WithVerify(verify bool) Option {
+	return func(opts *Options) { opts.Verify = verify }
+}
+
+// WithLogger sets Options.Logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(opts *Options) { opts.Logger = logger }
+}
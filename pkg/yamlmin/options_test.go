@@ -0,0 +1,797 @@
+package yamlmin_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMinOccurrencesByKind(t *testing.T) {
+	data := map[string]interface{}{
+		"a": map[string]string{"k": "repeated_map_value"},
+		"b": map[string]string{"k": "repeated_map_value"},
+		"c": "repeated_scalar_value",
+		"d": "repeated_scalar_value",
+	}
+
+	opts := yamlmin.DefaultOptions()
+	opts.MinSize = 5
+	opts.MinOccurrencesByKind = map[yaml.Kind]int{yaml.ScalarNode: 3}
+
+	out, err := yamlmin.MarshalWithOptions(data, opts)
+	require.NoError(t, err)
+	outputStr := string(out)
+
+	assert.Equal(t, 1, strings.Count(outputStr, "&map"))
+	assert.NotContains(t, outputStr, "&str")
+}
+
+func TestScore(t *testing.T) {
+	data := map[string]interface{}{
+		"a": map[string]string{"k": "repeated_value", "x": "one"},
+		"b": map[string]string{"k": "repeated_value", "x": "one"},
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		opts := yamlmin.DefaultOptions()
+		opts.MinSize = 5
+
+		out, err := yamlmin.MarshalWithOptions(data, opts)
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, strings.Count(string(out), "&map"))
+		assert.NotContains(t, string(out), "&str")
+	})
+
+	t.Run("PreferScalars", func(t *testing.T) {
+		opts := yamlmin.DefaultOptions()
+		opts.MinSize = 5
+		opts.Score = func(group yamlmin.DuplicateGroup) float64 {
+			if group.Kind == yaml.ScalarNode {
+				return 1
+			}
+			return 0
+		}
+
+		out, err := yamlmin.MarshalWithOptions(data, opts)
+		require.NoError(t, err)
+
+		assert.NotContains(t, string(out), "&map")
+		assert.Equal(t, 1, strings.Count(string(out), "&str"))
+	})
+}
+
+func TestCostScore(t *testing.T) {
+	small := map[string]string{"image": "nginx:1.25", "pull": "IfNotPresent"}
+	large := map[string]string{}
+	for _, k := range []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel", "india", "juliet"} {
+		large[k] = strings.Repeat(k, 30)
+	}
+	data := map[string]interface{}{"a": small, "b": small, "c": large, "d": large}
+
+	opts := yamlmin.DefaultOptions()
+	opts.Score = yamlmin.CostScore(yamlmin.YAMLCost)
+	out, err := yamlmin.MarshalWithOptions(data, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(out), "&map"))
+
+	// gzip already compresses the small repeat to a back-reference.
+	opts.Score = yamlmin.CostScore(yamlmin.GzipCost)
+	out, err = yamlmin.MarshalWithOptions(data, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(out), "&map"))
+	assert.Contains(t, string(out), "c: &map1")
+
+	group := yamlmin.DuplicateGroup{Kind: yaml.MappingNode, Occurrences: 2, Size: 20, Nodes: 5}
+	assert.Greater(t, yamlmin.CostScore(yamlmin.YAMLCost)(group), 0.0)
+	assert.LessOrEqual(t, yamlmin.CostScore(yamlmin.JSONRefCost)(group), 0.0)
+}
+
+func TestMaximizeSavings(t *testing.T) {
+	// The container occurs ten times, twice inside the same pod spec. Alone
+	// it saves more than the pod, but the pod is worth anchoring as well.
+	container := map[string]string{"image": "nginx:1.25", "pull": "IfNotPresent"}
+	pod := map[string]interface{}{
+		"container": container,
+		"command":   strings.Repeat("run ", 30),
+	}
+	data := map[string]interface{}{"pod1": pod, "pod2": pod}
+	for i := 0; i < 8; i++ {
+		data[fmt.Sprintf("c%d", i)] = container
+	}
+
+	opts := yamlmin.DefaultOptions()
+	opts.Score = yamlmin.SavingsScore
+	greedy, err := yamlmin.MarshalWithOptions(data, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(greedy), "&map"))
+
+	opts = yamlmin.DefaultOptions()
+	opts.MaximizeSavings = true
+	out, err := yamlmin.MarshalWithOptions(data, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(out), "&map"))
+	assert.Contains(t, string(out), "pod2: *map")
+	assert.Less(t, len(out), len(greedy))
+	equal, diff, err := yamlmin.Equivalent(greedy, out)
+	require.NoError(t, err)
+	assert.True(t, equal, diff.String())
+}
+
+func TestNoSequenceAnchors(t *testing.T) {
+	data := map[string]interface{}{
+		"a": []string{"repeated_list_item", "other_list_item"},
+		"b": []string{"repeated_list_item", "other_list_item"},
+	}
+
+	opts := yamlmin.DefaultOptions()
+	opts.MinSize = 5
+	opts.NoSequenceAnchors = true
+
+	out, err := yamlmin.MarshalWithOptions(data, opts)
+	require.NoError(t, err)
+	outputStr := string(out)
+
+	assert.NotContains(t, outputStr, "&list")
+	assert.Equal(t, 2, strings.Count(outputStr, "&str"))
+}
+
+func TestNoNestedAnchors(t *testing.T) {
+	input := `a:
+  x: {image: nginx, pull: IfNotPresent}
+  y: long string value here ok
+b:
+  x: {image: nginx, pull: IfNotPresent}
+  y: long string value here ok
+c: {image: nginx, pull: IfNotPresent}
+d: {image: nginx, pull: IfNotPresent}
+`
+	out, err := yamlmin.MinifyBytes([]byte(input), yamlmin.DefaultOptions())
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "x: &map2")
+
+	opts := yamlmin.DefaultOptions()
+	opts.NoNestedAnchors = true
+	out, err = yamlmin.MinifyBytes([]byte(input), opts)
+	require.NoError(t, err)
+	assert.Equal(t, `a: &map1
+  x: {image: nginx, pull: IfNotPresent}
+  y: long string value here ok
+b: *map1
+c: &map2 {image: nginx, pull: IfNotPresent}
+d: *map2
+`, string(out))
+
+	// Four copies of the container save more than two of its parent, which
+	// would then enclose its anchor.
+	opts.MaximizeSavings = true
+	out, err = yamlmin.MinifyBytes([]byte(input), opts)
+	require.NoError(t, err)
+	assert.Equal(t, `a:
+  x: &map1 {image: nginx, pull: IfNotPresent}
+  y: &str1 long string value here ok
+b:
+  x: *map1
+  y: *str1
+c: *map1
+d: *map1
+`, string(out))
+}
+
+func TestMultilineScalarsOnly(t *testing.T) {
+	data := map[string]interface{}{
+		"a": "repeated single line",
+		"b": "repeated single line",
+		"c": "repeated\nmulti line\n",
+		"d": "repeated\nmulti line\n",
+	}
+
+	opts := yamlmin.DefaultOptions()
+	opts.MinSize = 5
+	opts.MultilineScalarsOnly = true
+
+	out, err := yamlmin.MarshalWithOptions(data, opts)
+	require.NoError(t, err)
+	outputStr := string(out)
+
+	assert.Equal(t, 1, strings.Count(outputStr, "&str"))
+	assert.Equal(t, 2, strings.Count(outputStr, "repeated single line"))
+}
+
+func TestHoistScalars(t *testing.T) {
+	data := map[string]interface{}{
+		"a": "a long repeated string",
+		"b": map[string]string{"c": "a long repeated string"},
+	}
+
+	opts := yamlmin.DefaultOptions()
+	opts.MinSize = 5
+	opts.HoistScalars = true
+
+	out, err := yamlmin.MarshalWithOptions(data, opts)
+	require.NoError(t, err)
+
+	expected := `x-yamlmin-values:
+  v1: &str1 a long repeated string
+a: *str1
+b:
+  c: *str1
+`
+	assert.Equal(t, expected, string(out))
+}
+
+func TestSetKeys(t *testing.T) {
+	data := map[string]interface{}{
+		"a": map[string]interface{}{"finalizers": []string{"example.com/one", "example.com/two"}},
+		"b": map[string]interface{}{"finalizers": []string{"example.com/two", "example.com/one"}},
+	}
+
+	opts := yamlmin.DefaultOptions()
+	opts.SetKeys = []string{"finalizers"}
+
+	out, err := yamlmin.MarshalWithOptions(data, opts)
+	require.NoError(t, err)
+
+	expected := `a: &map1
+  finalizers:
+    - example.com/one
+    - example.com/two
+b: *map1
+`
+	assert.Equal(t, expected, string(out))
+}
+
+func TestSectionAnchorLimits(t *testing.T) {
+	data := map[string]interface{}{
+		"jobs": map[string]interface{}{
+			"a": []string{"the longest repeated string", "a short repeated str", "another repeated string"},
+			"b": []string{"the longest repeated string", "a short repeated str", "another repeated string", "x"},
+		},
+		"stages": []string{"one repeated stage name", "one repeated stage name"},
+	}
+
+	opts := yamlmin.DefaultOptions()
+	opts.SectionAnchorLimits = map[string]int{"jobs": 1}
+
+	out, err := yamlmin.MarshalWithOptions(data, opts)
+	require.NoError(t, err)
+	outputStr := string(out)
+
+	assert.Equal(t, 2, strings.Count(outputStr, "&str"), outputStr)
+	assert.Contains(t, outputStr, "- &str1 the longest repeated string")
+	// The strings left unanchored by the limit still use up their names.
+	assert.Contains(t, outputStr, "- &str4 one repeated stage name")
+	assert.Equal(t, 2, strings.Count(outputStr, "a short repeated str"))
+}
+
+func TestDedupKeys(t *testing.T) {
+	input := `images:
+  sha256:0123456789abcdef0123456789abcdef: app
+mirror:
+  sha256:0123456789abcdef0123456789abcdef: app-mirror
+ref: sha256:0123456789abcdef0123456789abcdef
+`
+	out, err := yamlmin.MinifyString(input, "")
+	require.NoError(t, err)
+	assert.Equal(t, input, out)
+
+	out, err = yamlmin.MinifyString(input, `{"DedupKeys": true}`)
+	require.NoError(t, err)
+	assert.Equal(t, `images:
+  &str1 sha256:0123456789abcdef0123456789abcdef: app
+mirror:
+  *str1 : app-mirror
+ref: *str1
+`, out)
+
+	var want, got interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(input), &want))
+	require.NoError(t, yaml.Unmarshal([]byte(out), &got))
+	assert.Equal(t, want, got)
+}
+
+func TestAnchorFingerprints(t *testing.T) {
+	input := "a:\n  x: a long repeated string\nb:\n  x: a long repeated string\nc: [a long repeated string]\n"
+
+	out, err := yamlmin.MinifyString(input, `{"AnchorFingerprints": true}`)
+	require.NoError(t, err)
+	lines := strings.Split(out, "\n")
+	require.Len(t, lines, 7, out)
+	assert.True(t, strings.HasPrefix(lines[0], yamlmin.FingerprintPrefix), out)
+	assert.Len(t, strings.TrimPrefix(lines[0], yamlmin.FingerprintPrefix), 16)
+	assert.Equal(t, "a: &map1", lines[1])
+
+	// The fingerprint depends on content only, not on where it appears.
+	out2, err := yamlmin.MinifyString("first: unrelated\n"+input, `{"AnchorFingerprints": true}`)
+	require.NoError(t, err)
+	assert.Contains(t, out2, lines[0]+"\na: &map1")
+
+	var want, got interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(input), &want))
+	require.NoError(t, yaml.Unmarshal([]byte(out), &got))
+	assert.Equal(t, want, got)
+}
+
+func TestYAMLVersion(t *testing.T) {
+	input := `a:
+  enabled: on
+  mode: the same longer value
+b:
+  enabled: "on"
+  mode: the same longer value
+perms: 0o755
+`
+	out, err := yamlmin.MinifyString(input, "")
+	require.NoError(t, err)
+	assert.Contains(t, out, "b: *map1", "without a version, on and \"on\" share an anchor")
+
+	out, err = yamlmin.MinifyString(input, `{"YAMLVersion": "1.1"}`)
+	require.NoError(t, err)
+	assert.Equal(t, `a:
+  enabled: on
+  mode: &str1 the same longer value
+b:
+  enabled: "on"
+  mode: *str1
+perms: "0o755"
+`, out)
+
+	out, err = yamlmin.MinifyString(input, `{"YAMLVersion": "1.2"}`)
+	require.NoError(t, err)
+	assert.Equal(t, `a: &map1
+  enabled: "on"
+  mode: the same longer value
+b: *map1
+perms: 0o755
+`, out)
+
+	_, err = yamlmin.MinifyString(input, `{"YAMLVersion": "1.3"}`)
+	assert.Error(t, err)
+}
+
+func TestMaxAliasDistance(t *testing.T) {
+	input := "a: a long repeated string\nb: a long repeated string\nc: 1\nd: 2\ne: 3\nf: a long repeated string\n"
+
+	out, err := yamlmin.MinifyString(input, `{"MaxAliasDistance": 5}`)
+	require.NoError(t, err)
+	assert.Equal(t, "a: &str1 a long repeated string\nb: *str1\nc: 1\nd: 2\ne: 3\nf: *str1\n", out)
+
+	out, err = yamlmin.MinifyString(input, `{"MaxAliasDistance": 4}`)
+	require.NoError(t, err)
+	assert.Equal(t, "a: &str1 a long repeated string\nb: *str1\nc: 1\nd: 2\ne: 3\nf: a long repeated string\n", out)
+
+	out, err = yamlmin.MinifyString("a: a long repeated string\nc: 1\nb: a long repeated string\n", `{"MaxAliasDistance": 1}`)
+	require.NoError(t, err)
+	assert.Equal(t, "a: a long repeated string\nc: 1\nb: a long repeated string\n", out)
+}
+
+func TestComments(t *testing.T) {
+	input := `a:
+  x: a long repeated string # first
+b:
+  x: a long repeated string # second
+l:
+  - a long repeated string # third
+`
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"", `a: &map1
+  x: &str1 a long repeated string # first
+b: *map1
+l:
+  - *str1
+`},
+		{"strict", input},
+		{"keep", `a: &map1
+  x: &str1 a long repeated string # first
+b: *map1
+l:
+  - *str1 # third
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			out, err := yamlmin.MinifyString(input, `{"Comments": "`+tt.mode+`"}`)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, out)
+		})
+	}
+
+	_, err := yamlmin.MinifyString(input, `{"Comments": "bogus"}`)
+	assert.Error(t, err)
+}
+
+func TestOptionsJSON(t *testing.T) {
+	opts := yamlmin.DefaultOptions()
+	opts.TimeLimit = 5 * time.Second
+	opts.MinOccurrencesByKind = map[yaml.Kind]int{yaml.ScalarNode: 3}
+	opts.Comments = yamlmin.CommentsKeep
+
+	data, err := json.Marshal(opts)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"minOccurrences": 2, "minOccurrencesByKind": {"scalar": 3}, "minSize": 20, "indent": 2,
+		"maxDepth": 50, "maxWidth": 10000, "timeLimit": "5s", "comments": "keep"}`, string(data))
+
+	back, err := yamlmin.ParseOptions(data)
+	require.NoError(t, err)
+	assert.Equal(t, opts, back)
+
+	// Omitted fields keep their defaults, and names match case-insensitively.
+	parsed, err := yamlmin.ParseOptions([]byte(`{"MinSize": 40, "timeLimit": 1000, "select": "kind==ConfigMap"}`))
+	require.NoError(t, err)
+	assert.Equal(t, 40, parsed.MinSize)
+	assert.Equal(t, 2, parsed.MinOccurrences)
+	assert.Equal(t, time.Microsecond, parsed.TimeLimit)
+	require.NotNil(t, parsed.Select)
+
+	for _, bad := range []string{
+		`{"minSze": 40}`,
+		`{"minSize": -1}`,
+		`{"timeLimit": "soon"}`,
+		`{"comments": "some"}`,
+		`{"yamlVersion": "1.3"}`,
+		`{"refMode": "graphql"}`,
+		`{"minOccurrencesByKind": {"alias": 2}}`,
+		`{"select": "kind"}`,
+	} {
+		_, err := yamlmin.ParseOptions([]byte(bad))
+		assert.Error(t, err, bad)
+	}
+	// Several bad settings always report the same first one.
+	bad := yamlmin.Options{MinSize: -1, Indent: -1, MaxDepth: -1, SectionAnchorLimits: map[string]int{"b": -1, "a": -1}}
+	for i := 0; i < 10; i++ {
+		assert.EqualError(t, bad.Validate(), "minSize must not be negative")
+	}
+	bad = yamlmin.Options{SectionAnchorLimits: map[string]int{"b": -1, "a": -1, "c": -1}}
+	for i := 0; i < 10; i++ {
+		assert.EqualError(t, bad.Validate(), "sectionAnchorLimits: a must not be negative")
+	}
+}
+
+func TestOptionFuncs(t *testing.T) {
+	opts := yamlmin.NewOptions(yamlmin.WithMinSize(40), yamlmin.WithMinOccurrences(3), yamlmin.WithIndent(4))
+	want := yamlmin.DefaultOptions()
+	want.MinSize, want.MinOccurrences, want.Indent = 40, 3, 4
+	assert.Equal(t, want, opts)
+
+	carvel, err := yamlmin.Preset("carvel")
+	require.NoError(t, err)
+	opts = yamlmin.NewOptions(yamlmin.WithOptions(carvel), yamlmin.WithIndent(4))
+	assert.Equal(t, yamlmin.CommentsStrict, opts.Comments)
+	assert.Equal(t, 4, opts.Indent)
+
+	data := map[string]string{"a": "a long repeated string", "b": "a long repeated string"}
+	out, err := yamlmin.MarshalWith(data, yamlmin.WithMinOccurrences(3))
+	require.NoError(t, err)
+	assert.Equal(t, "a: a long repeated string\nb: a long repeated string\n", string(out))
+
+	out, err = yamlmin.K8sMarshalWith(data)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "*str1")
+}
+
+func TestStrict(t *testing.T) {
+	parse := func(s string) *yaml.Node {
+		var doc yaml.Node
+		require.NoError(t, yaml.Unmarshal([]byte(s), &doc))
+		return &doc
+	}
+	opts := yamlmin.DefaultOptions()
+	opts.Strict = true
+
+	require.NoError(t, yamlmin.ProcessNode(parse("a: &x {k: a long repeated string}\nb: *x\n"), opts))
+
+	dangling := parse("a: [one, two]\nb: {c: d}\n")
+	dangling.Content[0].Content[3].Content[1] = &yaml.Node{Kind: yaml.AliasNode, Value: "x"}
+	unknown := parse("a: [one, two]\n")
+	unknown.Content[0].Content[1].Content[1].Kind = 42
+
+	for _, tt := range []struct {
+		root *yaml.Node
+		want yamlmin.UnsupportedNodeError
+	}{
+		{dangling, yamlmin.UnsupportedNodeError{Path: ".b.c", Kind: yaml.AliasNode, Reason: "alias *x has no target"}},
+		{unknown, yamlmin.UnsupportedNodeError{Path: ".a[1]", Kind: 42, Reason: "unknown kind"}},
+	} {
+		err := yamlmin.ProcessNode(tt.root, opts)
+		var unsupported *yamlmin.UnsupportedNodeError
+		require.ErrorAs(t, err, &unsupported)
+		assert.Equal(t, tt.want, *unsupported)
+
+		lenient := opts
+		lenient.Strict = false
+		assert.NoError(t, yamlmin.ProcessNode(tt.root, lenient))
+	}
+	assert.EqualError(t, yamlmin.ProcessNode(unknown, opts), ".a[1]: unsupported kind 42 node: unknown kind")
+}
+
+func TestDecisions(t *testing.T) {
+	input := "a: {image: nginx, pull: IfNotPresent}\nb: {image: nginx, pull: IfNotPresent}\n" +
+		"c: a long repeated string\nd: a long repeated string\n"
+	var doc yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(input), &doc))
+	report, err := yamlmin.Analyze(&doc, yamlmin.DefaultOptions())
+	require.NoError(t, err)
+	require.Len(t, report.Candidates, 2)
+	mapping, str := report.Candidates[0].Hash, report.Candidates[1].Hash
+
+	opts := yamlmin.DefaultOptions()
+	opts.Decisions = yamlmin.Decisions{
+		mapping: {Action: yamlmin.ActionReject},
+		str:     {Action: yamlmin.ActionAccept, Name: "text"},
+	}
+	out, err := yamlmin.MinifyBytes([]byte(input), opts)
+	require.NoError(t, err)
+	assert.Equal(t, "a: {image: nginx, pull: IfNotPresent}\nb: {image: nginx, pull: IfNotPresent}\n"+
+		"c: &text a long repeated string\nd: *text\n", string(out))
+
+	data, err := json.Marshal(opts)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), fmt.Sprintf(`"%016x":{"action":"accept","name":"text"}`, str))
+	parsed, err := yamlmin.ParseOptions(data)
+	require.NoError(t, err)
+	assert.Equal(t, opts.Decisions, parsed.Decisions)
+
+	_, err = yamlmin.ParseOptions([]byte(`{"decisions": {"00000000000000ff": {"action": "maybe"}}}`))
+	assert.ErrorContains(t, err, "unknown action")
+	for _, name := range []string{"foo:bar", "a*b", "web.v1"} {
+		_, err = yamlmin.ParseOptions([]byte(`{"decisions": {"00000000000000ff": {"action": "accept", "name": "` + name + `"}}}`))
+		assert.ErrorContains(t, err, "invalid anchor name", name)
+		opts.Decisions = yamlmin.Decisions{str: {Action: yamlmin.ActionAccept, Name: name}}
+		_, err = yamlmin.MinifyBytes([]byte(input), opts)
+		assert.ErrorContains(t, err, "invalid anchor name", name)
+	}
+
+	// A decided name is held for its group even when a counter would reach
+	// it first.
+	opts.Decisions = yamlmin.Decisions{str: {Action: yamlmin.ActionAccept, Name: "map1"}}
+	out, err = yamlmin.MinifyBytes([]byte(input), opts)
+	require.NoError(t, err)
+	assert.Equal(t, "a: &map2 {image: nginx, pull: IfNotPresent}\nb: *map2\n"+
+		"c: &map1 a long repeated string\nd: *map1\n", string(out))
+}
+
+func TestAnchorNaming(t *testing.T) {
+	input := `deployments:
+  - {name: frontend deployment, image: nginx, replicas: 3}
+  - {name: frontend deployment, image: nginx, replicas: 3}
+services:
+  - {id: 7, port: 8080, protocol: TCP, target: http}
+  - {id: 7, port: 8080, protocol: TCP, target: http}
+probes:
+  - {path: /healthz, port: 8080, period: 10}
+  - {path: /healthz, port: 8080, period: 10}
+`
+	opts := yamlmin.DefaultOptions()
+	opts.AnchorNaming = yamlmin.AnchorNamingField
+	out, err := yamlmin.MinifyBytes([]byte(input), opts)
+	require.NoError(t, err)
+	assert.Equal(t, `deployments:
+  - &frontend-deployment {name: frontend deployment, image: nginx, replicas: 3}
+  - *frontend-deployment
+services:
+  - &7 {id: 7, port: 8080, protocol: TCP, target: http}
+  - *7
+probes:
+  - &map1 {path: /healthz, port: 8080, period: 10}
+  - *map1
+`, string(out))
+
+	// A name already in use falls back to a counter.
+	out, err = yamlmin.MinifyBytes([]byte("base: &frontend {x: 1}\n"+
+		"a: {name: frontend, image: nginx, replicas: 3}\nb: {name: frontend, image: nginx, replicas: 3}\n"), opts)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "a: &map1 {name: frontend")
+
+	out, err = yamlmin.MinifyBytes([]byte("a: {name: web.example.com, image: nginx, replicas: 3}\n"+
+		"b: {name: web.example.com, image: nginx, replicas: 3}\n"), opts)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "a: &web-example-com {name: web.example.com")
+
+	// A name field matching a counter name already handed out falls back to
+	// the next counter, rather than anchoring a second &map1.
+	input = `a:
+  x: {image: nginx, pull: IfNotPresent}
+b:
+  x: {name: map1, p: 1111111111, q: 2222222222}
+c:
+  x: {image: nginx, pull: IfNotPresent}
+  y: {name: map1, p: 1111111111, q: 2222222222}
+`
+	out, err = yamlmin.MinifyBytes([]byte(input), opts)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "x: &map2 {name: map1")
+	equal, diff, err := yamlmin.Equivalent([]byte(input), out)
+	require.NoError(t, err)
+	assert.True(t, equal, diff)
+
+	data, err := json.Marshal(opts)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"anchorNaming":"field"`)
+	_, err = yamlmin.ParseOptions([]byte(`{"anchorNaming": "random"}`))
+	assert.ErrorContains(t, err, "unknown anchor naming")
+}
+
+func TestHashAnchorNaming(t *testing.T) {
+	block := "{image: 'nginx:latest', pull: IfNotPresent}"
+	fp, err := yamlmin.FingerprintBytes([]byte(block))
+	require.NoError(t, err)
+	name := "map-" + fmt.Sprintf("%016x", fp)[:6]
+
+	opts := yamlmin.DefaultOptions()
+	opts.AnchorNaming = yamlmin.AnchorNamingHash
+	input := "a: " + block + "\nb: " + block + "\n"
+	out, err := yamlmin.MinifyBytes([]byte(input), opts)
+	require.NoError(t, err)
+	assert.Equal(t, "a: &"+name+" "+block+"\nb: *"+name+"\n", string(out))
+
+	// Other duplicates coming and going leave the name alone.
+	more := "z: {image: 'redis:latest', pull: IfNotPresent}\nzz: {image: 'redis:latest', pull: IfNotPresent}\n"
+	out, err = yamlmin.MinifyBytes([]byte(more+input), opts)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "a: &"+name+" ")
+
+	// A name the input already uses gets a longer hash.
+	taken := "x: &" + name + " something else\ny: *" + name + "\n"
+	out, err = yamlmin.MinifyBytes([]byte(taken+input), opts)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "a: &"+name+fmt.Sprintf("%016x", fp)[6:7]+" ")
+
+	var o yamlmin.Options
+	require.NoError(t, json.Unmarshal([]byte(`{"anchorNaming": "hash"}`), &o))
+	assert.Equal(t, yamlmin.AnchorNamingHash, o.AnchorNaming)
+}
+
+func TestFoldWidth(t *testing.T) {
+	input := `serve: python -m app.server --host 0.0.0.0 --port 8080 --workers 4 --log-level debug
+canary: python -m app.server --host 0.0.0.0 --port 8080 --workers 4 --log-level debug
+url: https://example.com/a/long/path/without/any/spaces/that/goes/on/and/on/and/on
+short: a few words
+a long mapping key that stays on its line however long it gets: 1
+`
+	opts := yamlmin.DefaultOptions()
+	opts.FoldWidth = 40
+	out, err := yamlmin.MinifyBytes([]byte(input), opts)
+	require.NoError(t, err)
+	assert.Equal(t, `serve: &str1 >-
+  python -m app.server --host 0.0.0.0
+  --port 8080 --workers 4 --log-level
+  debug
+canary: *str1
+url: https://example.com/a/long/path/without/any/spaces/that/goes/on/and/on/and/on
+short: a few words
+a long mapping key that stays on its line however long it gets: 1
+`, string(out))
+
+	var want, got interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(input), &want))
+	require.NoError(t, yaml.Unmarshal(out, &got))
+	assert.Equal(t, want, got)
+
+	again, err := yamlmin.MinifyBytes(out, opts)
+	require.NoError(t, err)
+	assert.Equal(t, string(out), string(again))
+
+	_, err = yamlmin.ParseOptions([]byte(`{"foldWidth": -1}`))
+	assert.ErrorContains(t, err, "foldWidth must not be negative")
+}
+
+func TestMaxAliasRatio(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 6; i++ {
+		fmt.Fprintf(&b, "svc%d:\n  resources: {cpu: 500m, memory: 256Mi, storage: 1Gi}\n  probe: {path: /healthz, port: 8080}\n  owner: platform-team-%d\n", i, i)
+	}
+	input := b.String()
+
+	ratio := func(out []byte) float64 {
+		var root yaml.Node
+		require.NoError(t, yaml.Unmarshal(out, &root))
+		nodes, aliases := 0, 0
+		var walk func(n *yaml.Node)
+		walk = func(n *yaml.Node) {
+			nodes++
+			if n.Kind == yaml.AliasNode {
+				aliases++
+			}
+			for _, c := range n.Content {
+				walk(c)
+			}
+		}
+		walk(&root)
+		return float64(aliases) / float64(nodes)
+	}
+
+	opts := yamlmin.DefaultOptions()
+	unlimited, err := yamlmin.MinifyBytes([]byte(input), opts)
+	require.NoError(t, err)
+	require.Equal(t, 10, strings.Count(string(unlimited), "*map"))
+	require.Greater(t, ratio(unlimited), 0.1)
+
+	for _, savings := range []bool{false, true} {
+		opts.MaxAliasRatio = 0.1
+		opts.MaximizeSavings = savings
+		out, err := yamlmin.MinifyBytes([]byte(input), opts)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, ratio(out), 0.1, "savings=%v", savings)
+		assert.Equal(t, 5, strings.Count(string(out), "*map"), "savings=%v", savings)
+		equal, diff, err := yamlmin.Equivalent([]byte(input), out)
+		require.NoError(t, err)
+		assert.True(t, equal, diff.String())
+	}
+
+	_, err = yamlmin.ParseOptions([]byte(`{"maxAliasRatio": 1.5}`))
+	assert.ErrorContains(t, err, "maxAliasRatio must be between 0 and 1")
+}
+
+func TestContextAnchorNaming(t *testing.T) {
+	input := `web:
+  containers:
+    - {name: nginx, image: 'nginx:1.25', pull: IfNotPresent}
+    - {name: web}
+  resources: {cpu: 500m, memory: 256Mi, storage: 1Gi}
+api:
+  containers:
+    - {name: nginx, image: 'nginx:1.25', pull: IfNotPresent}
+    - {name: api}
+  resources: {cpu: 500m, memory: 256Mi, storage: 1Gi}
+worker:
+  resources: {cpu: 250m, memory: 128Mi, storage: 1Gi}
+  queue: work
+batch:
+  resources: {cpu: 250m, memory: 128Mi, storage: 1Gi}
+  queue: batch
+`
+	opts := yamlmin.DefaultOptions()
+	opts.AnchorNaming = yamlmin.AnchorNamingContext
+	out, err := yamlmin.MinifyBytes([]byte(input), opts)
+	require.NoError(t, err)
+	assert.Equal(t, `web:
+  containers:
+    - &nginx-container {name: nginx, image: 'nginx:1.25', pull: IfNotPresent}
+    - {name: web}
+  resources: &resources {cpu: 500m, memory: 256Mi, storage: 1Gi}
+api:
+  containers:
+    - *nginx-container
+    - {name: api}
+  resources: *resources
+worker:
+  resources: &resources-2 {cpu: 250m, memory: 128Mi, storage: 1Gi}
+  queue: work
+batch:
+  resources: *resources-2
+  queue: batch
+`, string(out))
+
+	again, err := yamlmin.MinifyBytes(out, opts)
+	require.NoError(t, err)
+	assert.Equal(t, string(out), string(again))
+
+	// Structures with no key above them fall back to counters.
+	out, err = yamlmin.MinifyBytes([]byte("- [alpha-value, beta-value, gamma-value]\n- [alpha-value, beta-value, gamma-value]\n"), opts)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "&list1 ")
+}
+
+func TestContextAnchorNamingFixture(t *testing.T) {
+	data, err := os.ReadFile("testdata/fixture.yaml")
+	require.NoError(t, err)
+	opts := yamlmin.DefaultOptions()
+	opts.AnchorNaming = yamlmin.AnchorNamingContext
+	out, err := yamlmin.MinifyBytes(data, opts)
+	require.NoError(t, err)
+
+	equal, diff, err := yamlmin.Equivalent(data, out)
+	require.NoError(t, err)
+	assert.True(t, equal, diff)
+}
@@ -0,0 +1,314 @@
+package yamlmin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// optionsJSON is the canonical JSON encoding of Options, shared by
+// MinifyString, the server, the C bindings, and configuration files. Names
+// are the Options field names in lowerCamelCase; decoding matches them
+// case-insensitively, so "MinSize" works too.
+type optionsJSON struct {
+	MinOccurrences       int            `json:"minOccurrences"`
+	MinOccurrencesByKind map[string]int `json:"minOccurrencesByKind,omitempty"`
+	MinSize              int            `json:"minSize"`
+	Indent               int            `json:"indent"`
+	FoldWidth            int            `json:"foldWidth,omitempty"`
+	MaxDepth             int            `json:"maxDepth"`
+	MaxWidth             int            `json:"maxWidth"`
+	TimeLimit            jsonDuration   `json:"timeLimit,omitempty"`
+	NoSequenceAnchors    bool           `json:"noSequenceAnchors,omitempty"`
+	NoNestedAnchors      bool           `json:"noNestedAnchors,omitempty"`
+	MultilineScalarsOnly bool           `json:"multilineScalarsOnly,omitempty"`
+	HoistScalars         bool           `json:"hoistScalars,omitempty"`
+	HoistKey             string         `json:"hoistKey,omitempty"`
+	SetKeys              []string       `json:"setKeys,omitempty"`
+	MergeSubsets         bool           `json:"mergeSubsets,omitempty"`
+	SequenceRuns         bool           `json:"sequenceRuns,omitempty"`
+	DedupKeys            bool           `json:"dedupKeys,omitempty"`
+	YAMLVersion          YAMLVersion    `json:"yamlVersion,omitempty"`
+	Parallel             bool           `json:"parallel,omitempty"`
+	MaximizeSavings      bool           `json:"maximizeSavings,omitempty"`
+	MaxAliasDistance     int            `json:"maxAliasDistance,omitempty"`
+	MaxAliasRatio        float64        `json:"maxAliasRatio,omitempty"`
+	Comments             CommentMode    `json:"comments,omitempty"`
+	AnchorFingerprints   bool           `json:"anchorFingerprints,omitempty"`
+	SectionAnchorLimits  map[string]int `json:"sectionAnchorLimits,omitempty"`
+	ExcludeKeys          []string       `json:"excludeKeys,omitempty"`
+	ScopeKeys            []string       `json:"scopeKeys,omitempty"`
+	AnchorNaming         AnchorNaming   `json:"anchorNaming,omitempty"`
+	AnchorNameKeys       []string       `json:"anchorNameKeys,omitempty"`
+	RefMode              RefMode        `json:"refMode,omitempty"`
+	Select               string         `json:"select,omitempty"`
+	Passthrough          bool           `json:"passthrough,omitempty"`
+	ConcatSafe           bool           `json:"concatSafe,omitempty"`
+	UniqueAnchors        bool           `json:"uniqueAnchors,omitempty"`
+	MaxOutputBytes       int            `json:"maxOutputBytes,omitempty"`
+	Verify               bool           `json:"verify,omitempty"`
+	Strict               bool           `json:"strict,omitempty"`
+	Decisions            Decisions      `json:"decisions,omitempty"`
+}
+
+// jsonDuration is a time.Duration written as a Go duration string ("10s").
+// Decoding also accepts a number of nanoseconds.
+type jsonDuration time.Duration
+
+func (d jsonDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("duration must be a string like \"10s\" or nanoseconds")
+		}
+		*d = jsonDuration(n)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = jsonDuration(v)
+	return nil
+}
+
+var kindNames = map[yaml.Kind]string{
+	yaml.MappingNode:  "mapping",
+	yaml.SequenceNode: "sequence",
+	yaml.ScalarNode:   "scalar",
+}
+
+// MarshalJSON encodes o in the canonical JSON form. Score, Select, and Logger
+// are functions or handles and are left out; a selector can still be given
+// as a ParseSelector expression when decoding.
+func (o Options) MarshalJSON() ([]byte, error) {
+	j := optionsJSON{
+		MinOccurrences:       o.MinOccurrences,
+		MinSize:              o.MinSize,
+		Indent:               o.Indent,
+		FoldWidth:            o.FoldWidth,
+		MaxDepth:             o.MaxDepth,
+		MaxWidth:             o.MaxWidth,
+		TimeLimit:            jsonDuration(o.TimeLimit),
+		NoSequenceAnchors:    o.NoSequenceAnchors,
+		NoNestedAnchors:      o.NoNestedAnchors,
+		MultilineScalarsOnly: o.MultilineScalarsOnly,
+		HoistScalars:         o.HoistScalars,
+		HoistKey:             o.HoistKey,
+		SetKeys:              o.SetKeys,
+		MergeSubsets:         o.MergeSubsets,
+		SequenceRuns:         o.SequenceRuns,
+		DedupKeys:            o.DedupKeys,
+		YAMLVersion:          o.YAMLVersion,
+		Parallel:             o.Parallel,
+		MaximizeSavings:      o.MaximizeSavings,
+		MaxAliasDistance:     o.MaxAliasDistance,
+		MaxAliasRatio:        o.MaxAliasRatio,
+		Comments:             o.Comments,
+		AnchorFingerprints:   o.AnchorFingerprints,
+		SectionAnchorLimits:  o.SectionAnchorLimits,
+		ExcludeKeys:          o.ExcludeKeys,
+		ScopeKeys:            o.ScopeKeys,
+		AnchorNaming:         o.AnchorNaming,
+		AnchorNameKeys:       o.AnchorNameKeys,
+		RefMode:              o.RefMode,
+		Passthrough:          o.Passthrough,
+		ConcatSafe:           o.ConcatSafe,
+		UniqueAnchors:        o.UniqueAnchors,
+		MaxOutputBytes:       o.MaxOutputBytes,
+		Verify:               o.Verify,
+		Strict:               o.Strict,
+		Decisions:            o.Decisions,
+	}
+	if len(o.MinOccurrencesByKind) > 0 {
+		j.MinOccurrencesByKind = make(map[string]int, len(o.MinOccurrencesByKind))
+		for kind, n := range o.MinOccurrencesByKind {
+			name, ok := kindNames[kind]
+			if !ok {
+				name = strconv.Itoa(int(kind))
+			}
+			j.MinOccurrencesByKind[name] = n
+		}
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes the canonical JSON form into o. Fields the JSON
+// omits keep their current values, so decode into DefaultOptions() (or a
+// preset) to fill in defaults. Unknown fields are rejected and the result is
+// validated.
+func (o *Options) UnmarshalJSON(data []byte) error {
+	var j optionsJSON
+	current, err := o.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(current, &j); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&j); err != nil {
+		return err
+	}
+
+	opts := *o
+	opts.MinOccurrences = j.MinOccurrences
+	opts.MinSize = j.MinSize
+	opts.Indent = j.Indent
+	opts.FoldWidth = j.FoldWidth
+	opts.MaxDepth = j.MaxDepth
+	opts.MaxWidth = j.MaxWidth
+	opts.TimeLimit = time.Duration(j.TimeLimit)
+	opts.NoSequenceAnchors = j.NoSequenceAnchors
+	opts.NoNestedAnchors = j.NoNestedAnchors
+	opts.MultilineScalarsOnly = j.MultilineScalarsOnly
+	opts.HoistScalars = j.HoistScalars
+	opts.HoistKey = j.HoistKey
+	opts.SetKeys = j.SetKeys
+	opts.MergeSubsets = j.MergeSubsets
+	opts.SequenceRuns = j.SequenceRuns
+	opts.DedupKeys = j.DedupKeys
+	opts.YAMLVersion = j.YAMLVersion
+	opts.Parallel = j.Parallel
+	opts.MaximizeSavings = j.MaximizeSavings
+	opts.MaxAliasDistance = j.MaxAliasDistance
+	opts.MaxAliasRatio = j.MaxAliasRatio
+	opts.Comments = j.Comments
+	opts.AnchorFingerprints = j.AnchorFingerprints
+	opts.SectionAnchorLimits = j.SectionAnchorLimits
+	opts.ExcludeKeys = j.ExcludeKeys
+	opts.ScopeKeys = j.ScopeKeys
+	opts.AnchorNaming = j.AnchorNaming
+	opts.AnchorNameKeys = j.AnchorNameKeys
+	opts.RefMode = j.RefMode
+	opts.Passthrough = j.Passthrough
+	opts.ConcatSafe = j.ConcatSafe
+	opts.UniqueAnchors = j.UniqueAnchors
+	opts.MaxOutputBytes = j.MaxOutputBytes
+	opts.Verify = j.Verify
+	opts.Strict = j.Strict
+	opts.Decisions = j.Decisions
+
+	opts.MinOccurrencesByKind = nil
+	if len(j.MinOccurrencesByKind) > 0 {
+		opts.MinOccurrencesByKind = make(map[yaml.Kind]int, len(j.MinOccurrencesByKind))
+		for name, n := range j.MinOccurrencesByKind {
+			kind, err := parseKind(name)
+			if err != nil {
+				return err
+			}
+			opts.MinOccurrencesByKind[kind] = n
+		}
+	}
+	if j.Select != "" {
+		if opts.Select, err = ParseSelector(j.Select); err != nil {
+			return err
+		}
+	}
+
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	*o = opts
+	return nil
+}
+
+func parseKind(name string) (yaml.Kind, error) {
+	for kind, n := range kindNames {
+		if n == name {
+			return kind, nil
+		}
+	}
+	return 0, fmt.Errorf("minOccurrencesByKind: unknown kind %q, want mapping, sequence, or scalar", name)
+}
+
+// ParseOptions decodes options in the canonical JSON form, starting from
+// DefaultOptions. Empty input gives the defaults.
+func ParseOptions(data []byte) (Options, error) {
+	opts := DefaultOptions()
+	if len(bytes.TrimSpace(data)) == 0 {
+		return opts, nil
+	}
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return Options{}, fmt.Errorf("parsing options: %w", err)
+	}
+	return opts, nil
+}
+
+// Validate reports the first invalid setting in o. Zero values are valid and
+// mean the documented default.
+func (o Options) Validate() error {
+	for _, field := range []struct {
+		name string
+		n    int
+	}{
+		{"minOccurrences", o.MinOccurrences},
+		{"minSize", o.MinSize},
+		{"indent", o.Indent},
+		{"foldWidth", o.FoldWidth},
+		{"maxDepth", o.MaxDepth},
+		{"maxWidth", o.MaxWidth},
+		{"maxAliasDistance", o.MaxAliasDistance},
+		{"maxOutputBytes", o.MaxOutputBytes},
+	} {
+		if field.n < 0 {
+			return fmt.Errorf("%s must not be negative", field.name)
+		}
+	}
+	if o.TimeLimit < 0 {
+		return fmt.Errorf("timeLimit must not be negative")
+	}
+	if o.MaxAliasRatio < 0 || o.MaxAliasRatio > 1 {
+		return fmt.Errorf("maxAliasRatio must be between 0 and 1")
+	}
+	kinds := make([]yaml.Kind, 0, len(o.MinOccurrencesByKind))
+	for kind := range o.MinOccurrencesByKind {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	for _, kind := range kinds {
+		if _, ok := kindNames[kind]; !ok {
+			return fmt.Errorf("minOccurrencesByKind: unsupported kind %d", kind)
+		}
+		if o.MinOccurrencesByKind[kind] < 0 {
+			return fmt.Errorf("minOccurrencesByKind: %s must not be negative", kindNames[kind])
+		}
+	}
+	keys := make([]string, 0, len(o.SectionAnchorLimits))
+	for key := range o.SectionAnchorLimits {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if o.SectionAnchorLimits[key] < 0 {
+			return fmt.Errorf("sectionAnchorLimits: %s must not be negative", key)
+		}
+	}
+	switch o.YAMLVersion {
+	case YAMLVersionNone, YAML11, YAML12:
+	default:
+		return fmt.Errorf("unknown YAML version %q", o.YAMLVersion)
+	}
+	if err := o.AnchorNaming.validate(); err != nil {
+		return err
+	}
+	if err := o.Decisions.validate(); err != nil {
+		return err
+	}
+	if _, ok := refLayouts[o.RefMode]; !ok && o.RefMode != RefModeNone {
+		return fmt.Errorf("unknown ref mode %q", o.RefMode)
+	}
+	return o.Comments.validate()
+}
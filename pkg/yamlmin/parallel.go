@@ -0,0 +1,78 @@
+package yamlmin
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// candidateNode is a node selected by scanNode for hashing.
+type candidateNode struct {
+	node  *yaml.Node
+	depth int
+}
+
+// indexCandidates hashes the candidates found by scanNode and groups them by
+// hash. Both steps may run in parallel: hashing splits the candidates between
+// workers, and grouping gives each worker its own shards of nodesByHash.
+// Every worker visits candidates in document order, so hashOrder and each
+// bucket are the same in either mode.
+func (df *duplicateFinder) indexCandidates() {
+	df.hashed = len(df.candidates)
+	hashes := make([]uint64, len(df.candidates))
+	ok := make([]bool, len(df.candidates))
+	first := make([]bool, len(df.candidates)) // first occurrence of its hash
+
+	workers := runtime.GOMAXPROCS(0)
+	if !df.parallel || workers < 2 || len(df.candidates) < 2 {
+		workers = 1
+	}
+	run := func(n int, work func(w int)) {
+		if n == 1 {
+			work(0)
+			return
+		}
+		var wg sync.WaitGroup
+		for w := range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				work(w)
+			}()
+		}
+		wg.Wait()
+	}
+
+	var next atomic.Int64
+	run(workers, func(int) {
+		for i := int(next.Add(1) - 1); i < len(df.candidates); i = int(next.Add(1) - 1) {
+			c := df.candidates[i]
+			// If hashing fails (due to limits), we just skip this node as a duplicate candidate
+			if h, err := df.hashNode(c.node, c.depth); err == nil {
+				hashes[i], ok[i] = h, true
+			}
+		}
+	})
+
+	owners := min(workers, 1<<hashShardBits)
+	run(owners, func(w int) {
+		for i, c := range df.candidates {
+			if !ok[i] || shardOf(hashes[i])%owners != w {
+				continue
+			}
+			nodes, seen := df.nodesByHash.get(hashes[i])
+			first[i] = !seen
+			df.nodesByHash.set(hashes[i], append(nodes, c.node))
+		}
+	})
+
+	for i := range df.candidates {
+		if first[i] {
+			df.hashOrder = append(df.hashOrder, hashes[i])
+		}
+	}
+	clear(df.candidates)
+	df.candidates = df.candidates[:0]
+}
@@ -0,0 +1,43 @@
+package yamlmin_test
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParallelMatchesSerial(t *testing.T) {
+	data, err := os.ReadFile("testdata/fixture.yaml")
+	require.NoError(t, err)
+	var value interface{}
+	require.NoError(t, yaml.Unmarshal(data, &value))
+
+	merge := yamlmin.DefaultOptions()
+	merge.MergeSubsets = true
+	variants := map[string]yamlmin.Options{"default": yamlmin.DefaultOptions(), "merge": merge}
+
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+	for name, opts := range variants {
+		runtime.GOMAXPROCS(1)
+		want, err := yamlmin.MarshalWithOptions(value, opts)
+		require.NoError(t, err)
+
+		opts.Parallel = true
+		for _, procs := range []int{1, 2, 3, 4, 8, 16} {
+			t.Run(fmt.Sprintf("%s/GOMAXPROCS=%d", name, procs), func(t *testing.T) {
+				runtime.GOMAXPROCS(procs)
+				for range 3 {
+					got, err := yamlmin.MarshalWithOptions(value, opts)
+					require.NoError(t, err)
+					assert.Equal(t, string(want), string(got))
+				}
+			})
+		}
+	}
+}
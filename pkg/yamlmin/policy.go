@@ -0,0 +1,172 @@
+package yamlmin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy declares requirements that minified documents must meet, typically
+// loaded from a policy.yaml checked into the repository:
+//
+//	minReduction: 15          # percent saved versus the expanded documents
+//	maxDocumentBytes: 921600  # per document
+//	forbidAnchors: [.data]    # paths, as accepted by Query
+type Policy struct {
+	// MinReduction is the minimum size reduction, in percent, of the stream
+	// compared with the same documents with every alias expanded. Zero
+	// disables the check.
+	MinReduction float64 `yaml:"minReduction"`
+
+	// MaxDocumentBytes is the maximum size of any single document. Zero
+	// disables the check.
+	MaxDocumentBytes int `yaml:"maxDocumentBytes"`
+
+	// ForbidAnchors lists paths under which anchors, aliases, and merge keys
+	// are not allowed, for consumers that read those sections literally.
+	ForbidAnchors []string `yaml:"forbidAnchors"`
+}
+
+// LoadPolicy parses a policy file. Unknown fields are rejected so that typos
+// don't silently disable a requirement.
+func LoadPolicy(data []byte) (Policy, error) {
+	var p Policy
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil && !errors.Is(err, io.EOF) {
+		return Policy{}, fmt.Errorf("parsing policy: %w", err)
+	}
+	for _, path := range p.ForbidAnchors {
+		if _, err := parsePath(path); err != nil {
+			return Policy{}, fmt.Errorf("parsing policy: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// Check evaluates every document in data against p and returns the
+// violations found, in document order.
+func (p Policy) Check(data []byte) ([]Violation, error) {
+	var violations []Violation
+	expanded := 0
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for n := 1; ; n++ {
+		var root yaml.Node
+		err := dec.Decode(&root)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing YAML: %w", err)
+		}
+
+		size, err := encodedSize(&root)
+		if err != nil {
+			return nil, err
+		}
+		if p.MaxDocumentBytes > 0 && size > p.MaxDocumentBytes {
+			violations = append(violations, Violation{
+				Rule: RuleMaxDocumentBytes, Document: n,
+				Line: root.Line, Column: root.Column,
+				Message: fmt.Sprintf("document %d is %d bytes, over the policy maximum of %d", n, size, p.MaxDocumentBytes),
+			})
+		}
+
+		for _, path := range p.ForbidAnchors {
+			violations = checkForbidden(&root, path, n, violations)
+		}
+
+		if p.MinReduction > 0 {
+			full, err := Query(&root, ".")
+			if err != nil {
+				return nil, err
+			}
+			size, err := encodedSize(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{full}})
+			if err != nil {
+				return nil, err
+			}
+			expanded += size
+		}
+	}
+
+	if p.MinReduction > 0 && expanded > 0 {
+		reduction := 100.0 * (1.0 - float64(len(data))/float64(expanded))
+		if reduction < p.MinReduction {
+			violations = append(violations, Violation{
+				Rule:    RuleMinReduction,
+				Message: fmt.Sprintf("reduction %.1f%% is below the policy minimum of %g%%", reduction, p.MinReduction),
+			})
+		}
+	}
+	return violations, nil
+}
+
+func encodedSize(root *yaml.Node) (int, error) {
+	out, err := encodeNode(root, DefaultOptions())
+	if err != nil {
+		return 0, err
+	}
+	return len(out), nil
+}
+
+// checkForbidden reports anchors, aliases, and merge keys at or below path.
+// Paths are followed through explicit keys only, so content reached through
+// an alias is reported at the alias rather than at its anchor.
+func checkForbidden(root *yaml.Node, path string, doc int, violations []Violation) []Violation {
+	steps, _ := parsePath(path)
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, step := range steps {
+		node = literalStep(node, step)
+		if node == nil {
+			return violations
+		}
+	}
+
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		add := func(format string, args ...interface{}) {
+			violations = append(violations, Violation{
+				Rule: RuleForbidAnchors, Document: doc,
+				Line: n.Line, Column: n.Column,
+				Message: fmt.Sprintf("%s under %s is forbidden by policy", fmt.Sprintf(format, args...), path),
+			})
+		}
+		switch {
+		case n.Anchor != "":
+			add("anchor &%s", n.Anchor)
+		case n.Kind == yaml.AliasNode:
+			add("alias *%s", n.Value)
+		case isMergeKey(n):
+			add("merge key <<")
+		}
+		for _, child := range n.Content {
+			walk(child)
+		}
+	}
+	walk(node)
+	return violations
+}
+
+// literalStep applies one path step without resolving aliases or merges.
+func literalStep(node *yaml.Node, step pathStep) *yaml.Node {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		if step.isIndex && step.index < len(node.Content) {
+			return node.Content[step.index]
+		}
+	case yaml.MappingNode:
+		for i := 0; !step.isIndex && i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == step.key {
+				return node.Content[i+1]
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package yamlmin_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy(t *testing.T) {
+	_, err := yamlmin.LoadPolicy([]byte("minReduction: 15\ntypo: 1\n"))
+	assert.Error(t, err)
+	_, err = yamlmin.LoadPolicy([]byte("forbidAnchors: [data]\n"))
+	assert.Error(t, err)
+
+	policy, err := yamlmin.LoadPolicy([]byte("minReduction: 15\nmaxDocumentBytes: 60\nforbidAnchors: [.data]\n"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "pass",
+			input: "a: &a\n  k: a long enough value to be worth sharing\nb: *a\n",
+		},
+		{
+			name:  "anchor under forbidden path",
+			input: "data: &d\n  k: a long enough value to be worth sharing\nb: *d\n",
+			want:  []string{"ForbidAnchors/1 1:7: anchor &d under .data is forbidden by policy"},
+		},
+		{
+			name:  "document too large",
+			input: "a: &a\n  k: a long enough value to be worth sharing\nb: *a\n---\nc: " + strings.Repeat("x", 60) + "\n",
+			want:  []string{"MaxDocumentBytes/2 4:1: document 2 is 64 bytes, over the policy maximum of 60"},
+		},
+		{
+			name:  "no reduction",
+			input: "a: 1\n",
+			want:  []string{"MinReduction/0 reduction 0.0% is below the policy minimum of 15%"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := policy.Check([]byte(tt.input))
+			require.NoError(t, err)
+			var got []string
+			for _, v := range violations {
+				got = append(got, fmt.Sprintf("%s/%d %s", v.Rule, v.Document, v))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
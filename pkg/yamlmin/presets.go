@@ -0,0 +1,95 @@
+package yamlmin
+
+import (
+	"fmt"
+	"sort"
+)
+
+// presets maps preset names to functions returning their options.
+var presets = map[string]func() Options{
+	"default": DefaultOptions,
+	"openapi": func() Options {
+		opts := DefaultOptions()
+		opts.RefMode = RefModeOpenAPI
+		return opts
+	},
+	"swagger": func() Options {
+		opts := DefaultOptions()
+		opts.RefMode = RefModeSwagger
+		return opts
+	},
+	// carvel suits ytt and kapp. ytt reads "#@" comments as annotations, so
+	// comments are content; a higher size floor keeps small incidental
+	// duplicates from coming and going between renders and renumbering the
+	// anchors kapp diffs show.
+	"carvel": func() Options {
+		opts := DefaultOptions()
+		opts.Comments = CommentsStrict
+		opts.MinSize = 40
+		return opts
+	},
+	// circleci collects shared strings under a top-level "aliases" key, the
+	// conventional home for anchors in CircleCI configs, which CircleCI
+	// ignores.
+	"circleci": func() Options {
+		opts := DefaultOptions()
+		opts.HoistScalars = true
+		opts.HoistKey = "aliases"
+		return opts
+	},
+	// serverless suits serverless.yml. "serverless plugin install" and
+	// dashboard setup rewrite the plugins, org, and app keys in place.
+	"serverless": func() Options {
+		opts := DefaultOptions()
+		opts.ExcludeKeys = []string{"plugins", "org", "app"}
+		return opts
+	},
+	// sam suits AWS SAM templates. CloudFormation itself rejects aliases, so
+	// minified templates must go through the SAM CLI, which resolves them
+	// before deploying. Transform and Metadata are read by the SAM translator,
+	// "sam publish", and visual editors, so they stay as written.
+	"sam": func() Options {
+		opts := DefaultOptions()
+		opts.ExcludeKeys = []string{"AWSTemplateFormatVersion", "Transform", "Metadata"}
+		return opts
+	},
+	// helm-values suits Helm values files. Only the blocks values files
+	// repeat per environment or per service are anchored, so chart-wide
+	// settings stay literal where "helm --set" users look for them, and
+	// anchors are named after the block's name field.
+	"helm-values": func() Options {
+		opts := DefaultOptions()
+		opts.ScopeKeys = []string{
+			"environments", "envs", "services", "components", "apps",
+			"deployments", "statefulsets", "workers", "jobs", "cronjobs",
+		}
+		opts.AnchorNaming = AnchorNamingField
+		opts.AnchorNameKeys = []string{"name"}
+		return opts
+	},
+	"asyncapi": func() Options {
+		opts := DefaultOptions()
+		opts.RefMode = RefModeAsyncAPI
+		return opts
+	},
+}
+
+// Preset returns options tuned for a named document type, such as "openapi"
+// or "asyncapi". See Presets for the available names.
+func Preset(name string) (Options, error) {
+	preset, ok := presets[name]
+	if !ok {
+		return Options{}, fmt.Errorf("unknown preset %q", name)
+	}
+	return preset(), nil
+}
+
+// Presets returns the names of all available presets in sorted order.
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,289 @@
+package yamlmin
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrPathNotFound is returned by Query when the path does not exist.
+var ErrPathNotFound = errors.New("path not found")
+
+// maxQueryNodes bounds the size of a Query result, so a small document built
+// from nested aliases cannot expand without limit.
+const maxQueryNodes = 1 << 20
+
+// Query returns the node at path in root, looking through aliases and "<<"
+// merge keys as if the document were fully expanded.
+//
+// A path is a sequence of ".key" and "[index]" steps, e.g.
+// ".spec.template.containers[0]"; keys containing dots, brackets, or quotes,
+// and empty keys, can be written as ["key"], with any '"' or '\' in them
+// escaped by a '\'. The path "." selects root itself.
+//
+// The result is a copy in which every alias is replaced by its content and
+// merge keys are flattened, so it can be encoded on its own. root is not
+// modified.
+func Query(root *yaml.Node, path string) (*yaml.Node, error) {
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	node := resolveAlias(root)
+	if node != nil && node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = resolveAlias(node.Content[0])
+	}
+	for i, step := range steps {
+		if node == nil {
+			return nil, fmt.Errorf("%w: %s", ErrPathNotFound, formatPath(steps[:i+1]))
+		}
+		next, err := queryStep(node, step)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", formatPath(steps[:i+1]), err)
+		}
+		node = next
+	}
+
+	return expandCopy(node, &expansionBudget{nodes: maxQueryNodes, bytes: math.MaxInt})
+}
+
+// pathStep is a single mapping key or sequence index in a query path.
+type pathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func parsePath(path string) ([]pathStep, error) {
+	if path == "" || path == "." {
+		return nil, nil
+	}
+	var steps []pathStep
+	rest := path
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, `["`):
+			key, n, ok := unquoteKey(rest[2:])
+			if !ok {
+				return nil, fmt.Errorf("invalid path %q: unterminated key", path)
+			}
+			steps = append(steps, pathStep{key: key})
+			rest = rest[2+n:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unterminated index", path)
+			}
+			n, err := strconv.Atoi(rest[1:end])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid path %q: bad index %q", path, rest[1:end])
+			}
+			steps = append(steps, pathStep{index: n, isIndex: true})
+			rest = rest[end+1:]
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			if end == 0 {
+				if len(rest) > 1 && rest[1] == '[' {
+					rest = rest[1:]
+					continue
+				}
+				return nil, fmt.Errorf("invalid path %q: empty key", path)
+			}
+			steps = append(steps, pathStep{key: rest[1 : end+1]})
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid path %q: expected '.' or '['", path)
+		}
+	}
+	return steps, nil
+}
+
+// unquoteKey reads a bracketed key from s, which follows its opening `["`,
+// up to the closing `"]`. A '\' before '"' or '\' escapes it; other
+// backslashes are kept as they are. It returns the key and the length of s
+// it used, closing `"]` included.
+func unquoteKey(s string) (string, int, bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\'):
+			i++
+			b.WriteByte(s[i])
+		case c == '"' && i+1 < len(s) && s[i+1] == ']':
+			return b.String(), i + 2, true
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, false
+}
+
+// ChildPath returns the Query path of parent.Content[i] given path, the path
+// of parent, which is "" for a document's root. For a mapping, i indexes a
+// value, and the step names its key.
+func ChildPath(path string, parent *yaml.Node, i int) string {
+	if parent.Kind == yaml.SequenceNode {
+		return path + formatPath([]pathStep{{index: i, isIndex: true}})
+	}
+	return path + formatPath([]pathStep{{key: keyString(parent.Content[i-1])}})
+}
+
+// keyEscaper escapes a key written as ["key"] in a path.
+var keyEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func formatPath(steps []pathStep) string {
+	var b strings.Builder
+	for _, s := range steps {
+		switch {
+		case s.isIndex:
+			fmt.Fprintf(&b, "[%d]", s.index)
+		case s.key == "" || strings.ContainsAny(s.key, `.[]"`):
+			b.WriteString(`["` + keyEscaper.Replace(s.key) + `"]`)
+		default:
+			b.WriteString("." + s.key)
+		}
+	}
+	return b.String()
+}
+
+// queryStep applies one step to an alias-resolved node.
+func queryStep(node *yaml.Node, step pathStep) (*yaml.Node, error) {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		if !step.isIndex {
+			return nil, fmt.Errorf("%w: key on a sequence", ErrPathNotFound)
+		}
+		if step.index >= len(node.Content) {
+			return nil, fmt.Errorf("%w: index out of range", ErrPathNotFound)
+		}
+		return resolveAlias(node.Content[step.index]), nil
+	case yaml.MappingNode:
+		if step.isIndex {
+			return nil, fmt.Errorf("%w: index on a mapping", ErrPathNotFound)
+		}
+		if value := mergedLookup(node, step.key, 0); value != nil {
+			return value, nil
+		}
+		return nil, ErrPathNotFound
+	default:
+		return nil, fmt.Errorf("%w: not a collection", ErrPathNotFound)
+	}
+}
+
+// mergedLookup finds key in a mapping, falling back to its merge sources in
+// order as YAML merge keys specify.
+func mergedLookup(node *yaml.Node, key string, depth int) *yaml.Node {
+	if depth > maxMergeDepth {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if k := resolveAlias(node.Content[i]); !isMergeKey(k) && k.Value == key {
+			return resolveAlias(node.Content[i+1])
+		}
+	}
+	for _, src := range mergeSources(node) {
+		if value := mergedLookup(src, key, depth+1); value != nil {
+			return value
+		}
+	}
+	return nil
+}
+
+// maxMergeDepth bounds merge-key chains, which could otherwise be cyclic.
+const maxMergeDepth = 64
+
+// mergeSources returns the mappings merged into node by "<<" keys.
+func mergeSources(node *yaml.Node) []*yaml.Node {
+	var sources []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if !isMergeKey(resolveAlias(node.Content[i])) {
+			continue
+		}
+		value := resolveAlias(node.Content[i+1])
+		switch value.Kind {
+		case yaml.MappingNode:
+			sources = append(sources, value)
+		case yaml.SequenceNode:
+			for _, item := range value.Content {
+				if item = resolveAlias(item); item.Kind == yaml.MappingNode {
+					sources = append(sources, item)
+				}
+			}
+		}
+	}
+	return sources
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for i := 0; node != nil && node.Kind == yaml.AliasNode && i < maxMergeDepth; i++ {
+		node = node.Alias
+	}
+	return node
+}
+
+// expandCopy deep-copies node with aliases replaced by their content, anchors
+// dropped, and merge keys flattened into explicit pairs.
+func expandCopy(node *yaml.Node, budget *expansionBudget) (*yaml.Node, error) {
+	node = resolveAlias(node)
+	if node == nil {
+		return nil, nil
+	}
+	if err := budget.spend(node); err != nil {
+		return nil, err
+	}
+
+	out := *node
+	out.Anchor = ""
+	out.Alias = nil
+	out.Content = nil
+
+	pairs := node.Content
+	if node.Kind == yaml.MappingNode {
+		pairs = flattenMerges(node, 0)
+	}
+	for _, child := range pairs {
+		c, err := expandCopy(child, budget)
+		if err != nil {
+			return nil, err
+		}
+		out.Content = append(out.Content, c)
+	}
+	return &out, nil
+}
+
+// flattenMerges returns a mapping's key/value pairs with merged pairs that
+// are not overridden appended after the explicit ones.
+func flattenMerges(node *yaml.Node, depth int) []*yaml.Node {
+	var pairs []*yaml.Node
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := resolveAlias(node.Content[i])
+		if isMergeKey(key) {
+			continue
+		}
+		seen[key.Value] = true
+		pairs = append(pairs, node.Content[i], node.Content[i+1])
+	}
+	if depth > maxMergeDepth {
+		return pairs
+	}
+	for _, src := range mergeSources(node) {
+		merged := flattenMerges(src, depth+1)
+		for i := 0; i+1 < len(merged); i += 2 {
+			key := resolveAlias(merged[i])
+			if !seen[key.Value] {
+				seen[key.Value] = true
+				pairs = append(pairs, merged[i], merged[i+1])
+			}
+		}
+	}
+	return pairs
+}
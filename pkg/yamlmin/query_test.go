@@ -0,0 +1,84 @@
+package yamlmin_test
+
+import (
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestQuery(t *testing.T) {
+	input := `base: &b
+  image: nginx
+  port: 80
+spec:
+  template:
+    <<: *b
+    port: 8080
+  containers: [*b]
+  "a.b": dotted
+  'say "hi"': quoted
+  "": empty
+  'back\slash.': slashed
+`
+	var root yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(input), &root))
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{".", ""},
+		{".spec.template", "port: 8080\nimage: nginx\n"},
+		{".spec.template.image", "nginx\n"},
+		{".spec.containers[0]", "image: nginx\nport: 80\n"},
+		{".spec.containers[0].port", "80\n"},
+		{`.spec["a.b"]`, "dotted\n"},
+		{`.spec["say \"hi\""]`, "quoted\n"},
+		{`.spec[""]`, "empty\n"},
+		{`.spec["back\\slash."]`, "slashed\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			node, err := yamlmin.Query(&root, tt.path)
+			require.NoError(t, err)
+			out, err := yaml.Marshal(node)
+			require.NoError(t, err)
+			if tt.want != "" {
+				assert.Equal(t, tt.want, string(out))
+			}
+			assert.NotContains(t, string(out), "*b")
+		})
+	}
+
+	for _, path := range []string{".nope", ".spec.containers[1]", ".base[0]", ".spec.template.port.x"} {
+		_, err := yamlmin.Query(&root, path)
+		assert.ErrorIs(t, err, yamlmin.ErrPathNotFound, path)
+	}
+
+	_, err := yamlmin.Query(&root, "spec")
+	assert.Error(t, err)
+}
+
+func TestPathsRoundTrip(t *testing.T) {
+	a := "labels:\n  app.kubernetes.io/name: web\n  'say \"hi\"': x\n"
+	b := "labels:\n  app.kubernetes.io/name: api\n  'say \"hi\"': y\n"
+	equal, diff, err := yamlmin.Equivalent([]byte(a), []byte(b))
+	require.NoError(t, err)
+	assert.False(t, equal)
+	assert.Equal(t, `.labels["app.kubernetes.io/name"]`, diff.Path)
+
+	var root yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(a), &root))
+	for _, path := range []string{`.labels["app.kubernetes.io/name"]`, `.labels["say \"hi\""]`} {
+		node, err := yamlmin.Query(&root, path)
+		require.NoError(t, err, path)
+		assert.Equal(t, yaml.ScalarNode, node.Kind, path)
+	}
+
+	labels := root.Content[0].Content[1]
+	assert.Equal(t, `.labels["say \"hi\""]`, yamlmin.ChildPath(".labels", labels, 3))
+	assert.Equal(t, ".labels[1]", yamlmin.ChildPath(".labels", &yaml.Node{Kind: yaml.SequenceNode}, 1))
+}
@@ -0,0 +1,41 @@
+package yamlmin
+
+import "gopkg.in/yaml.v3"
+
+// countAliases records how many nodes and aliases root holds before
+// deduplication selects anything, for Options.MaxAliasRatio.
+func (df *duplicateFinder) countAliases(root *yaml.Node) {
+	df.docNodes, df.docAliases = 0, 0
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		df.docNodes++
+		if node.Kind == yaml.AliasNode {
+			df.docAliases++
+		}
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+	walk(root)
+}
+
+// aliasCost returns the aliases that selecting a group with live
+// occurrences adds, and the nodes its copies stop contributing.
+func (df *duplicateFinder) aliasCost(live []*yaml.Node) (aliases, shrink int) {
+	if len(live) < 2 {
+		return 0, 0
+	}
+	for _, n := range live[1:] {
+		if n.Kind != yaml.AliasNode {
+			aliases++
+			shrink += df.countNodes(n, 0) - 1
+		}
+	}
+	return aliases, shrink
+}
+
+// overAliasRatio reports whether the document's aliases and aliases more,
+// among its nodes less shrink, exceed Options.MaxAliasRatio.
+func (df *duplicateFinder) overAliasRatio(aliases, shrink int) bool {
+	return df.maxAliasRatio > 0 && float64(df.docAliases+aliases) > df.maxAliasRatio*float64(df.docNodes-shrink)
+}
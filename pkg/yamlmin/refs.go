@@ -0,0 +1,448 @@
+package yamlmin
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RefMode selects spec-aware deduplication, which hoists duplicate fragments
+// into the spec's definitions section and replaces them with $ref objects
+// instead of YAML anchors. Output in a RefMode contains no anchors.
+type RefMode string
+
+const (
+	// RefModeNone deduplicates with anchors and aliases.
+	RefModeNone RefMode = ""
+
+	// RefModeOpenAPI hoists duplicate schemas of an OpenAPI 3 document into
+	// components/schemas.
+	RefModeOpenAPI RefMode = "openapi"
+
+	// RefModeSwagger hoists duplicate schemas, parameters, and responses of a
+	// Swagger 2.0 document into definitions, parameters, and responses.
+	RefModeSwagger RefMode = "swagger"
+
+	// RefModeAsyncAPI hoists duplicate messages, schemas, and channels of an
+	// AsyncAPI document into components/messages, components/schemas, and
+	// components/channels.
+	RefModeAsyncAPI RefMode = "asyncapi"
+)
+
+// refSection describes one kind of reusable definition in a spec.
+type refSection struct {
+	path   []string // location of the named definitions
+	prefix string   // prefix for generated names
+	match  func(ctx refContext) bool
+}
+
+// refContext describes where a node sits in its document.
+type refContext struct {
+	key       string // mapping key holding the node, or the key holding its sequence
+	parentKey string // mapping key holding the node's parent mapping
+	inList    bool   // the node is a sequence item
+}
+
+func isSchema(ctx refContext) bool {
+	if ctx.inList {
+		return ctx.key == "allOf" || ctx.key == "anyOf" || ctx.key == "oneOf"
+	}
+	switch ctx.key {
+	case "schema", "items", "additionalProperties", "not":
+		return true
+	}
+	return ctx.parentKey == "properties"
+}
+
+func isParameter(ctx refContext) bool {
+	return ctx.inList && ctx.key == "parameters"
+}
+
+func isResponse(ctx refContext) bool {
+	return !ctx.inList && ctx.parentKey == "responses"
+}
+
+func isMessage(ctx refContext) bool {
+	return !ctx.inList && (ctx.key == "message" || ctx.parentKey == "messages")
+}
+
+func isPayload(ctx refContext) bool {
+	return (!ctx.inList && ctx.key == "payload") || isSchema(ctx)
+}
+
+func isChannel(ctx refContext) bool {
+	return !ctx.inList && ctx.parentKey == "channels"
+}
+
+var refLayouts = map[RefMode][]refSection{
+	RefModeOpenAPI: {
+		{path: []string{"components", "schemas"}, prefix: "Schema", match: isSchema},
+	},
+	RefModeSwagger: {
+		{path: []string{"definitions"}, prefix: "Definition", match: isSchema},
+		{path: []string{"parameters"}, prefix: "Parameter", match: isParameter},
+		{path: []string{"responses"}, prefix: "Response", match: isResponse},
+	},
+	RefModeAsyncAPI: {
+		{path: []string{"components", "messages"}, prefix: "Message", match: isMessage},
+		{path: []string{"components", "schemas"}, prefix: "Schema", match: isPayload},
+		{path: []string{"components", "channels"}, prefix: "Channel", match: isChannel},
+	},
+}
+
+// refOccurrence is a candidate fragment and the slot holding it.
+type refOccurrence struct {
+	doc     int
+	parent  *yaml.Node
+	index   int
+	node    *yaml.Node
+	section int
+	name    string // set when the node is a named definition
+}
+
+type refGroup struct {
+	section     int
+	occurrences []*refOccurrence
+	size        int
+	nodes       int
+}
+
+// refIndex collects candidate fragments across one or more documents.
+type refIndex struct {
+	df       *duplicateFinder
+	sections []refSection
+	parents  map[*yaml.Node]*yaml.Node
+	groups   map[uint64]*refGroup
+	order    []*refGroup
+}
+
+func (df *duplicateFinder) newRefIndex(mode RefMode) (*refIndex, error) {
+	sections, ok := refLayouts[mode]
+	if !ok {
+		return nil, fmt.Errorf("unknown ref mode %q", mode)
+	}
+	return &refIndex{
+		df:       df,
+		sections: sections,
+		parents:  make(map[*yaml.Node]*yaml.Node),
+		groups:   make(map[uint64]*refGroup),
+	}, nil
+}
+
+func documentRoot(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		return node.Content[0]
+	}
+	return node
+}
+
+// lookupPath returns the node at path in a mapping, creating mappings when
+// create is set.
+func lookupPath(root *yaml.Node, path []string, create bool) *yaml.Node {
+	node := documentRoot(root)
+	for _, key := range path {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		if next == nil {
+			if !create {
+				return nil
+			}
+			next = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, next)
+		}
+		node = next
+	}
+	return node
+}
+
+// add indexes every candidate fragment in doc.
+func (idx *refIndex) add(doc int, root *yaml.Node) {
+	definitions := make(map[*yaml.Node]int)
+	for i, s := range idx.sections {
+		if c := lookupPath(root, s.path, false); c != nil && c.Kind == yaml.MappingNode {
+			definitions[c] = i
+		}
+	}
+	idx.walk(doc, documentRoot(root), refContext{}, 0, definitions)
+}
+
+func (idx *refIndex) walk(doc int, node *yaml.Node, ctx refContext, depth int, definitions map[*yaml.Node]int) {
+	if depth > idx.df.maxDepth || idx.df.isDeadlineExceeded() {
+		return
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		section, isDefinitions := definitions[node]
+		for i := 1; i < len(node.Content); i += 2 {
+			child := node.Content[i]
+			idx.parents[child] = node
+			childCtx := refContext{key: node.Content[i-1].Value, parentKey: ctx.key}
+			if isDefinitions {
+				idx.record(&refOccurrence{doc: doc, parent: node, index: i, node: child, section: section, name: node.Content[i-1].Value})
+			} else {
+				idx.consider(doc, node, i, childCtx)
+			}
+			idx.walk(doc, child, childCtx, depth+1, definitions)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			idx.parents[child] = node
+			childCtx := refContext{key: ctx.key, parentKey: ctx.parentKey, inList: true}
+			idx.consider(doc, node, i, childCtx)
+			idx.walk(doc, child, childCtx, depth+1, definitions)
+		}
+	}
+}
+
+func (idx *refIndex) consider(doc int, parent *yaml.Node, index int, ctx refContext) {
+	node := parent.Content[index]
+	if node.Kind != yaml.MappingNode || isRef(node) {
+		return
+	}
+	for i, s := range idx.sections {
+		if s.match(ctx) {
+			idx.record(&refOccurrence{doc: doc, parent: parent, index: index, node: node, section: i})
+			return
+		}
+	}
+}
+
+func (idx *refIndex) record(occ *refOccurrence) {
+	if occ.node.Kind != yaml.MappingNode || isRef(occ.node) {
+		return
+	}
+	hash, err := idx.df.hashNode(occ.node, 0)
+	if err != nil {
+		return
+	}
+	key := hash ^ uint64(occ.section)
+	g, ok := idx.groups[key]
+	if !ok {
+		g = &refGroup{section: occ.section, size: idx.df.estimateSize(occ.node, 0), nodes: idx.df.countNodes(occ.node, 0)}
+		idx.groups[key] = g
+		idx.order = append(idx.order, g)
+	}
+	g.occurrences = append(g.occurrences, occ)
+}
+
+// hasLocalRef reports whether node holds a $ref into its own document, like
+// "#/components/schemas/Error".
+func hasLocalRef(node *yaml.Node) bool {
+	if ref := mappingValue(node, "$ref"); ref != nil && strings.HasPrefix(ref.Value, "#") {
+		return true
+	}
+	for _, child := range node.Content {
+		if hasLocalRef(child) {
+			return true
+		}
+	}
+	return false
+}
+
+func isRef(node *yaml.Node) bool {
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Value == "$ref" {
+			return true
+		}
+	}
+	return false
+}
+
+// hoist replaces duplicate fragments with $ref objects pointing at definitions
+// in target (document index targetDoc, or -1 for a separate document).
+// refPrefix is prepended to the JSON pointer of every generated $ref.
+// Fragments must appear in at least minDocs documents. A separate target
+// takes no fragments holding local $refs, which would not resolve there.
+func (idx *refIndex) hoist(target *yaml.Node, targetDoc int, refPrefix string, minDocs int) {
+	sort.SliceStable(idx.order, func(i, j int) bool { return idx.order[i].size > idx.order[j].size })
+
+	removed := make(map[*yaml.Node]bool)
+	isLive := func(n *yaml.Node) bool {
+		for p := n; p != nil; p = idx.parents[p] {
+			if removed[p] {
+				return false
+			}
+		}
+		return true
+	}
+
+	names := make(map[int]map[string]bool)
+	for i, s := range idx.sections {
+		names[i] = make(map[string]bool)
+		if c := lookupPath(target, s.path, false); c != nil {
+			for j := 0; j < len(c.Content); j += 2 {
+				names[i][c.Content[j].Value] = true
+			}
+		}
+	}
+
+	minOcc := idx.df.minOccurrencesFor(yaml.MappingNode)
+	for _, g := range idx.order {
+		var live []*refOccurrence
+		var definition *refOccurrence
+		docs := make(map[int]bool)
+		for _, occ := range g.occurrences {
+			if !isLive(occ.node) {
+				continue
+			}
+			if occ.name != "" && occ.doc == targetDoc && definition == nil {
+				definition = occ
+				continue
+			}
+			live = append(live, occ)
+			docs[occ.doc] = true
+		}
+
+		if definition == nil {
+			if len(live) < minOcc || len(docs) < minDocs || g.size < idx.df.minSize {
+				continue
+			}
+			if targetDoc < 0 && hasLocalRef(live[0].node) {
+				continue
+			}
+			group := DuplicateGroup{Kind: yaml.MappingNode, Occurrences: len(live), Size: g.size, Nodes: g.nodes}
+			if idx.df.score(group) <= 0 {
+				continue
+			}
+		} else if len(live) == 0 {
+			continue
+		}
+
+		section := idx.sections[g.section]
+		var name string
+		if definition != nil {
+			name = definition.name
+		} else {
+			name = idx.nameFor(g.section, live, names[g.section])
+			names[g.section][name] = true
+			container := lookupPath(target, section.path, true)
+			container.Content = append(container.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name},
+				live[0].node,
+			)
+			idx.parents[live[0].node] = container
+		}
+
+		ref := refPrefix + "#/" + strings.Join(section.path, "/") + "/" + jsonPointerEscape(name)
+		for _, occ := range live {
+			occ.parent.Content[occ.index] = refNode(ref)
+			if definition != nil || occ != live[0] {
+				removed[occ.node] = true
+			}
+		}
+	}
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// nameFor picks a definition name: an existing definition name, then a title,
+// then a generated name.
+func (idx *refIndex) nameFor(section int, occurrences []*refOccurrence, taken map[string]bool) string {
+	candidates := make([]string, 0, 2)
+	for _, occ := range occurrences {
+		if occ.name != "" {
+			candidates = append(candidates, occ.name)
+		}
+	}
+	for _, occ := range occurrences {
+		if title := mappingValue(occ.node, "title"); title != nil && title.Kind == yaml.ScalarNode {
+			candidates = append(candidates, unsafeNameChars.ReplaceAllString(title.Value, ""))
+		}
+	}
+	for _, name := range candidates {
+		if name != "" && !taken[name] {
+			return name
+		}
+	}
+
+	prefix := idx.sections[section].prefix
+	for n := 1; ; n++ {
+		name := prefix + strconv.Itoa(n)
+		if !taken[name] {
+			return name
+		}
+	}
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func refNode(ref string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: "$ref"},
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: ref},
+	}}
+}
+
+func jsonPointerEscape(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// processRefs runs spec-aware deduplication on a single document.
+func (df *duplicateFinder) processRefs(root *yaml.Node, mode RefMode) error {
+	idx, err := df.newRefIndex(mode)
+	if err != nil {
+		return err
+	}
+	idx.add(0, root)
+	idx.hoist(root, 0, "", 1)
+	return nil
+}
+
+// ShareRefs hoists fragments duplicated across several spec documents into a
+// new common document and rewrites each occurrence as an external $ref to
+// commonPath (for example "common.yaml#/components/schemas/Pet"). Fragments
+// duplicated within a single spec are left for the per-document RefMode pass,
+// and fragments with local $refs stay in their specs: each spec may define
+// its own target under the same name.
+func ShareRefs(specs []*yaml.Node, commonPath string, opts Options) (*yaml.Node, error) {
+	if opts.RefMode == RefModeNone {
+		return nil, fmt.Errorf("sharing refs requires a RefMode")
+	}
+	df := newDuplicateFinder(opts)
+	idx, err := df.newRefIndex(opts.RefMode)
+	if err != nil {
+		return nil, err
+	}
+	for i, spec := range specs {
+		idx.add(i, spec)
+	}
+
+	common := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	idx.hoist(common, -1, commonPath, 2)
+
+	// Definitions in the common document refer to each other locally.
+	var localize func(node *yaml.Node)
+	localize = func(node *yaml.Node) {
+		if ref := mappingValue(node, "$ref"); ref != nil && strings.HasPrefix(ref.Value, commonPath+"#") {
+			ref.Value = strings.TrimPrefix(ref.Value, commonPath)
+		}
+		for _, child := range node.Content {
+			localize(child)
+		}
+	}
+	localize(common)
+
+	return common, nil
+}
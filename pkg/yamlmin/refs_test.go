@@ -0,0 +1,281 @@
+package yamlmin_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRefModeOpenAPI(t *testing.T) {
+	input := `openapi: 3.0.0
+paths:
+  /pets:
+    get:
+      responses:
+        "200":
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  name: {type: string}
+                  tag: {type: string}
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                name: {type: string}
+                tag: {type: string}
+  /owners:
+    get:
+      responses:
+        "200":
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  id: {type: integer, format: int64}
+components:
+  schemas:
+    Owner:
+      type: object
+      properties:
+        id: {type: integer, format: int64}
+`
+	opts := yamlmin.DefaultOptions()
+	opts.RefMode = yamlmin.RefModeOpenAPI
+
+	out, _, err := yamlmin.NewDecoder(strings.NewReader(input), opts).Decode()
+	require.NoError(t, err)
+
+	var doc map[string]interface{}
+	require.NoError(t, yaml.Unmarshal(out, &doc))
+
+	ref := func(path ...string) interface{} {
+		var v interface{} = doc
+		for _, p := range path {
+			v = v.(map[string]interface{})[p]
+		}
+		return v
+	}
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/Schema1"},
+		ref("paths", "/pets", "post", "requestBody", "content", "application/json", "schema"))
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/Schema1"},
+		ref("paths", "/pets", "get", "responses", "200", "content", "application/json", "schema"))
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/Owner"},
+		ref("paths", "/owners", "get", "responses", "200", "content", "application/json", "schema"))
+	assert.Equal(t, "object", ref("components", "schemas", "Schema1", "type"))
+	assert.NotContains(t, string(out), "&")
+}
+
+func TestShareRefs(t *testing.T) {
+	spec := func(path string) *yaml.Node {
+		var node yaml.Node
+		require.NoError(t, yaml.Unmarshal([]byte(`paths:
+  `+path+`:
+    get:
+      responses:
+        "200":
+          content:
+            application/json:
+              schema:
+                title: Error
+                type: object
+                properties:
+                  code: {type: integer}
+                  message: {type: string}
+`), &node))
+		return &node
+	}
+	a, b := spec("/a"), spec("/b")
+
+	opts := yamlmin.DefaultOptions()
+	opts.RefMode = yamlmin.RefModeOpenAPI
+
+	common, err := yamlmin.ShareRefs([]*yaml.Node{a, b}, "common.yaml", opts)
+	require.NoError(t, err)
+
+	commonOut, err := yaml.Marshal(common)
+	require.NoError(t, err)
+	assert.Contains(t, string(commonOut), "components:\n    schemas:\n        Error:\n")
+
+	aOut, err := yaml.Marshal(a)
+	require.NoError(t, err)
+	assert.Contains(t, string(aOut), "$ref: common.yaml#/components/schemas/Error")
+	bOut, err := yaml.Marshal(b)
+	require.NoError(t, err)
+	assert.Contains(t, string(bOut), "$ref: common.yaml#/components/schemas/Error")
+}
+
+func TestShareRefsLocalRefs(t *testing.T) {
+	spec := func(errorProps string) *yaml.Node {
+		var node yaml.Node
+		require.NoError(t, yaml.Unmarshal([]byte(`paths:
+  /pets:
+    get:
+      responses:
+        default:
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  error: {$ref: '#/components/schemas/Error'}
+                  requestId: {type: string, format: uuid}
+components:
+  schemas:
+    Error:
+      type: object
+      properties: `+errorProps+`
+`), &node))
+		return &node
+	}
+	a := spec("{code: {type: integer}}")
+	b := spec("{message: {type: string}, detail: {type: string}}")
+
+	opts := yamlmin.DefaultOptions()
+	opts.RefMode = yamlmin.RefModeOpenAPI
+
+	common, err := yamlmin.ShareRefs([]*yaml.Node{a, b}, "common.yaml", opts)
+	require.NoError(t, err)
+
+	commonOut, err := yaml.Marshal(common)
+	require.NoError(t, err)
+	assert.NotContains(t, string(commonOut), "#/components/schemas/Error")
+	for _, spec := range []*yaml.Node{a, b} {
+		out, err := yaml.Marshal(spec)
+		require.NoError(t, err)
+		assert.Contains(t, string(out), "error: {$ref: '#/components/schemas/Error'}")
+	}
+}
+
+func TestRefModeSwagger(t *testing.T) {
+	input := `swagger: "2.0"
+paths:
+  /pets/{id}:
+    get:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          type: string
+      responses:
+        "404":
+          description: The requested resource was not found
+          schema:
+            $ref: '#/definitions/Error'
+    delete:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          type: string
+      responses:
+        "404":
+          description: The requested resource was not found
+          schema:
+            $ref: '#/definitions/Error'
+definitions:
+  Error:
+    type: object
+    properties:
+      message: {type: string}
+`
+	opts := yamlmin.DefaultOptions()
+	opts.RefMode = yamlmin.RefModeSwagger
+
+	out, _, err := yamlmin.NewDecoder(strings.NewReader(input), opts).Decode()
+	require.NoError(t, err)
+
+	expected := `swagger: "2.0"
+paths:
+  /pets/{id}:
+    get:
+      parameters:
+        - $ref: '#/parameters/Parameter1'
+      responses:
+        "404":
+          $ref: '#/responses/Response1'
+    delete:
+      parameters:
+        - $ref: '#/parameters/Parameter1'
+      responses:
+        "404":
+          $ref: '#/responses/Response1'
+definitions:
+  Error:
+    type: object
+    properties:
+      message: {type: string}
+responses:
+  Response1:
+    description: The requested resource was not found
+    schema:
+      $ref: '#/definitions/Error'
+parameters:
+  Parameter1:
+    name: id
+    in: path
+    required: true
+    type: string
+`
+	assert.Equal(t, expected, string(out))
+}
+
+func TestPresetAsyncAPI(t *testing.T) {
+	input := `asyncapi: 2.6.0
+channels:
+  user/signedup:
+    subscribe:
+      message:
+        name: UserSignedUp
+        payload:
+          type: object
+          properties:
+            email: {type: string, format: email}
+  user/deleted:
+    subscribe:
+      message:
+        name: UserDeleted
+        payload:
+          type: object
+          properties:
+            email: {type: string, format: email}
+`
+	opts, err := yamlmin.Preset("asyncapi")
+	require.NoError(t, err)
+
+	out, _, err := yamlmin.NewDecoder(strings.NewReader(input), opts).Decode()
+	require.NoError(t, err)
+
+	expected := `asyncapi: 2.6.0
+channels:
+  user/signedup:
+    subscribe:
+      message:
+        name: UserSignedUp
+        payload:
+          $ref: '#/components/schemas/Schema1'
+  user/deleted:
+    subscribe:
+      message:
+        name: UserDeleted
+        payload:
+          $ref: '#/components/schemas/Schema1'
+components:
+  schemas:
+    Schema1:
+      type: object
+      properties:
+        email: {type: string, format: email}
+`
+	assert.Equal(t, expected, string(out))
+}
@@ -0,0 +1,191 @@
+// Package registry records the structures yamlmin fingerprints across files
+// and runs, so reports can reach beyond a single invocation: "this block
+// appears in 34 files across the repo". A Registry analyzes files and keeps
+// what it finds in a Store, which is pluggable; FileStore keeps it in a
+// local JSON file.
+package registry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"gopkg.in/yaml.v3"
+)
+
+// Block is one candidate structure found in a file.
+type Block struct {
+	// Fingerprint is the structure's yamlmin.Fingerprint, provided the
+	// options it was recorded with leave Comments and YAMLVersion unset.
+	Fingerprint uint64 `json:"fingerprint,string"`
+
+	// Kind is "mapping", "sequence", or "scalar".
+	Kind string `json:"kind"`
+
+	// Size is the estimated size of the structure, in characters.
+	Size int `json:"size"`
+
+	// Document is the 0-based index of the document in the file's stream,
+	// and Path locates the structure in it in yamlmin.Query syntax.
+	Document int    `json:"document"`
+	Path     string `json:"path"`
+}
+
+// Store persists what a Registry records. A Registry serializes its calls,
+// so implementations need not be safe for concurrent use.
+type Store interface {
+	// Put replaces the blocks recorded for file.
+	Put(file string, blocks []Block) error
+
+	// Load returns the blocks of every recorded file.
+	Load() (map[string][]Block, error)
+}
+
+// Registry records the blocks of files in a Store and reports the ones that
+// repeat across them.
+type Registry struct {
+	mu    sync.Mutex
+	store Store
+}
+
+// New returns a Registry backed by store.
+func New(store Store) *Registry {
+	return &Registry{store: store}
+}
+
+// Record analyzes every document of data and replaces what the registry
+// holds for file with the structures found, so recording a file again after
+// it changes does not count it twice. opts decides what counts as a
+// candidate, as it does for deduplication, except that structures occurring
+// only once in the file are recorded too.
+func (r *Registry) Record(file string, data []byte, opts yamlmin.Options) error {
+	blocks, err := Blocks(data, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.store.Put(file, blocks)
+}
+
+// Blocks returns the candidate structures in every document of data, in
+// document order.
+func Blocks(data []byte, opts yamlmin.Options) ([]Block, error) {
+	var blocks []Block
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for doc := 0; ; doc++ {
+		var root yaml.Node
+		err := dec.Decode(&root)
+		if errors.Is(err, io.EOF) {
+			return blocks, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing YAML: %w", err)
+		}
+		paths := make(map[*yaml.Node]string)
+		collectPaths(&root, "", paths)
+		for _, b := range yamlmin.Index(&root, opts) {
+			for _, n := range b.Nodes {
+				blocks = append(blocks, Block{
+					Fingerprint: b.Hash,
+					Kind:        yamlmin.KindName(b.Kind),
+					Size:        b.Size,
+					Document:    doc,
+					Path:        paths[n],
+				})
+			}
+		}
+	}
+}
+
+// Sighting is where a block was seen.
+type Sighting struct {
+	File     string `json:"file"`
+	Document int    `json:"document"`
+	Path     string `json:"path"`
+}
+
+// Entry summarizes one fingerprint across the recorded files.
+type Entry struct {
+	Fingerprint uint64 `json:"fingerprint,string"`
+	Kind        string `json:"kind"`
+	Size        int    `json:"size"`
+
+	// Files are the distinct files the block appears in, sorted.
+	Files []string `json:"files"`
+
+	// Sightings are all its occurrences, by file and then document order.
+	Sightings []Sighting `json:"sightings"`
+}
+
+// Report returns the blocks recorded in at least minFiles files, most
+// widespread first, then largest first.
+func (r *Registry) Report(minFiles int) ([]Entry, error) {
+	r.mu.Lock()
+	recorded, err := r.store.Load()
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(recorded))
+	for file := range recorded {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	entries := make(map[uint64]*Entry)
+	var order []uint64
+	for _, file := range files {
+		for _, b := range recorded[file] {
+			e, ok := entries[b.Fingerprint]
+			if !ok {
+				e = &Entry{Fingerprint: b.Fingerprint, Kind: b.Kind, Size: b.Size}
+				entries[b.Fingerprint] = e
+				order = append(order, b.Fingerprint)
+			}
+			if n := len(e.Files); n == 0 || e.Files[n-1] != file {
+				e.Files = append(e.Files, file)
+			}
+			e.Sightings = append(e.Sightings, Sighting{File: file, Document: b.Document, Path: b.Path})
+		}
+	}
+
+	var report []Entry
+	for _, fp := range order {
+		if e := entries[fp]; len(e.Files) >= minFiles {
+			report = append(report, *e)
+		}
+	}
+	sort.SliceStable(report, func(i, j int) bool {
+		if len(report[i].Files) != len(report[j].Files) {
+			return len(report[i].Files) > len(report[j].Files)
+		}
+		return report[i].Size > report[j].Size
+	})
+	return report, nil
+}
+
+// collectPaths records the Query path of node and its descendants.
+func collectPaths(node *yaml.Node, path string, paths map[*yaml.Node]string) {
+	if node.Kind != yaml.DocumentNode {
+		paths[node] = path
+		if path == "" {
+			paths[node] = "."
+		}
+	}
+	for i, child := range node.Content {
+		childP := path
+		switch {
+		case node.Kind == yaml.MappingNode && i%2 == 0:
+			childP = yamlmin.ChildPath(path, node, i+1)
+		case node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode:
+			childP = yamlmin.ChildPath(path, node, i)
+		}
+		collectPaths(child, childP, paths)
+	}
+}
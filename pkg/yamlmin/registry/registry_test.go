@@ -0,0 +1,112 @@
+package registry_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/glennpratt/yamlmin/pkg/yamlmin/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const shared = `resources:
+  limits: {cpu: 500m, memory: 256Mi}
+  requests: {cpu: 100m, memory: 128Mi}
+`
+
+func TestRegistryReport(t *testing.T) {
+	store := registry.NewFileStore(filepath.Join(t.TempDir(), "registry.json"))
+
+	// Each run records into the same store.
+	first := registry.New(store)
+	require.NoError(t, first.Record("a.yaml", []byte("api:\n  "+indent(shared)), yamlmin.Options{}))
+	require.NoError(t, first.Record("b.yaml", []byte("web:\n  "+indent(shared)+"---\nworker:\n  "+indent(shared)), yamlmin.Options{}))
+
+	second := registry.New(store)
+	require.NoError(t, second.Record("c.yaml", []byte("other: {x: 1}\n"), yamlmin.Options{}))
+
+	report, err := second.Report(2)
+	require.NoError(t, err)
+	require.NotEmpty(t, report)
+
+	top := report[0]
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(shared), &node))
+	fp, err := yamlmin.Fingerprint(node.Content[0])
+	require.NoError(t, err)
+	assert.Equal(t, fp, top.Fingerprint)
+	assert.Equal(t, "mapping", top.Kind)
+	assert.Equal(t, []string{"a.yaml", "b.yaml"}, top.Files)
+	assert.Equal(t, []registry.Sighting{
+		{File: "a.yaml", Document: 0, Path: ".api"},
+		{File: "b.yaml", Document: 0, Path: ".web"},
+		{File: "b.yaml", Document: 1, Path: ".worker"},
+	}, top.Sightings)
+	for _, e := range report {
+		assert.NotContains(t, e.Files, "c.yaml")
+	}
+
+	// Recording a file again replaces its earlier blocks.
+	require.NoError(t, second.Record("b.yaml", []byte("web: {}\n"), yamlmin.Options{}))
+	report, err = second.Report(2)
+	require.NoError(t, err)
+	assert.Empty(t, report)
+}
+
+func TestFileStoreVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"version": 0, "files": {"a.yaml": [{"fingerprint": "1"}]}}`), 0o644))
+
+	files, err := registry.NewFileStore(path).Load()
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	require.NoError(t, os.WriteFile(path, []byte(`not json`), 0o644))
+	_, err = registry.NewFileStore(path).Load()
+	assert.ErrorContains(t, err, "parsing")
+}
+
+func TestFileStorePut(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "registry.json")
+	store := registry.NewFileStore(path)
+	require.NoError(t, store.Put("a.yaml", []registry.Block{{Fingerprint: 1}}))
+	require.NoError(t, store.Put("b.yaml", []registry.Block{{Fingerprint: 2}}))
+
+	files, err := store.Load()
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	// Only the store itself is left behind.
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "registry.json", entries[0].Name())
+}
+
+func TestMemoryStore(t *testing.T) {
+	r := registry.New(registry.NewMemoryStore())
+	require.NoError(t, r.Record("a.yaml", []byte(shared), yamlmin.Options{}))
+	require.NoError(t, r.Record("b.yaml", []byte(shared), yamlmin.Options{}))
+	report, err := r.Report(2)
+	require.NoError(t, err)
+	require.NotEmpty(t, report)
+	assert.Equal(t, ".", report[0].Sightings[0].Path)
+
+	assert.ErrorContains(t, r.Record("bad.yaml", []byte("a: [\n"), yamlmin.Options{}), "bad.yaml: parsing YAML")
+}
+
+// indent indents every line of s after the first by two spaces.
+func indent(s string) string {
+	out := ""
+	for i, c := range s {
+		out += string(c)
+		if c == '\n' && i < len(s)-1 {
+			out += "  "
+		}
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+)
+
+// MemoryStore is a Store that keeps blocks in memory, for a single run or
+// for tests.
+type MemoryStore struct {
+	files map[string][]Block
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{files: make(map[string][]Block)}
+}
+
+// Put implements Store.
+func (s *MemoryStore) Put(file string, blocks []Block) error {
+	s.files[file] = append([]Block(nil), blocks...)
+	return nil
+}
+
+// Load implements Store.
+func (s *MemoryStore) Load() (map[string][]Block, error) {
+	files := make(map[string][]Block, len(s.files))
+	for file, blocks := range s.files {
+		files[file] = blocks
+	}
+	return files, nil
+}
+
+// FileStore is a Store kept in a local JSON file, read and rewritten on each
+// call, so every Put costs time proportional to the whole store, not just the
+// file it records. A missing file is an empty store. Entries recorded under
+// another FingerprintVersion are discarded, since their fingerprints no
+// longer match.
+type FileStore struct {
+	path string
+}
+
+// NewFileStore returns a FileStore at path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+type fileData struct {
+	Version int                `json:"version"`
+	Files   map[string][]Block `json:"files"`
+}
+
+// Put implements Store. The store is written to a temporary file beside it
+// and renamed into place, so an interrupted Put leaves the old store intact.
+func (s *FileStore) Put(file string, blocks []Block) error {
+	files, err := s.Load()
+	if err != nil {
+		return err
+	}
+	files[file] = blocks
+	data, err := json.MarshalIndent(fileData{Version: yamlmin.FingerprintVersion, Files: files}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Load implements Store.
+func (s *FileStore) Load() (map[string][]Block, error) {
+	files := make(map[string][]Block)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return files, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var d fileData
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", s.path, err)
+	}
+	if d.Version != yamlmin.FingerprintVersion {
+		return files, nil
+	}
+	for file, blocks := range d.Files {
+		files[file] = blocks
+	}
+	return files, nil
+}
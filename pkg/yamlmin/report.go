@@ -0,0 +1,195 @@
+package yamlmin
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// Report describes the duplicates Analyze found.
+type Report struct {
+	// Candidates are the structures occurring more than once, in order of
+	// first occurrence.
+	Candidates []Candidate
+
+	// EstimatedSavings is the sum of EstimatedSavings over the selected
+	// candidates.
+	EstimatedSavings int
+
+	// Sections groups the candidates by the top-level key they occur under,
+	// in document order, so the owners of each part of a shared file can act
+	// on their own findings.
+	Sections []Section
+}
+
+// Section is the part of a Report under one top-level mapping key.
+type Section struct {
+	// Key is the top-level key; it is empty for occurrences outside a
+	// top-level mapping, such as in a document that is a sequence.
+	Key string
+
+	// Candidates are those with at least one occurrence under Key.
+	Candidates []Candidate
+
+	// EstimatedSavings is this section's share of the selected candidates'
+	// savings, in proportion to the occurrences under Key. Shares of all
+	// sections add up to Report.EstimatedSavings, give or take rounding.
+	EstimatedSavings int
+}
+
+// Candidate is a structure that occurs more than once and could share an
+// anchor.
+type Candidate struct {
+	DuplicateGroup
+
+	// Paths are the Query paths of the occurrences, in document order. A
+	// mapping key (with Options.DedupKeys) has the path of its value.
+	Paths []string
+
+	// Sections are the top-level keys of the occurrences, matching Paths.
+	Sections []string
+
+	// Selected reports whether Marshal would anchor the candidate with the
+	// same options; Decision gives the reason either way.
+	Selected bool
+	Decision string
+
+	// EstimatedSavings is the bytes anchoring the candidate would save, as
+	// Stats.AnchorSavings estimates them. Savings of candidates nested in
+	// each other overlap.
+	EstimatedSavings int
+}
+
+// Analyze reports the duplicates in in, and which of them Marshal would
+// anchor with opts, without deduplicating or encoding anything.
+func Analyze(in interface{}, opts Options) (Report, error) {
+	root, err := encodeValue(in)
+	if err != nil {
+		return Report{}, err
+	}
+
+	paths := make(map[*yaml.Node]string)
+	collectPaths(root, nil, paths)
+	sections, keys := collectSections(root)
+
+	var report Report
+	for _, b := range Index(root, opts) {
+		if len(b.Nodes) < 2 {
+			continue
+		}
+		c := Candidate{
+			DuplicateGroup:   b.DuplicateGroup,
+			Selected:         b.Decision == DecisionAnchored,
+			Decision:         b.Decision,
+			EstimatedSavings: estimatedSavings(b.Size, len(b.Nodes)-1, len("map1")),
+		}
+		for _, n := range b.Nodes {
+			c.Paths = append(c.Paths, paths[n])
+			c.Sections = append(c.Sections, sections[n])
+		}
+		if c.Selected {
+			report.EstimatedSavings += c.EstimatedSavings
+		}
+		report.Candidates = append(report.Candidates, c)
+	}
+	report.Sections = groupSections(report.Candidates, keys)
+	return report, nil
+}
+
+// collectSections maps every node under a top-level mapping key to that key,
+// and returns the keys in document order. Other nodes map to "".
+func collectSections(root *yaml.Node) (map[*yaml.Node]string, []string) {
+	sections := make(map[*yaml.Node]string)
+	top := root
+	if top.Kind == yaml.DocumentNode && len(top.Content) == 1 {
+		top = top.Content[0]
+	}
+	if top.Kind != yaml.MappingNode {
+		return sections, nil
+	}
+	var keys []string
+	var mark func(node *yaml.Node, key string)
+	mark = func(node *yaml.Node, key string) {
+		sections[node] = key
+		for _, child := range node.Content {
+			mark(child, key)
+		}
+	}
+	for i := 0; i+1 < len(top.Content); i += 2 {
+		key := keyString(top.Content[i])
+		keys = append(keys, key)
+		mark(top.Content[i], key)
+		mark(top.Content[i+1], key)
+	}
+	return sections, keys
+}
+
+// groupSections builds the Report.Sections for candidates, ordered as keys
+// and then "" if anything lies outside them.
+func groupSections(candidates []Candidate, keys []string) []Section {
+	index := make(map[string]int, len(keys))
+	var out []Section
+	add := func(key string) int {
+		i, ok := index[key]
+		if !ok {
+			i = len(out)
+			index[key] = i
+			out = append(out, Section{Key: key})
+		}
+		return i
+	}
+	for _, key := range keys {
+		add(key)
+	}
+
+	for _, c := range candidates {
+		counts := make(map[string]int)
+		for _, key := range c.Sections {
+			counts[key]++
+		}
+		for _, key := range c.Sections {
+			n, ok := counts[key]
+			if !ok {
+				continue
+			}
+			delete(counts, key)
+			s := &out[add(key)]
+			s.Candidates = append(s.Candidates, c)
+			if c.Selected {
+				s.EstimatedSavings += c.EstimatedSavings * n / len(c.Sections)
+			}
+		}
+	}
+
+	sections := out[:0]
+	for _, s := range out {
+		if len(s.Candidates) > 0 {
+			sections = append(sections, s)
+		}
+	}
+	return sections
+}
+
+// collectPaths records the Query path of every node under node, which is at
+// path.
+func collectPaths(node *yaml.Node, path []pathStep, paths map[*yaml.Node]string) {
+	if node == nil {
+		return
+	}
+	if len(path) == 0 {
+		paths[node] = "."
+	} else {
+		paths[node] = formatPath(path)
+	}
+	for i, child := range node.Content {
+		var step pathStep
+		switch node.Kind {
+		case yaml.MappingNode:
+			step = pathStep{key: keyString(node.Content[i-i%2])}
+		case yaml.SequenceNode:
+			step = pathStep{index: i, isIndex: true}
+		default:
+			collectPaths(child, path, paths)
+			continue
+		}
+		collectPaths(child, append(path[:len(path):len(path)], step), paths)
+	}
+}
@@ -0,0 +1,200 @@
+package yamlmin
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// minRunItems is the fewest items Options.SequenceRuns extracts.
+const minRunItems = 2
+
+// runOccurrence is a shared run in one sequence.
+type runOccurrence struct {
+	seq   *yaml.Node
+	items []*yaml.Node
+}
+
+// runGroup is a run of items that several sequences contain.
+type runGroup struct {
+	items int
+	size  int
+	occs  []runOccurrence // document order, at most one per sequence
+}
+
+// extractRuns moves runs of items shared by several sequences into a
+// sequence anchored in place of the first run and aliased in place of the
+// others. It reports whether it extracted any.
+func (df *duplicateFinder) extractRuns(root *yaml.Node) bool {
+	if df.noSequences {
+		return false
+	}
+	var seqs []*yaml.Node
+	df.collectSeqs(root, 0, &seqs)
+
+	itemHashes := make(map[*yaml.Node][]uint64, len(seqs))
+	for _, s := range seqs {
+		hashes := make([]uint64, len(s.Content))
+		for i, item := range s.Content {
+			if h, err := df.hashNode(item, 0); err == nil {
+				hashes[i] = h
+			}
+		}
+		itemHashes[s] = hashes
+	}
+
+	// Grow runs an item at a time, keeping those still shared by enough
+	// sequences, so only frequent runs are ever extended.
+	minOcc := df.minOccurrencesFor(yaml.SequenceNode)
+	type open struct {
+		seq   *yaml.Node
+		start int
+		sig   uint64
+	}
+	var level []open
+	for _, s := range seqs {
+		for i := range s.Content {
+			if itemHashes[s][i] != 0 {
+				level = append(level, open{s, i, itemHashes[s][i]})
+			}
+		}
+	}
+	var groups []*runGroup
+	for items := minRunItems; len(level) > 0 && !df.isDeadlineExceeded(); items++ {
+		var next []open
+		bySig := make(map[uint64][]open)
+		var sigs []uint64
+		for _, o := range level {
+			end := o.start + items - 1
+			if end >= len(o.seq.Content) || itemHashes[o.seq][end] == 0 {
+				continue
+			}
+			h := fnv.New64a()
+			_ = binary.Write(h, binary.LittleEndian, o.sig)
+			_ = binary.Write(h, binary.LittleEndian, itemHashes[o.seq][end])
+			o.sig = h.Sum64()
+			if _, ok := bySig[o.sig]; !ok {
+				sigs = append(sigs, o.sig)
+			}
+			bySig[o.sig] = append(bySig[o.sig], o)
+		}
+		for _, sig := range sigs {
+			g := &runGroup{items: items}
+			for _, o := range bySig[sig] {
+				if n := len(g.occs); n > 0 && g.occs[n-1].seq == o.seq {
+					continue
+				}
+				g.occs = append(g.occs, runOccurrence{o.seq, o.seq.Content[o.start : o.start+items]})
+			}
+			if len(g.occs) < minOcc {
+				continue
+			}
+			next = append(next, bySig[sig]...)
+			for _, item := range g.occs[0].items {
+				g.size += df.estimateSize(item, 0)
+			}
+			if g.size >= df.minSize {
+				groups = append(groups, g)
+			}
+		}
+		level = next
+	}
+
+	// Larger runs first; ties keep document order.
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].size > groups[j].size })
+
+	gone := make(map[*yaml.Node]bool)
+	extracted := false
+	for _, g := range groups {
+		if df.applyRun(g, gone) {
+			extracted = true
+		}
+	}
+	return extracted
+}
+
+// applyRun replaces the occurrences of a run that earlier runs left intact,
+// and reports whether there were enough of them to do so.
+func (df *duplicateFinder) applyRun(g *runGroup, gone map[*yaml.Node]bool) bool {
+	type span struct {
+		seq   *yaml.Node
+		start int
+	}
+	var spans []span
+	for _, o := range g.occs {
+		if gone[o.seq] {
+			continue
+		}
+		start := indexOf(o.seq.Content, o.items[0])
+		if start < 0 || start+g.items > len(o.seq.Content) || start == 0 && g.items == len(o.seq.Content) {
+			continue
+		}
+		intact := true
+		for i, item := range o.items {
+			// Dropping a later copy must not remove an anchor in use.
+			if o.seq.Content[start+i] != item || len(spans) > 0 && containsAnchor(item) {
+				intact = false
+				break
+			}
+		}
+		if intact {
+			spans = append(spans, span{o.seq, start})
+		}
+	}
+	if len(spans) < df.minOccurrencesFor(yaml.SequenceNode) {
+		return false
+	}
+
+	run := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	var aliases []*yaml.Node
+	for i, sp := range spans {
+		items := sp.seq.Content[sp.start : sp.start+g.items]
+		var replacement *yaml.Node
+		if i == 0 {
+			run.Content = append(run.Content, items...)
+			replacement = run
+		} else {
+			for _, item := range items {
+				markGone(item, gone)
+			}
+			replacement = &yaml.Node{Kind: yaml.AliasNode, Alias: run}
+			aliases = append(aliases, replacement)
+		}
+		content := append([]*yaml.Node{}, sp.seq.Content[:sp.start]...)
+		content = append(content, replacement)
+		sp.seq.Content = append(content, sp.seq.Content[sp.start+g.items:]...)
+	}
+	run.Anchor = df.structureName(run, "run", &df.runCounter)
+	for _, alias := range aliases {
+		alias.Value = run.Anchor
+	}
+	return true
+}
+
+// collectSeqs gathers sequences eligible for run extraction in document
+// order.
+func (df *duplicateFinder) collectSeqs(node *yaml.Node, depth int, seqs *[]*yaml.Node) {
+	if node == nil || depth > df.maxDepth || df.isDeadlineExceeded() {
+		return
+	}
+	if node.Kind == yaml.SequenceNode && len(node.Content) > minRunItems && len(node.Content) <= df.maxWidth {
+		*seqs = append(*seqs, node)
+	}
+	for i, child := range node.Content {
+		if node.Kind == yaml.MappingNode && df.excluded(node, i) {
+			continue
+		}
+		df.collectSeqs(child, depth+1, seqs)
+	}
+}
+
+func indexOf(nodes []*yaml.Node, node *yaml.Node) int {
+	for i, n := range nodes {
+		if n == node {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,182 @@
+package yamlmin
+
+import (
+	"math"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SavingsScore scores a group by the bytes anchoring it saves: its encoded
+// size times the occurrences after the first, less the anchor and alias
+// overhead. It is CostScore(YAMLCost), and the default Score with
+// Options.MaximizeSavings.
+func SavingsScore(group DuplicateGroup) float64 {
+	return CostScore(YAMLCost)(group)
+}
+
+// scoredGroup is a duplicate group awaiting selection.
+type scoredGroup struct {
+	hash  uint64
+	score float64
+	group DuplicateGroup
+}
+
+// selection is a group selectBySavings chose to anchor.
+type selection struct {
+	scoredGroup
+	live []*yaml.Node // occurrences outside other selected groups' aliases
+}
+
+// selectBySavings chooses the anchored groups for Options.MaximizeSavings.
+// Like the default pass it visits groups best first, but a group that lost
+// occurrences to structures already aliased is scored again with the ones
+// left and waits its turn under that score. A group enclosing selected ones
+// is still anchored when its savings exceed what the selected ones lose to
+// it; those that no longer pay are dropped.
+func (df *duplicateFinder) selectBySavings(queue []scoredGroup) {
+	var selected []*selection
+	aliased := make(map[*yaml.Node]bool)  // non-first occurrences of selected groups
+	anchored := make(map[*yaml.Node]bool) // first occurrences of selected groups
+	sectionAnchors := make(map[string]int)
+
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+
+		nodes, _ := df.nodesByHash.get(c.hash)
+		live := df.liveNodes(nodes, aliased)
+		if len(live) < df.minOccurrencesFor(c.group.Kind) {
+			df.redecide(c.hash, DecisionAliased)
+			continue
+		}
+		if df.noNested {
+			if live = df.outsideAnchored(live, anchored); len(live) < df.minOccurrencesFor(c.group.Kind) {
+				df.redecide(c.hash, DecisionNested)
+				continue
+			}
+		}
+		if len(live) < c.group.Occurrences {
+			c.group.Occurrences = len(live)
+			c.score = df.score(c.group)
+			df.rescore(c.hash, c.score)
+			if c.score <= 0 {
+				df.redecide(c.hash, DecisionNoScore)
+				continue
+			}
+			if len(queue) > 0 && c.score < queue[0].score {
+				i := sort.Search(len(queue), func(i int) bool { return queue[i].score < c.score })
+				queue = append(queue[:i], append([]scoredGroup{c}, queue[i:]...)...)
+				continue
+			}
+		}
+
+		// Selecting c aliases its later occurrences, taking the occurrences
+		// of selected groups inside them along.
+		copies := make(map[*yaml.Node]bool, len(live)-1)
+		for _, n := range live[1:] {
+			copies[n] = true
+		}
+		first := map[*yaml.Node]bool{live[0]: true}
+		loss := 0.0
+		remaining := make(map[*selection][]*yaml.Node)
+		for _, s := range selected {
+			var kept []*yaml.Node
+			for _, n := range s.live {
+				if df.noNested && df.hasAncestorIn(n, first) {
+					loss = math.Inf(1) // c would enclose an anchor
+				}
+				if !copies[n] && !df.hasAncestorIn(n, copies) {
+					kept = append(kept, n)
+				}
+			}
+			if len(kept) == len(s.live) {
+				continue
+			}
+			remaining[s] = kept
+			loss += s.score - df.keptScore(s, kept)
+		}
+		if c.score <= loss {
+			df.redecide(c.hash, DecisionEnclosing)
+			continue
+		}
+
+		if df.maxAliasRatio > 0 {
+			// Count every selection as it would be with c, so aliases c
+			// takes over are not counted twice.
+			aliases, shrink := df.aliasCost(live)
+			for _, s := range selected {
+				kept, ok := remaining[s]
+				if !ok {
+					kept = s.live
+				} else if df.keptScore(s, kept) <= 0 {
+					continue
+				}
+				a, sh := df.aliasCost(kept)
+				aliases, shrink = aliases+a, shrink+sh
+			}
+			if df.overAliasRatio(aliases, shrink) {
+				df.redecide(c.hash, DecisionAliasRatio)
+				continue
+			}
+		}
+
+		section := df.sectionOf(live[0])
+		if limit, ok := df.sectionLimits[section]; ok {
+			if sectionAnchors[section] >= limit {
+				df.redecide(c.hash, DecisionSectionBudget)
+				continue
+			}
+			sectionAnchors[section]++
+		}
+
+		for s, kept := range remaining {
+			delete(anchored, s.live[0])
+			if score := df.keptScore(s, kept); score > 0 {
+				anchored[kept[0]] = true
+				s.live, s.score, s.group.Occurrences = kept, score, len(kept)
+				df.rescore(s.hash, score)
+				continue
+			}
+			// s no longer pays: its copies outside c stay as they are.
+			df.isDuplicate[s.hash] = false
+			df.redecide(s.hash, DecisionAliased)
+			if _, ok := df.sectionLimits[df.sectionOf(s.live[0])]; ok {
+				sectionAnchors[df.sectionOf(s.live[0])]--
+			}
+			for _, n := range s.live[1:] {
+				delete(aliased, n)
+			}
+			s.live = nil
+		}
+		df.isDuplicate[c.hash] = true
+		df.redecide(c.hash, DecisionAnchored)
+		selected = append(selected, &selection{scoredGroup: c, live: live})
+		anchored[live[0]] = true
+		for _, n := range live[1:] {
+			aliased[n] = true
+		}
+	}
+}
+
+// liveNodes returns the nodes not inside an aliased structure.
+func (df *duplicateFinder) liveNodes(nodes []*yaml.Node, aliased map[*yaml.Node]bool) []*yaml.Node {
+	var live []*yaml.Node
+	for _, n := range nodes {
+		if !df.hasAncestorIn(n, aliased) {
+			live = append(live, n)
+		}
+	}
+	return live
+}
+
+// keptScore is the score of selected group s left with occurrences kept, or
+// 0 if that is too few to anchor.
+func (df *duplicateFinder) keptScore(s *selection, kept []*yaml.Node) float64 {
+	if len(kept) < df.minOccurrencesFor(s.group.Kind) {
+		return 0
+	}
+	g := s.group
+	g.Occurrences = len(kept)
+	return max(df.score(g), 0)
+}
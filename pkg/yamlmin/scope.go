@@ -0,0 +1,20 @@
+package yamlmin
+
+import "gopkg.in/yaml.v3"
+
+// markScope records the nodes under a mapping key in df.scopeKeys, at any
+// depth, as the only ones shouldAnchor accepts.
+func (df *duplicateFinder) markScope(node *yaml.Node, in bool) {
+	if in {
+		df.inScope[node] = true
+	}
+	for i, child := range node.Content {
+		childIn := in
+		if node.Kind == yaml.MappingNode && i%2 == 1 && df.scopeKeys[keyString(node.Content[i-1])] {
+			childIn = true
+		}
+		if child.Kind != yaml.AliasNode {
+			df.markScope(child, childIn)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package yamlmin
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseSelector parses a document selector for Options.Select. A selector is
+// a comma-separated list of conditions that must all hold, each comparing the
+// scalar at a path with a value:
+//
+//	kind==ConfigMap
+//	kind==Deployment,metadata.namespace!=kube-system
+//
+// Paths use the Query syntax; the leading '.' may be omitted. A missing path
+// never equals a value.
+func ParseSelector(expr string) (func(doc *yaml.Node) bool, error) {
+	type condition struct {
+		path   string
+		value  string
+		negate bool
+	}
+	var conds []condition
+	for _, term := range strings.Split(expr, ",") {
+		term = strings.TrimSpace(term)
+		op := "=="
+		path, value, ok := strings.Cut(term, op)
+		if i := strings.Index(term, "!="); i >= 0 && (!ok || i < len(path)) {
+			op = "!="
+			path, value, ok = strings.Cut(term, op)
+		}
+		path, value = strings.TrimSpace(path), strings.TrimSpace(value)
+		if !ok || path == "" {
+			return nil, fmt.Errorf("invalid selector %q: want path==value or path!=value", term)
+		}
+		if path[0] != '.' && path[0] != '[' {
+			path = "." + path
+		}
+		if _, err := parsePath(path); err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", term, err)
+		}
+		conds = append(conds, condition{path: path, value: value, negate: op == "!="})
+	}
+
+	return func(doc *yaml.Node) bool {
+		for _, c := range conds {
+			node, err := Query(doc, c.path)
+			equal := err == nil && node != nil && node.Kind == yaml.ScalarNode && node.Value == c.value
+			if equal == c.negate {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
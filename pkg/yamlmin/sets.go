@@ -0,0 +1,56 @@
+package yamlmin
+
+import (
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+func setOf(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		set[k] = true
+	}
+	return set
+}
+
+// canonicalizeSets sorts sequences stored under any of keys. Scalars sort by
+// value ahead of collections, which sort by structural hash.
+func (df *duplicateFinder) canonicalizeSets(node *yaml.Node, keys map[string]bool) {
+	if node == nil {
+		return
+	}
+	if node.Kind == yaml.MappingNode {
+		for i := 1; i < len(node.Content); i += 2 {
+			value := node.Content[i]
+			if keys[node.Content[i-1].Value] && value.Kind == yaml.SequenceNode {
+				df.sortSequence(value)
+			}
+		}
+	}
+	for _, child := range node.Content {
+		df.canonicalizeSets(child, keys)
+	}
+}
+
+func (df *duplicateFinder) sortSequence(seq *yaml.Node) {
+	hashes := make(map[*yaml.Node]uint64, len(seq.Content))
+	for _, item := range seq.Content {
+		if item.Kind != yaml.ScalarNode {
+			// Unhashable items (limits hit) sort as zero, keeping relative order.
+			hashes[item], _ = df.hashNode(item, 0)
+		}
+	}
+	sort.SliceStable(seq.Content, func(i, j int) bool {
+		a, b := seq.Content[i], seq.Content[j]
+		aScalar, bScalar := a.Kind == yaml.ScalarNode, b.Kind == yaml.ScalarNode
+		switch {
+		case aScalar && bScalar:
+			return a.Value < b.Value
+		case aScalar != bScalar:
+			return aScalar
+		default:
+			return hashes[a] < hashes[b]
+		}
+	})
+}
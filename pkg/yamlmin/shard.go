@@ -0,0 +1,61 @@
+package yamlmin
+
+import "iter"
+
+// hashShardBits sets how many shards, 1<<hashShardBits, hash-keyed indexes
+// are split into by the top bits of the hash.
+const hashShardBits = 4
+
+// shardedMap is a map keyed by structural hash, split by hash prefix so that
+// no single map grows with the whole document and distinct shards can be
+// written by distinct goroutines without locking. The zero value is empty
+// and ready to use.
+type shardedMap[V any] struct {
+	shards [1 << hashShardBits]map[uint64]V
+}
+
+// shardOf returns the shard holding hash.
+func shardOf(hash uint64) int {
+	return int(hash >> (64 - hashShardBits))
+}
+
+func (m *shardedMap[V]) get(hash uint64) (V, bool) {
+	v, ok := m.shards[shardOf(hash)][hash]
+	return v, ok
+}
+
+func (m *shardedMap[V]) set(hash uint64, v V) {
+	s := &m.shards[shardOf(hash)]
+	if *s == nil {
+		*s = make(map[uint64]V)
+	}
+	(*s)[hash] = v
+}
+
+func (m *shardedMap[V]) len() int {
+	n := 0
+	for _, s := range m.shards {
+		n += len(s)
+	}
+	return n
+}
+
+// all iterates over every entry, shard by shard.
+func (m *shardedMap[V]) all() iter.Seq2[uint64, V] {
+	return func(yield func(uint64, V) bool) {
+		for _, s := range m.shards {
+			for k, v := range s {
+				if !yield(k, v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// clear removes every entry, keeping the shards allocated.
+func (m *shardedMap[V]) clear() {
+	for _, s := range m.shards {
+		clear(s)
+	}
+}
@@ -0,0 +1,97 @@
+package yamlmin
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnsupportedNodeError is returned under Options.Strict for a node the
+// minifier cannot safely handle.
+type UnsupportedNodeError struct {
+	// Path locates the node in Query syntax; "." is the root.
+	Path string
+	// Kind is the node's kind as found, which may not be a known one.
+	Kind yaml.Kind
+	// Reason says what is wrong with the node.
+	Reason string
+}
+
+func (e *UnsupportedNodeError) Error() string {
+	name := KindName(e.Kind)
+	if name == "node" {
+		name = "kind " + strconv.Itoa(int(e.Kind))
+	}
+	return fmt.Sprintf("%s: unsupported %s node: %s", e.Path, name, e.Reason)
+}
+
+// checkNodes returns an *UnsupportedNodeError for the first node under root,
+// in document order, that processing would otherwise skip or mishandle.
+func checkNodes(root *yaml.Node) error {
+	seen := make(map[*yaml.Node]bool)      // anchored nodes already complete
+	enclosing := make(map[*yaml.Node]bool) // nodes being walked
+	var walk func(node *yaml.Node, path []pathStep, top bool) error
+	walk = func(node *yaml.Node, path []pathStep, top bool) error {
+		fail := func(kind yaml.Kind, reason string) error {
+			p := "."
+			if len(path) > 0 {
+				p = formatPath(path)
+			}
+			return &UnsupportedNodeError{Path: p, Kind: kind, Reason: reason}
+		}
+		if node == nil {
+			return fail(0, "nil node")
+		}
+
+		switch node.Kind {
+		case yaml.DocumentNode:
+			if !top {
+				return fail(node.Kind, "document inside another node")
+			}
+		case yaml.ScalarNode:
+			if len(node.Content) > 0 {
+				return fail(node.Kind, "scalar has children")
+			}
+		case yaml.MappingNode:
+			if len(node.Content)%2 != 0 {
+				return fail(node.Kind, "key without a value")
+			}
+		case yaml.SequenceNode:
+		case yaml.AliasNode:
+			switch target := node.Alias; {
+			case target == nil:
+				return fail(node.Kind, "alias *"+node.Value+" has no target")
+			case target.Anchor != node.Value:
+				return fail(node.Kind, fmt.Sprintf("alias *%s refers to a node anchored as %q", node.Value, target.Anchor))
+			case enclosing[target]:
+				return fail(node.Kind, "alias *"+node.Value+" refers to a node that contains it")
+			case !seen[target]:
+				return fail(node.Kind, "alias *"+node.Value+" precedes its anchor")
+			}
+			return nil
+		default:
+			return fail(node.Kind, "unknown kind")
+		}
+
+		enclosing[node] = true
+		for i, child := range node.Content {
+			childPath := path
+			switch node.Kind {
+			case yaml.MappingNode:
+				childPath = append(path[:len(path):len(path)], pathStep{key: keyString(node.Content[i-i%2])})
+			case yaml.SequenceNode:
+				childPath = append(path[:len(path):len(path)], pathStep{index: i, isIndex: true})
+			}
+			if err := walk(child, childPath, false); err != nil {
+				return err
+			}
+		}
+		delete(enclosing, node)
+		if node.Anchor != "" {
+			seen[node] = true
+		}
+		return nil
+	}
+	return walk(root, nil, true)
+}
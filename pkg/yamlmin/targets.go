@@ -0,0 +1,215 @@
+package yamlmin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Target describes the YAML features a consumer of minified output supports.
+type Target struct {
+	// Name identifies the target, e.g. "github-actions".
+	Name string
+
+	// Anchors reports whether anchors and aliases are supported.
+	Anchors bool
+
+	// MergeKeys reports whether "<<" merge keys are supported.
+	MergeKeys bool
+
+	// Preset names the options preset used for this target.
+	// Default: "default"
+	Preset string
+
+	// JSONTags marshals Go values using their JSON tags, as K8sMarshal does.
+	JSONTags bool
+}
+
+var targets = map[string]Target{
+	"asyncapi": {Name: "asyncapi", Preset: "asyncapi"},
+	// Azure Pipelines rejects anchors, so its output is always plain YAML.
+	"azure-pipelines": {Name: "azure-pipelines"},
+	"circleci":        {Name: "circleci", Anchors: true, MergeKeys: true, Preset: "circleci"},
+	"cloudformation":  {Name: "cloudformation"},
+	"docker-compose":  {Name: "docker-compose", Anchors: true, MergeKeys: true},
+	"github-actions":  {Name: "github-actions", Anchors: true},
+	"gitlab-ci":       {Name: "gitlab-ci", Anchors: true, MergeKeys: true},
+	"json":            {Name: "json", JSONTags: true},
+	"kapp":            {Name: "kapp", Anchors: true, Preset: "carvel"},
+	"kubernetes":      {Name: "kubernetes", Anchors: true, MergeKeys: true, JSONTags: true},
+	"openapi":         {Name: "openapi", Preset: "openapi"},
+	"swagger":         {Name: "swagger", Preset: "swagger"},
+	"ytt":             {Name: "ytt", Anchors: true, Preset: "carvel"},
+}
+
+// Options returns the options used to minify for t: its preset, restricted to
+// the constructs t supports.
+func (t Target) Options() (Options, error) {
+	name := t.Preset
+	if name == "" {
+		name = "default"
+	}
+	opts, err := Preset(name)
+	if err != nil {
+		return Options{}, err
+	}
+	if !t.MergeKeys {
+		opts.MergeSubsets = false
+	}
+	if !t.Anchors && opts.RefMode == RefModeNone {
+		// Nothing may be shared, so no group can reach the threshold.
+		opts.MinOccurrences = math.MaxInt
+		opts.MinOccurrencesByKind = nil
+		opts.HoistScalars = false
+	}
+	return opts, nil
+}
+
+// MarshalForTarget marshals in for the named consumer, selecting its preset,
+// reference style, and compatibility constraints. Targets without anchor
+// support get $ref deduplication when their preset has a RefMode, and plain
+// YAML otherwise.
+func MarshalForTarget(in interface{}, target string) ([]byte, error) {
+	t, err := LookupTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := t.Options()
+	if err != nil {
+		return nil, err
+	}
+
+	var root *yaml.Node
+	if t.JSONTags {
+		root, err = jsonTagNode(in)
+	} else {
+		root, err = encodeValue(in)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !t.Anchors && opts.RefMode == RefModeNone {
+		return encodeNode(root, opts)
+	}
+	return marshalNode(root, opts)
+}
+
+// LookupTarget returns the named target. See Targets for the available names.
+func LookupTarget(name string) (Target, error) {
+	t, ok := targets[name]
+	if !ok {
+		return Target{}, fmt.Errorf("unknown target %q", name)
+	}
+	return t, nil
+}
+
+// Targets returns the names of all known targets in sorted order.
+func Targets() []string {
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Violation rules, naming the Policy requirement or Target feature that a
+// document fails.
+const (
+	RuleMinReduction     = "MinReduction"
+	RuleMaxDocumentBytes = "MaxDocumentBytes"
+	RuleForbidAnchors    = "ForbidAnchors"
+	RuleAnchors          = "Anchors"
+	RuleMergeKeys        = "MergeKeys"
+)
+
+// Violation is a construct in a document that a target does not support, or
+// a requirement of a Policy that the document fails.
+type Violation struct {
+	// Rule names the Policy requirement or Target feature broken, one of
+	// the Rule constants.
+	Rule string
+
+	// Document is the number of the document holding the problem (1-based),
+	// or zero for violations that concern the whole stream.
+	Document int
+
+	// Line and Column locate the offending node (1-based). They are zero for
+	// violations that concern a whole document or stream.
+	Line   int
+	Column int
+
+	// Message describes the problem and how to address it.
+	Message string
+}
+
+func (v Violation) String() string {
+	if v.Line == 0 {
+		return v.Message
+	}
+	return fmt.Sprintf("%d:%d: %s", v.Line, v.Column, v.Message)
+}
+
+// CheckTarget validates every document in data against the limitations of
+// target and returns the violations found, in document order.
+func CheckTarget(data []byte, target Target) ([]Violation, error) {
+	var violations []Violation
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for n := 1; ; n++ {
+		var root yaml.Node
+		err := dec.Decode(&root)
+		if errors.Is(err, io.EOF) {
+			return violations, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing YAML: %w", err)
+		}
+		violations = checkNode(&root, target, n, violations)
+	}
+}
+
+func checkNode(node *yaml.Node, target Target, doc int, violations []Violation) []Violation {
+	add := func(n *yaml.Node, rule, format string, args ...interface{}) {
+		violations = append(violations, Violation{Rule: rule, Document: doc, Line: n.Line, Column: n.Column, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if !target.Anchors {
+		if node.Anchor != "" {
+			add(node, RuleAnchors, "anchor &%s is not supported by %s; expand it or minify for this target", node.Anchor, target.Name)
+		}
+		if node.Kind == yaml.AliasNode {
+			add(node, RuleAnchors, "alias *%s is not supported by %s; expand it or minify for this target", node.Value, target.Name)
+		}
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			violations = checkNode(child, target, doc, violations)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if !target.MergeKeys && isMergeKey(key) {
+				add(key, RuleMergeKeys, "merge key << is not supported by %s; inline the merged mapping", target.Name)
+			}
+			violations = checkNode(key, target, doc, violations)
+			violations = checkNode(node.Content[i+1], target, doc, violations)
+		}
+	case yaml.SequenceNode:
+		for _, child := range node.Content {
+			violations = checkNode(child, target, doc, violations)
+		}
+	}
+	return violations
+}
+
+func isMergeKey(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && (node.Tag == "!!merge" || (node.Value == "<<" && node.Style == 0))
+}
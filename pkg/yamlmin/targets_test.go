@@ -0,0 +1,224 @@
+package yamlmin_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTarget(t *testing.T) {
+	input := []byte(`a: &x
+  b: 1
+c:
+  <<: *x
+`)
+
+	tests := []struct {
+		target   string
+		expected []string
+	}{
+		{"kubernetes", nil},
+		{"github-actions", []string{"MergeKeys/1 4:3: merge key << is not supported by github-actions; inline the merged mapping"}},
+		{"json", []string{
+			"Anchors/1 1:4: anchor &x is not supported by json; expand it or minify for this target",
+			"MergeKeys/1 4:3: merge key << is not supported by json; inline the merged mapping",
+			"Anchors/1 4:7: alias *x is not supported by json; expand it or minify for this target",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			target, err := yamlmin.LookupTarget(tt.target)
+			require.NoError(t, err)
+
+			violations, err := yamlmin.CheckTarget(input, target)
+			require.NoError(t, err)
+
+			var actual []string
+			for _, v := range violations {
+				actual = append(actual, fmt.Sprintf("%s/%d %s", v.Rule, v.Document, v))
+			}
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestMarshalForTarget(t *testing.T) {
+	type step struct {
+		Run string `json:"run" yaml:"command"`
+	}
+	data := map[string]interface{}{
+		"a": step{Run: "make test integration-test"},
+		"b": step{Run: "make test integration-test"},
+	}
+
+	tests := []struct {
+		target   string
+		expected string
+	}{
+		{"github-actions", "a: &map1\n  command: make test integration-test\nb: *map1\n"},
+		{"kubernetes", `{"a": &map1 {"run": "make test integration-test"}, "b": *map1}` + "\n"},
+		{"json", `{"a": {"run": "make test integration-test"}, "b": {"run": "make test integration-test"}}` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			out, err := yamlmin.MarshalForTarget(data, tt.target)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(out))
+		})
+	}
+}
+
+func TestCarvelTargets(t *testing.T) {
+	input := []byte(`#@data/values
+---
+#@overlay/match by="name"
+a:
+  image: registry.example.com/team/app:1.2.3
+b:
+  image: registry.example.com/team/app:1.2.3
+c:
+  image: registry.example.com/team/app:1.2.3
+  #@ replicas
+`)
+	for _, name := range []string{"ytt", "kapp"} {
+		target, err := yamlmin.LookupTarget(name)
+		require.NoError(t, err)
+		opts, err := target.Options()
+		require.NoError(t, err)
+
+		out, err := yamlmin.MinifyBytes(input, opts)
+		require.NoError(t, err)
+		assert.Contains(t, string(out), "#@overlay/match by=\"name\"")
+		assert.Contains(t, string(out), "#@ replicas")
+		assert.Equal(t, 1, strings.Count(string(out), "*map1"), "only the uncommented copies are shared")
+
+		violations, err := yamlmin.CheckTarget(out, target)
+		require.NoError(t, err)
+		assert.Empty(t, violations)
+	}
+}
+
+func TestCIPresets(t *testing.T) {
+	type job struct {
+		Image string `yaml:"image"`
+		Run   string `yaml:"run"`
+	}
+	data := map[string]interface{}{
+		"jobs": map[string]job{
+			"build": {Image: "cimg/go:1.22", Run: "make build and package everything"},
+			"test":  {Image: "cimg/node:20", Run: "make build and package everything"},
+		},
+	}
+
+	out, err := yamlmin.MarshalForTarget(data, "circleci")
+	require.NoError(t, err)
+	assert.Equal(t, `aliases:
+  v1: &str1 make build and package everything
+jobs:
+  build:
+    image: cimg/go:1.22
+    run: *str1
+  test:
+    image: cimg/node:20
+    run: *str1
+`, string(out))
+
+	out, err = yamlmin.MarshalForTarget(data, "azure-pipelines")
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "&")
+}
+
+func TestServerlessPresets(t *testing.T) {
+	opts, err := yamlmin.Preset("sam")
+	require.NoError(t, err)
+	out, err := yamlmin.MinifyBytes([]byte(`Transform: AWS::Serverless-2016-10-31
+Metadata:
+  Role: !GetAtt [ProcessorRole, Arn]
+Resources:
+  Fn:
+    Properties:
+      Role: !GetAtt [ProcessorRole, Arn]
+      Policies: [ProcessorRole, Arn]
+      Runtime: !Ref RuntimeParameterName
+      Handler: RuntimeParameterName
+`), opts)
+	require.NoError(t, err)
+	assert.Equal(t, `Transform: AWS::Serverless-2016-10-31
+Metadata:
+  Role: !GetAtt [ProcessorRole, Arn]
+Resources:
+  Fn:
+    Properties:
+      Role: !GetAtt [ProcessorRole, Arn]
+      Policies: [ProcessorRole, Arn]
+      Runtime: !Ref RuntimeParameterName
+      Handler: RuntimeParameterName
+`, string(out))
+
+	opts, err = yamlmin.Preset("serverless")
+	require.NoError(t, err)
+	out, err = yamlmin.MinifyBytes([]byte(`plugins:
+  - serverless-offline-with-a-long-name
+functions:
+  a:
+    handler: handler.a
+    layers: [serverless-offline-with-a-long-name]
+  b:
+    handler: handler.b
+    layers: [serverless-offline-with-a-long-name]
+`), opts)
+	require.NoError(t, err)
+	assert.Equal(t, `plugins:
+  - serverless-offline-with-a-long-name
+functions:
+  a:
+    handler: handler.a
+    layers: &list1 [serverless-offline-with-a-long-name]
+  b:
+    handler: handler.b
+    layers: *list1
+`, string(out))
+}
+
+func TestHelmValuesPreset(t *testing.T) {
+	opts, err := yamlmin.Preset("helm-values")
+	require.NoError(t, err)
+	out, err := yamlmin.MinifyBytes([]byte(`resources:
+  limits: {cpu: 500m, memory: 512Mi}
+services:
+  - name: api
+    resources:
+      limits: {cpu: 500m, memory: 512Mi}
+  - name: web
+    resources:
+      limits: {cpu: 500m, memory: 512Mi}
+`), opts)
+	require.NoError(t, err)
+	assert.Equal(t, `resources:
+  limits: {cpu: 500m, memory: 512Mi}
+services:
+  - name: api
+    resources: &api-resources
+      limits: {cpu: 500m, memory: 512Mi}
+  - name: web
+    resources: *api-resources
+`, string(out))
+
+	// yaml.v3 only accepts letters, digits, '_' and '-' in anchor names.
+	out, err = yamlmin.MinifyBytes([]byte(`services:
+  - name: web.example.com
+    resources:
+      limits: {cpu: 500m, memory: 512Mi}
+  - name: api.example.com
+    resources:
+      limits: {cpu: 500m, memory: 512Mi}
+`), opts)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "resources: &web-example-com-resources\n")
+}
@@ -0,0 +1,112 @@
+package yamlmin
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TruncatedValue replaces subtrees cut to fit Options.MaxOutputBytes.
+const TruncatedValue = "<truncated>"
+
+// TruncatedPrefix starts the trailer comment written for each subtree cut to
+// fit Options.MaxOutputBytes; its Query path and approximate size in bytes
+// follow.
+const TruncatedPrefix = "# yamlmin:truncated "
+
+// ErrTooLarge is returned when a document cannot be cut down to
+// Options.MaxOutputBytes.
+var ErrTooLarge = errors.New("document exceeds MaxOutputBytes")
+
+// fitOutput enforces Options.MaxOutputBytes on out, the encoding of root. If
+// out is too large, the largest subtrees of root are replaced with
+// TruncatedValue until it fits. It returns the new encoding with its trailer
+// and the paths of the subtrees cut.
+func fitOutput(root *yaml.Node, opts Options, out []byte) ([]byte, []string, error) {
+	limit := opts.MaxOutputBytes
+	if limit <= 0 || len(out) <= limit {
+		return out, nil, nil
+	}
+	if opts.ConcatSafe {
+		limit -= len("---\n")
+	}
+
+	var paths []string
+	var trailer strings.Builder
+	for len(out)+trailer.Len() > limit {
+		var best cutCandidate
+		best.find(root, nil)
+		if best.parent == nil {
+			return nil, paths, fmt.Errorf("%w: %d bytes remain after truncating %d subtrees", ErrTooLarge, len(out)+trailer.Len(), len(paths))
+		}
+
+		path := formatPath(best.path)
+		best.parent.Content[best.index] = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: TruncatedValue}
+		pruneAnchors(root)
+		paths = append(paths, path)
+		trailer.WriteString(TruncatedPrefix + path + " " + strconv.Itoa(best.size) + "\n")
+
+		var err error
+		if out, err = encodeNode(root, opts); err != nil {
+			return nil, paths, err
+		}
+	}
+	return append(out, trailer.String()...), paths, nil
+}
+
+// cutCandidate is the largest subtree found so far that may be truncated.
+type cutCandidate struct {
+	parent *yaml.Node
+	index  int
+	path   []pathStep
+	size   int
+}
+
+// find walks node, which is at path, recording in c the largest subtree
+// other than the root that holds no anchors, as aliases elsewhere may refer
+// to them. It returns the approximate encoded size of node and whether
+// it holds an anchor.
+func (c *cutCandidate) find(node *yaml.Node, path []pathStep) (size int, anchored bool) {
+	if node == nil {
+		return 0, false
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			s, a := c.find(child, path)
+			size, anchored = size+s, anchored || a
+		}
+		return size, anchored
+	case yaml.ScalarNode, yaml.AliasNode:
+		return len(node.Value) + 1, node.Anchor != ""
+	}
+
+	anchored = node.Anchor != ""
+	for i, child := range node.Content {
+		var step pathStep
+		if node.Kind == yaml.SequenceNode {
+			step = pathStep{index: i, isIndex: true}
+		} else if i%2 == 0 {
+			s, a := c.find(child, path)
+			size, anchored = size+s, anchored || a
+			continue
+		} else {
+			step = pathStep{key: keyString(node.Content[i-1])}
+		}
+
+		childPath := append(path[:len(path):len(path)], step)
+		s, a := c.find(child, childPath)
+		size, anchored = size+s, anchored || a
+		if !a && s > c.size && s > len(TruncatedValue) && !isTruncated(child) {
+			*c = cutCandidate{parent: node, index: i, path: childPath, size: s}
+		}
+	}
+	return size, anchored
+}
+
+func isTruncated(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Value == TruncatedValue
+}
@@ -0,0 +1,92 @@
+// Package yamlmin is the v2 API of yamlmin.
+//
+// Every entry point takes a context, the input, and *Options, and returns the
+// output together with a *Result describing it:
+//
+//	out, res, err := yamlmin.Marshal(ctx, myStruct, nil)
+//
+// A nil *Options means DefaultOptions. The context's deadline bounds
+// deduplication the same way Options.TimeLimit does, and a cancelled context
+// aborts with ctx.Err(). The v1 package remains supported; Options and Result
+// are shared with it, so values can be passed between the two.
+package yamlmin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	v1 "github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"gopkg.in/yaml.v3"
+)
+
+// Options configures deduplication. It is the v1 Options type.
+type Options = v1.Options
+
+// Result describes a minified output. It is the v1 Result type; for
+// multi-document input the counts are summed over all documents.
+type Result = v1.Result
+
+// DefaultOptions returns a new Options holding the default values.
+func DefaultOptions() *Options {
+	opts := v1.DefaultOptions()
+	return &opts
+}
+
+// Marshal encodes in using YAML struct tags and returns it deduplicated.
+func Marshal(ctx context.Context, in interface{}, opts *Options) ([]byte, *Result, error) {
+	data, err := yaml.Marshal(in)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encoding to YAML: %w", err)
+	}
+	return Minify(ctx, data, opts)
+}
+
+// MarshalJSON encodes in using JSON struct tags, as Kubernetes objects
+// expect, and returns it deduplicated.
+func MarshalJSON(ctx context.Context, in interface{}, opts *Options) ([]byte, *Result, error) {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encoding to JSON: %w", err)
+	}
+	return Minify(ctx, data, opts)
+}
+
+// Minify deduplicates every document in a YAML stream. Target-specific
+// options come from v1 Target.Options.
+func Minify(ctx context.Context, input []byte, opts *Options) ([]byte, *Result, error) {
+	o, err := effectiveOptions(ctx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var out bytes.Buffer
+	total, err := v1.NewDecoder(bytes.NewReader(input), o).WriteStream(&out, func([]byte, Result) error {
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return out.Bytes(), &total, nil
+}
+
+// effectiveOptions resolves opts, tightening TimeLimit to the context's
+// deadline.
+func effectiveOptions(ctx context.Context, opts *Options) (Options, error) {
+	if err := ctx.Err(); err != nil {
+		return Options{}, err
+	}
+	o := v1.DefaultOptions()
+	if opts != nil {
+		o = *opts
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline)
+		if o.TimeLimit <= 0 || remaining < o.TimeLimit {
+			o.TimeLimit = remaining
+		}
+	}
+	return o, nil
+}
@@ -0,0 +1,45 @@
+package yamlmin_test
+
+import (
+	"context"
+	"testing"
+
+	yamlmin "github.com/glennpratt/yamlmin/pkg/yamlmin/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshal(t *testing.T) {
+	ctx := context.Background()
+	in := map[string]string{"a": "a long repeated string", "b": "a long repeated string"}
+
+	out, res, err := yamlmin.Marshal(ctx, in, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "a: &str1 a long repeated string\nb: *str1\n", string(out))
+	assert.Equal(t, &yamlmin.Result{InputBytes: 52, OutputBytes: len(out), Anchors: 1, Aliases: 1, NodesScanned: 4, Candidates: 3, IndexEntries: 2, AuxBytes: 371}, res)
+
+	opts := yamlmin.DefaultOptions()
+	opts.MinOccurrences = 3
+	out, _, err = yamlmin.Marshal(ctx, in, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "a: a long repeated string\nb: a long repeated string\n", string(out))
+
+	out, _, err = yamlmin.MarshalJSON(ctx, in, nil)
+	require.NoError(t, err)
+	assert.Equal(t, `{"a": &str1 "a long repeated string", "b": *str1}`+"\n", string(out))
+}
+
+func TestMinify(t *testing.T) {
+	ctx := context.Background()
+	input := "a: a long repeated string\nb: a long repeated string\n---\nc: unique\n"
+
+	out, res, err := yamlmin.Minify(ctx, []byte(input), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "a: &str1 a long repeated string\nb: *str1\n---\nc: unique\n", string(out))
+	assert.Equal(t, 2, res.Aliases+res.Anchors)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, _, err = yamlmin.Minify(cancelled, []byte(input), nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,80 @@
+package yamlmin
+
+import "gopkg.in/yaml.v3"
+
+// verified reports whether node may be aliased to anchor: always when
+// verification is off, otherwise only when they are structurally equal.
+func (df *duplicateFinder) verified(node, anchor *yaml.Node) bool {
+	return !df.verify || df.nodesEqual(node, anchor, 0)
+}
+
+// checkBucket counts and logs a bucket holding structurally distinct nodes.
+func (df *duplicateFinder) checkBucket(hash uint64, nodes []*yaml.Node) {
+	for _, n := range nodes[1:] {
+		if df.nodesEqual(nodes[0], n, 0) {
+			continue
+		}
+		df.collisions++
+		if df.logger != nil {
+			df.logger.Debug("hash collision",
+				"hash", hash,
+				"kind", n.Kind,
+				"firstLine", nodes[0].Line,
+				"line", n.Line,
+			)
+		}
+		return
+	}
+}
+
+// nodesEqual compares two nodes with the semantics used by hashing: mapping
+// key order is ignored and aliases compare as their targets. Unlike the hash,
+// scalar tags must match too.
+func (df *duplicateFinder) nodesEqual(a, b *yaml.Node, depth int) bool {
+	for a != nil && a.Kind == yaml.AliasNode {
+		a = a.Alias
+	}
+	for b != nil && b.Kind == yaml.AliasNode {
+		b = b.Alias
+	}
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil || a.Kind != b.Kind || depth > df.maxDepth || localTag(a) != localTag(b) {
+		return false
+	}
+	if df.comments == CommentsStrict &&
+		(a.HeadComment != b.HeadComment || a.LineComment != b.LineComment || a.FootComment != b.FootComment) {
+		return false
+	}
+
+	switch a.Kind {
+	case yaml.ScalarNode:
+		return a.Value == b.Value && a.ShortTag() == b.ShortTag()
+	case yaml.MappingNode:
+		if len(a.Content) != len(b.Content) {
+			return false
+		}
+		values := make(map[string]*yaml.Node, len(b.Content)/2)
+		for i := 0; i+1 < len(b.Content); i += 2 {
+			values[keyString(b.Content[i])] = b.Content[i+1]
+		}
+		for i := 0; i+1 < len(a.Content); i += 2 {
+			other, ok := values[keyString(a.Content[i])]
+			if !ok || !df.nodesEqual(a.Content[i+1], other, depth+1) {
+				return false
+			}
+		}
+		return true
+	default:
+		if len(a.Content) != len(b.Content) {
+			return false
+		}
+		for i := range a.Content {
+			if !df.nodesEqual(a.Content[i], b.Content[i], depth+1) {
+				return false
+			}
+		}
+		return true
+	}
+}
@@ -0,0 +1,91 @@
+package yamlmin
+
+import (
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLVersion selects the YAML specification used to resolve plain scalars.
+type YAMLVersion string
+
+const (
+	// YAMLVersionNone keeps the resolution of the YAML parser without
+	// re-quoting anything.
+	YAMLVersionNone YAMLVersion = ""
+
+	// YAML11 resolves plain scalars as YAML 1.1 (libyaml, PyYAML) does, where
+	// yes/no/on/off are booleans and 0755 is octal.
+	YAML11 YAMLVersion = "1.1"
+
+	// YAML12 resolves plain scalars with the YAML 1.2 core schema.
+	YAML12 YAMLVersion = "1.2"
+)
+
+// YAML 1.1 implicit types, from https://yaml.org/type/.
+var (
+	yaml11Null  = regexp.MustCompile(`^(~|null|Null|NULL|)$`)
+	yaml11Bool  = regexp.MustCompile(`^(y|Y|yes|Yes|YES|n|N|no|No|NO|true|True|TRUE|false|False|FALSE|on|On|ON|off|Off|OFF)$`)
+	yaml11Int   = regexp.MustCompile(`^[-+]?(0b[0-1_]+|0[0-7_]+|0|[1-9][0-9_]*|0x[0-9a-fA-F_]+|[1-9][0-9_]*(:[0-5]?[0-9])+)$`)
+	yaml11Float = regexp.MustCompile(`^([-+]?([0-9][0-9_]*)?\.[0-9_]*([eE][-+][0-9]+)?|[-+]?[0-9][0-9_]*(:[0-5]?[0-9])+\.[0-9_]*|[-+]?\.(inf|Inf|INF)|\.(nan|NaN|NAN))$`)
+	yaml11Time  = regexp.MustCompile(`^[0-9]{4}-[0-9]{1,2}-[0-9]{1,2}([Tt]|[ \t]+)?([0-9]{1,2}:[0-9]{2}:[0-9]{2}(\.[0-9]*)?([ \t]*(Z|[-+][0-9]{1,2}(:[0-9]{2})?))?)?$`)
+)
+
+// resolve11 returns the YAML 1.1 tag of a plain scalar value.
+func resolve11(value string) string {
+	switch {
+	case yaml11Null.MatchString(value):
+		return "!!null"
+	case yaml11Bool.MatchString(value):
+		return "!!bool"
+	case yaml11Int.MatchString(value):
+		return "!!int"
+	case yaml11Float.MatchString(value):
+		return "!!float"
+	case yaml11Time.MatchString(value):
+		return "!!timestamp"
+	}
+	return "!!str"
+}
+
+// resolve12 returns the YAML 1.2 tag of a plain scalar value, as yaml.v3
+// resolves it.
+func resolve12(value string) string {
+	return (&yaml.Node{Kind: yaml.ScalarNode, Value: value}).ShortTag()
+}
+
+// isPlainUntagged reports whether a scalar's type comes from implicit
+// resolution, rather than from quoting, a block style, or an explicit tag.
+func isPlainUntagged(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Style&^yaml.FlowStyle == 0 && (node.Tag == "" || node.Tag == resolve12(node.Value))
+}
+
+// scalarTag returns the tag of a scalar under the configured YAMLVersion.
+func (df *duplicateFinder) scalarTag(node *yaml.Node) string {
+	if df.yamlVersion == YAML11 && isPlainUntagged(node) {
+		return resolve11(node.Value)
+	}
+	if df.yamlVersion == YAMLVersionNone {
+		return node.Tag
+	}
+	return node.ShortTag()
+}
+
+// quoteAmbiguous double-quotes plain scalars that are strings under the
+// configured version but would resolve to another type under the other one,
+// so the output reads the same to YAML 1.1 and 1.2 consumers.
+func (df *duplicateFinder) quoteAmbiguous(node *yaml.Node) {
+	if isPlainUntagged(node) {
+		v11, v12 := resolve11(node.Value), resolve12(node.Value)
+		own := v12
+		if df.yamlVersion == YAML11 {
+			own = v11
+		}
+		if own == "!!str" && v11 != v12 {
+			node.Style = yaml.DoubleQuotedStyle
+		}
+	}
+	for _, child := range node.Content {
+		df.quoteAmbiguous(child)
+	}
+}
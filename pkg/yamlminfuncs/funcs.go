@@ -0,0 +1,73 @@
+// Package yamlminfuncs provides text/template functions for minifying YAML
+// inside Go templating pipelines.
+//
+// Basic usage:
+//
+//	tmpl := template.New("manifest").Funcs(yamlminfuncs.FuncMap())
+//
+// Templates can then call:
+//
+//	{{ .Values | yamlmin }}
+//	{{ .Values | yamlminOpts .MinifyOptions }}
+//	{{ .Rendered | yamlexpand }}
+package yamlminfuncs
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/template"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+)
+
+// FuncMap returns the yamlmin, yamlminOpts, and yamlexpand template functions.
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"yamlmin":     minify,
+		"yamlminOpts": minifyOpts,
+		"yamlexpand":  expand,
+	}
+}
+
+// minify marshals v to minified YAML with default options.
+func minify(v interface{}) (string, error) {
+	out, err := yamlmin.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// minifyOpts marshals v to minified YAML with options overridden by opts. The
+// options come first so the value can be piped in.
+func minifyOpts(opts map[string]interface{}, v interface{}) (string, error) {
+	o, err := optionsFromMap(opts)
+	if err != nil {
+		return "", err
+	}
+	out, err := yamlmin.MarshalWithOptions(v, o)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// expand resolves every alias in s and returns plain YAML.
+func expand(s string) (string, error) {
+	out, err := yamlmin.Expand([]byte(s), yamlmin.ExpandOptions{})
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// optionsFromMap reads opts in the canonical JSON form of yamlmin.Options,
+// as server tenant overrides and yamlmin.MinifyString do, so every option is
+// accepted under its JSON name.
+func optionsFromMap(m map[string]interface{}) (yamlmin.Options, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return yamlmin.Options{}, fmt.Errorf("encoding options: %w", err)
+	}
+	return yamlmin.ParseOptions(data)
+}
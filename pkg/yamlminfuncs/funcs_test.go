@@ -0,0 +1,68 @@
+package yamlminfuncs_test
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlminfuncs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuncMap(t *testing.T) {
+	data := map[string]interface{}{
+		"a": "a long repeated string",
+		"b": "a long repeated string",
+	}
+	opts := map[string]interface{}{"minOccurrences": 3}
+
+	tests := []struct {
+		name     string
+		tmpl     string
+		data     interface{}
+		expected string
+	}{
+		{
+			name:     "yamlmin",
+			tmpl:     `{{ . | yamlmin }}`,
+			data:     data,
+			expected: "a: &str1 a long repeated string\nb: *str1\n",
+		},
+		{
+			name:     "yamlminOpts",
+			tmpl:     `{{ .Data | yamlminOpts .Opts }}`,
+			data:     map[string]interface{}{"Data": data, "Opts": opts},
+			expected: "a: a long repeated string\nb: a long repeated string\n",
+		},
+		{
+			name:     "yamlminOptsNaming",
+			tmpl:     `{{ .Data | yamlminOpts .Opts }}`,
+			data:     map[string]interface{}{"Data": data, "Opts": map[string]interface{}{"anchorNaming": "context"}},
+			expected: "a: &a a long repeated string\nb: *a\n",
+		},
+		{
+			name:     "yamlexpand",
+			tmpl:     `{{ . | yamlexpand }}`,
+			data:     "a: &str1 a long repeated string\nb: *str1\n",
+			expected: "a: a long repeated string\nb: a long repeated string\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New(tt.name).Funcs(yamlminfuncs.FuncMap()).Parse(tt.tmpl)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			require.NoError(t, tmpl.Execute(&buf, tt.data))
+			assert.Equal(t, tt.expected, buf.String())
+		})
+	}
+}
+
+func TestFuncMapUnknownOption(t *testing.T) {
+	tmpl := template.Must(template.New("t").Funcs(yamlminfuncs.FuncMap()).Parse(`{{ . | yamlminOpts .Opts }}`))
+	err := tmpl.Execute(&bytes.Buffer{}, map[string]interface{}{"Opts": map[string]interface{}{"minOcurrences": 3}})
+	assert.ErrorContains(t, err, "minOcurrences")
+}
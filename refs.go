@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"gopkg.in/yaml.v3"
+)
+
+// shareRefs hoists fragments shared across spec files into commonPath and
+// rewrites each spec in place to reference it. The specs are then minified
+// individually by the regular batch run.
+//
+// Every file is written to a temporary file first and renamed into place
+// only once all of them are complete, so a failure leaves the specs and the
+// common file as they were. The common file is renamed first, so a spec
+// never refers to fragments it does not hold yet.
+func shareRefs(paths []string, commonPath string, opts yamlmin.Options) error {
+	if len(paths) < 2 {
+		return errors.New("-common requires at least two files")
+	}
+
+	specs := make([]*yaml.Node, len(paths))
+	for i, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+		specs[i] = &node
+	}
+
+	// References are relative to each spec, which share a directory with the
+	// common file in the usual layout.
+	common, err := yamlmin.ShareRefs(specs, filepath.Base(commonPath), opts)
+	if err != nil {
+		return err
+	}
+
+	targets := append([]string{commonPath}, paths...)
+	nodes := append([]*yaml.Node{common}, specs...)
+	var temps []string
+	defer func() {
+		for _, tmp := range temps {
+			os.Remove(tmp)
+		}
+	}()
+	for i, path := range targets {
+		tmp, err := writeTemp(path, nodes[i], opts)
+		if err != nil {
+			return err
+		}
+		temps = append(temps, tmp)
+	}
+	for i, tmp := range temps {
+		if err := os.Rename(tmp, targets[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTemp encodes node to a new temporary file beside path and returns its
+// name. The file gets path's permissions, or 0644 when path does not exist.
+func writeTemp(path string, node *yaml.Node, opts yamlmin.Options) (string, error) {
+	mode := fs.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	enc := yaml.NewEncoder(f)
+	enc.SetIndent(opts.Indent)
+	err = enc.Encode(node)
+	if err == nil {
+		err = enc.Close()
+	}
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("writing %s: %w", path, err)
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShareRefsFiles(t *testing.T) {
+	spec := func(path string) string {
+		return `paths:
+  ` + path + `:
+    get:
+      responses:
+        "200":
+          content:
+            application/json:
+              schema:
+                title: Error
+                type: object
+                properties:
+                  code: {type: integer}
+                  message: {type: string}
+`
+	}
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yaml")
+	require.NoError(t, os.WriteFile(a, []byte(spec("/a")), 0o644))
+	require.NoError(t, os.WriteFile(b, []byte(spec("/b")), 0o644))
+	opts := yamlmin.DefaultOptions()
+	opts.RefMode = yamlmin.RefModeOpenAPI
+
+	// A common file that cannot be replaced, here a directory, leaves the
+	// specs untouched.
+	blocked := filepath.Join(dir, "blocked")
+	require.NoError(t, os.Mkdir(blocked, 0o755))
+	assert.Error(t, shareRefs([]string{a, b}, blocked, opts))
+	data, err := os.ReadFile(a)
+	require.NoError(t, err)
+	assert.Equal(t, spec("/a"), string(data))
+	require.NoError(t, os.Remove(blocked))
+
+	common := filepath.Join(dir, "common.yaml")
+	require.NoError(t, shareRefs([]string{a, b}, common, opts))
+	data, err = os.ReadFile(common)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Error:")
+	data, err = os.ReadFile(b)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "common.yaml#/components/schemas/Error")
+
+	// Only the specs and the common file are left behind.
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.Equal(t, []string{"a.yaml", "b.yaml", "common.yaml"}, names)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/glennpratt/yamlmin/pkg/yamlmin/registry"
+)
+
+// registryCmd records the blocks of files in a registry kept across runs and
+// prints the ones that appear in several recorded files. It exits 2 on usage
+// errors and 1 when a file can't be recorded.
+func registryCmd(args []string) int {
+	fs := flag.NewFlagSet("registry", flag.ExitOnError)
+	dbPath := fs.String("db", "yamlmin-registry.json", "Registry file to record the files in")
+	minFiles := fs.Int("min-files", 2, "Report blocks that appear in at least this many files")
+	minSize := fs.Int("min-size", 0, "Report blocks of at least this many characters")
+	preset := fs.String("preset", "default", "Options preset: "+strings.Join(yamlmin.Presets(), ", "))
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s registry [-db registry.json] [-min-files n] [file ...]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Records the files' blocks in the registry, replacing what was\n")
+		fmt.Fprintf(os.Stderr, "recorded for them before, and reports blocks that appear in\n")
+		fmt.Fprintf(os.Stderr, "several of all the files recorded so far. Files are not modified.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if *minFiles < 1 {
+		fmt.Fprintf(os.Stderr, "Error: -min-files must be at least 1\n")
+		return 2
+	}
+	opts, err := yamlmin.Preset(*preset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	reg := registry.New(registry.NewFileStore(*dbPath))
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+			return 1
+		}
+		if err := reg.Record(path, data, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error recording %v\n", err)
+			return 1
+		}
+	}
+
+	report, err := reg.Report(*minFiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading registry: %v\n", err)
+		return 1
+	}
+	for _, e := range report {
+		if e.Size < *minSize {
+			continue
+		}
+		fmt.Printf("%016x %s, %d bytes: appears in %d files\n", e.Fingerprint, e.Kind, e.Size, len(e.Files))
+		for _, s := range e.Sightings {
+			fmt.Printf("  %s: document %d %s\n", s.File, s.Document, s.Path)
+		}
+	}
+	return 0
+}
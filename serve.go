@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/glennpratt/yamlmin/pkg/server"
+)
+
+// serveCmd runs the HTTP minification service until it fails.
+func serveCmd(args []string) int {
+	def := server.DefaultConfig()
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "Address to listen on")
+	maxBody := fs.Int64("max-body", def.MaxBodyBytes, "Maximum request body size in bytes")
+	timeout := fs.Duration("timeout", def.RequestTimeout, "Maximum deduplication time per request")
+	maxConcurrent := fs.Int("max-concurrent", def.MaxConcurrent, "Maximum requests processed at once")
+	tokenFile := fs.String("token-file", "", "File of accepted bearer tokens, one per line")
+	tenantsFile := fs.String("tenants", "", "YAML file of tenants, each with a token and default preset, target, or options")
+	tlsCert := fs.String("tls-cert", "", "TLS certificate file; enables HTTPS")
+	tlsKey := fs.String("tls-key", "", "TLS private key file")
+	cacheEntries := fs.Int("cache-entries", 0, "Number of responses to keep in an LRU cache (0 disables)")
+	clientCA := fs.String("client-ca", "", "CA bundle for verifying client certificates; enables mTLS")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s serve [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Serves POST /minify over HTTP.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	cfg := server.Config{
+		MaxBodyBytes:      *maxBody,
+		RequestTimeout:    *timeout,
+		MaxConcurrent:     *maxConcurrent,
+		RequireClientCert: *clientCA != "",
+		CacheEntries:      *cacheEntries,
+	}
+	if *tokenFile != "" {
+		tokens, err := readTokens(*tokenFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		cfg.BearerTokens = tokens
+	}
+	if *tenantsFile != "" {
+		tenants, err := server.LoadTenants(*tenantsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		cfg.Tenants = tenants
+	}
+
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           server.New(cfg),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       *timeout + time.Minute,
+	}
+
+	var err error
+	if *tlsCert != "" {
+		httpServer.TLSConfig, err = server.TLSConfig(*tlsCert, *tlsKey, *clientCA)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "Listening on https://%s\n", *addr)
+		err = httpServer.ListenAndServeTLS("", "")
+	} else {
+		if *clientCA != "" {
+			fmt.Fprintf(os.Stderr, "Error: -client-ca requires -tls-cert and -tls-key\n")
+			return 2
+		}
+		fmt.Fprintf(os.Stderr, "Listening on http://%s\n", *addr)
+		err = httpServer.ListenAndServe()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// readTokens reads non-empty lines from path.
+func readTokens(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading tokens: %w", err)
+	}
+	var tokens []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			tokens = append(tokens, line)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("no tokens found in %s", path)
+	}
+	return tokens, nil
+}
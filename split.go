@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/glennpratt/yamlmin/pkg/kube"
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"gopkg.in/yaml.v3"
+)
+
+// splitCmd splits multi-document streams into one minified file per
+// Kubernetes kind or namespace. It exits 2 on usage errors.
+func splitCmd(args []string) int {
+	fs := flag.NewFlagSet("split", flag.ExitOnError)
+	by := fs.String("by", "kind", "Group documents by kind or namespace")
+	outDir := fs.String("o", ".", "Directory to write the files to")
+	preset := fs.String("preset", "default", "Options preset: "+strings.Join(yamlmin.Presets(), ", "))
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s split [-by kind|namespace] [-o dir] [file ...]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Writes the documents of each kind (or namespace) to their own minified\n")
+		fmt.Fprintf(os.Stderr, "file, e.g. deployment.yaml. With -by namespace, objects without one go\n")
+		fmt.Fprintf(os.Stderr, "to _cluster.yaml. Documents that are not Kubernetes objects go to _other.yaml.\n")
+		fmt.Fprintf(os.Stderr, "Reads from stdin when no files are given.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if *by != "kind" && *by != "namespace" {
+		fs.Usage()
+		return 2
+	}
+	opts, err := yamlmin.Preset(*preset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+
+	// Documents are grouped across all inputs, in input order.
+	groups := make(map[string]*bytes.Buffer)
+	for _, name := range inputs {
+		var data []byte
+		if name == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", name, err)
+			return 1
+		}
+		if err := splitDocs(data, *by, groups); err != nil {
+			fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", name, err)
+			return 1
+		}
+	}
+
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	reporter := textReporter{os.Stderr}
+	for _, key := range keys {
+		path := filepath.Join(*outDir, key+".yaml")
+		var out bytes.Buffer
+		if err := run(path, groups[key].Bytes(), &out, *preset, true, opts, reporter); err != nil {
+			fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", path, err)
+			return 1
+		}
+		if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", path, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// splitDocs appends each document in data to the stream for its group.
+func splitDocs(data []byte, by string, groups map[string]*bytes.Buffer) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var root yaml.Node
+		err := dec.Decode(&root)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("parsing YAML: %w", err)
+		}
+		doc, err := yaml.Marshal(&root)
+		if err != nil {
+			return err
+		}
+
+		key := "_other"
+		if id, ok := kube.Identify(doc); ok {
+			switch {
+			case by == "kind":
+				key = strings.ToLower(id.Kind)
+			case id.Namespace != "":
+				key = id.Namespace
+			default:
+				key = "_cluster"
+			}
+		}
+		key = fileSafe(key)
+
+		buf := groups[key]
+		if buf == nil {
+			buf = &bytes.Buffer{}
+			groups[key] = buf
+		} else {
+			buf.WriteString("---\n")
+		}
+		buf.Write(doc)
+	}
+}
+
+// fileSafe replaces characters that don't belong in a file name.
+func fileSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
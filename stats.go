@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/glennpratt/yamlmin/pkg/kube"
+)
+
+// fileStats describes the result of minifying one input.
+type fileStats struct {
+	Path      string   `json:"path"`
+	Preset    string   `json:"preset"`
+	Before    int      `json:"before"`
+	After     int      `json:"after"`
+	Reduction float64  `json:"reduction"`
+	Anchors   int      `json:"anchors"`
+	Aliases   int      `json:"aliases"`
+	Warnings  []string `json:"warnings"`
+
+	// MergePasses is the most detection passes any document needed with
+	// merge-key extraction; omitted without it.
+	MergePasses int `json:"mergePasses,omitempty"`
+
+	// Object identifies the Kubernetes object in a single-document input.
+	Object *kube.ObjectIdentity `json:"object,omitempty"`
+
+	// Documents breaks the sizes down per document for multi-document
+	// streams. Document sizes are of the re-encoded document, so they need
+	// not add up to Before.
+	Documents []docStats `json:"documents,omitempty"`
+}
+
+// docStats describes one document of a multi-document input.
+type docStats struct {
+	Index     int                  `json:"index"`
+	Object    *kube.ObjectIdentity `json:"object,omitempty"`
+	Before    int                  `json:"before"`
+	After     int                  `json:"after"`
+	Reduction float64              `json:"reduction"`
+	Aliases   int                  `json:"aliases"`
+}
+
+// label names the document for humans: the Kubernetes object it holds, or its
+// position.
+func (d docStats) label() string {
+	if d.Object != nil {
+		return d.Object.String()
+	}
+	return fmt.Sprintf("document #%d", d.Index)
+}
+
+type statsReporter interface {
+	report(rec fileStats) error
+}
+
+func newStatsReporter(format string, w io.Writer) (statsReporter, error) {
+	switch format {
+	case "text":
+		return textReporter{w}, nil
+	case "ndjson":
+		return ndjsonReporter{json.NewEncoder(w)}, nil
+	case "none":
+		return noneReporter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown stats format %q", format)
+	}
+}
+
+type textReporter struct {
+	w io.Writer
+}
+
+func (r textReporter) report(rec fileStats) error {
+	prefix := ""
+	if rec.Path != "-" {
+		prefix = rec.Path + ": "
+	}
+	passes := ""
+	if rec.MergePasses > 0 {
+		passes = fmt.Sprintf(", Merge passes: %d", rec.MergePasses)
+	}
+	if _, err := fmt.Fprintf(r.w, "%sInput: %d bytes, Output: %d bytes, Reduction: %.1f%%, Duplicates: %d%s\n",
+		prefix, rec.Before, rec.After, rec.Reduction, rec.Aliases, passes); err != nil {
+		return err
+	}
+	for _, d := range rec.Documents {
+		if _, err := fmt.Fprintf(r.w, "  %s: Input: %d bytes, Output: %d bytes, Reduction: %.1f%%, Duplicates: %d\n",
+			d.label(), d.Before, d.After, d.Reduction, d.Aliases); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ndjsonReporter writes one JSON object per input.
+type ndjsonReporter struct {
+	enc *json.Encoder
+}
+
+func (r ndjsonReporter) report(rec fileStats) error {
+	return r.enc.Encode(rec)
+}
+
+type noneReporter struct{}
+
+func (noneReporter) report(fileStats) error { return nil }
+
+// summaryReporter forwards records to another reporter while collecting them
+// for the batch summary.
+type summaryReporter struct {
+	statsReporter
+	sum *summary
+}
+
+func (r summaryReporter) report(rec fileStats) error {
+	r.sum.add(rec)
+	return r.statsReporter.report(rec)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/glennpratt/yamlmin/pkg/yamlmin/analysis"
+)
+
+// fileSuggestions is the suggest output for one input.
+type fileSuggestions struct {
+	File        string                `json:"file"`
+	Suggestions []analysis.Suggestion `json:"suggestions"`
+}
+
+// suggestCmd prints "extract anchor" edits for each input as JSON, for editor
+// plugins to offer as code actions instead of rewriting whole files.
+func suggestCmd(args []string) int {
+	fs := flag.NewFlagSet("suggest", flag.ExitOnError)
+	preset := fs.String("preset", "default", "Options preset: "+strings.Join(yamlmin.Presets(), ", "))
+	minOccurrences := fs.Int("min-occurrences", 2, "Minimum number of occurrences to create anchor")
+	minSize := fs.Int("min-size", 20, "Minimum structure size (chars) to consider for anchoring")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s suggest [options] [file ...]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Prints anchor suggestions as JSON edits (range and replacement text)\n")
+		fmt.Fprintf(os.Stderr, "without changing any file. Reads from stdin when no files are given.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	opts, err := yamlmin.Preset(*preset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "min-occurrences":
+			opts.MinOccurrences = *minOccurrences
+		case "min-size":
+			opts.MinSize = *minSize
+		}
+	})
+
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+
+	results := []fileSuggestions{}
+	for _, name := range inputs {
+		var data []byte
+		if name == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", name, err)
+			return 1
+		}
+		suggestions, err := analysis.Suggest(data, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", name, err)
+			return 1
+		}
+		if suggestions == nil {
+			suggestions = []analysis.Suggestion{}
+		}
+		results = append(results, fileSuggestions{File: name, Suggestions: suggestions})
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(results); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		return 1
+	}
+	return 0
+}
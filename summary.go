@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// summary aggregates fileStats across a batch run.
+type summary struct {
+	Files     int                      `json:"files"`
+	Before    int                      `json:"before"`
+	After     int                      `json:"after"`
+	Reduction float64                  `json:"reduction"`
+	Presets   map[string]*presetTotals `json:"presets"`
+	Top       []fileStats              `json:"top"`
+	NoGain    []string                 `json:"noGain"`
+
+	records []fileStats
+}
+
+type presetTotals struct {
+	Files  int `json:"files"`
+	Before int `json:"before"`
+	After  int `json:"after"`
+}
+
+func newSummary() *summary {
+	return &summary{Presets: make(map[string]*presetTotals), NoGain: []string{}}
+}
+
+func (s *summary) add(rec fileStats) {
+	s.Files++
+	s.Before += rec.Before
+	s.After += rec.After
+
+	p := s.Presets[rec.Preset]
+	if p == nil {
+		p = &presetTotals{}
+		s.Presets[rec.Preset] = p
+	}
+	p.Files++
+	p.Before += rec.Before
+	p.After += rec.After
+
+	if rec.Aliases == 0 {
+		s.NoGain = append(s.NoGain, rec.Path)
+	}
+	s.records = append(s.records, rec)
+}
+
+// finish computes derived fields once every file has been added.
+func (s *summary) finish() {
+	if s.Before > 0 {
+		s.Reduction = 100.0 * (1.0 - float64(s.After)/float64(s.Before))
+	}
+	top := append([]fileStats(nil), s.records...)
+	sort.SliceStable(top, func(i, j int) bool {
+		return top[i].Before-top[i].After > top[j].Before-top[j].After
+	})
+	if len(top) > 10 {
+		top = top[:10]
+	}
+	s.Top = top
+}
+
+func (s *summary) print(w io.Writer) {
+	fmt.Fprintf(w, "Total: %d files, Input: %d bytes, Output: %d bytes, Reduction: %.1f%%\n",
+		s.Files, s.Before, s.After, s.Reduction)
+
+	names := make([]string, 0, len(s.Presets))
+	for name := range s.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := s.Presets[name]
+		fmt.Fprintf(w, "  preset %s: %d files, Input: %d bytes, Output: %d bytes\n", name, p.Files, p.Before, p.After)
+	}
+
+	fmt.Fprintf(w, "Top savings:\n")
+	for _, rec := range s.Top {
+		fmt.Fprintf(w, "  %s: %d bytes saved (%.1f%%)\n", rec.Path, rec.Before-rec.After, rec.Reduction)
+	}
+	if len(s.NoGain) > 0 {
+		fmt.Fprintf(w, "No duplicates found:\n")
+		for _, path := range s.NoGain {
+			fmt.Fprintf(w, "  %s\n", path)
+		}
+	}
+}
+
+func (s *summary) writeJSON(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+)
+
+// trendRun is one run's aggregate stats in a history file.
+type trendRun struct {
+	Time       time.Time `json:"time"`
+	Files      int       `json:"files"`
+	Before     int       `json:"before"`
+	After      int       `json:"after"`
+	Reduction  float64   `json:"reduction"`
+	Duplicates int       `json:"duplicates"`
+}
+
+type trendHistory struct {
+	Runs []trendRun `json:"runs"`
+}
+
+// trendCmd measures files without changing them, appends the aggregate to a
+// history file, and compares it with the previous run. It exits 1 when the
+// reduction went down or the number of duplicates went up, and 2 on usage
+// errors.
+func trendCmd(args []string) int {
+	fs := flag.NewFlagSet("trend", flag.ExitOnError)
+	historyPath := fs.String("history", "yamlmin-history.json", "History file to append this run to")
+	preset := fs.String("preset", "default", "Options preset: "+strings.Join(yamlmin.Presets(), ", "))
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s trend [-history history.json] file ...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Records aggregate stats for the files and reports regressions\n")
+		fmt.Fprintf(os.Stderr, "against the previous run. Files are not modified.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	opts, err := yamlmin.Preset(*preset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	history, err := readHistory(*historyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		return 2
+	}
+
+	sum := newSummary()
+	reporter := summaryReporter{noneReporter{}, sum}
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+			return 1
+		}
+		if err := run(path, data, io.Discard, *preset, true, opts, reporter); err != nil {
+			fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", path, err)
+			return 1
+		}
+	}
+	sum.finish()
+
+	cur := trendRun{Time: time.Now().UTC(), Files: sum.Files, Before: sum.Before, After: sum.After, Reduction: sum.Reduction}
+	for _, rec := range sum.records {
+		cur.Duplicates += rec.Aliases
+	}
+
+	code := 0
+	fmt.Printf("Files: %d, Input: %d bytes, Output: %d bytes, Reduction: %.1f%%, Duplicates: %d\n",
+		cur.Files, cur.Before, cur.After, cur.Reduction, cur.Duplicates)
+	if n := len(history.Runs); n > 0 {
+		prev := history.Runs[n-1]
+		fmt.Printf("Previous run %s: Reduction: %.1f%%, Duplicates: %d\n",
+			prev.Time.Format(time.RFC3339), prev.Reduction, prev.Duplicates)
+		// Compare at the printed precision so rounding noise isn't reported.
+		if math.Round(cur.Reduction*10) < math.Round(prev.Reduction*10) {
+			fmt.Printf("Regression: reduction down %.1f points\n", prev.Reduction-cur.Reduction)
+			code = 1
+		}
+		if cur.Duplicates > prev.Duplicates {
+			fmt.Printf("Regression: duplicates up by %d\n", cur.Duplicates-prev.Duplicates)
+			code = 1
+		}
+	}
+
+	history.Runs = append(history.Runs, cur)
+	if err := writeHistory(*historyPath, history); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing history: %v\n", err)
+		return 1
+	}
+	return code
+}
+
+// readHistory reads a history file; a missing one is an empty history.
+func readHistory(path string) (trendHistory, error) {
+	var h trendHistory
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return h, err
+	}
+	if err := json.Unmarshal(data, &h); err != nil {
+		return h, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return h, nil
+}
+
+func writeHistory(path string, h trendHistory) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"gopkg.in/yaml.v3"
+)
+
+// previewLines caps the lines of a group's first occurrence shown by tui.
+const previewLines = 8
+
+// tuiCmd walks through the anchors yamlmin would create in a file, one
+// duplicate group at a time, and writes the output with only the accepted
+// ones. Answers are read from stdin and prompts go to stderr.
+func tuiCmd(args []string) int {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	preset := fs.String("preset", "default", "Options preset: "+strings.Join(yamlmin.Presets(), ", "))
+	write := fs.Bool("w", false, "Write the result to the file instead of stdout")
+	decisionsPath := fs.String("decisions", "", "Load earlier decisions from this file, and save all of them to it afterwards")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s tui [options] file\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Shows each duplicate group yamlmin would anchor and asks whether to\n")
+		fmt.Fprintf(os.Stderr, "anchor it, then writes the output with only the accepted anchors.\n")
+		fmt.Fprintf(os.Stderr, "Answer \"y name\" to also choose the anchor's name. With -decisions,\n")
+		fmt.Fprintf(os.Stderr, "later runs of \"%s -decisions file\" repeat the review.\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	path := fs.Arg(0)
+
+	opts, err := yamlmin.Preset(*preset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	if opts.RefMode != yamlmin.RefModeNone {
+		fmt.Fprintf(os.Stderr, "Error: tui reviews anchors; preset %s uses ref-mode %s\n", *preset, opts.RefMode)
+		return 2
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+		return 1
+	}
+	docs, err := parseDocuments(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+		return 1
+	}
+
+	rv := &review{in: bufio.NewReader(os.Stdin), out: os.Stderr, decisions: yamlmin.Decisions{}}
+	if *decisionsPath != "" {
+		if rv.decisions, err = readDecisions(*decisionsPath); errors.Is(err, os.ErrNotExist) {
+			rv.decisions, err = yamlmin.Decisions{}, nil
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading decisions: %v\n", err)
+			return 1
+		}
+	}
+	opts.Decisions = rv.decisions
+	if !rv.run(docs, opts) {
+		fmt.Fprintf(os.Stderr, "Quit; %s was not written.\n", path)
+		return 1
+	}
+
+	// Decisions are saved only once they have produced output, so a bad
+	// one cannot break later runs.
+	out, err := yamlmin.MinifyBytes(data, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", path, err)
+		return 1
+	}
+	if *write {
+		err = os.WriteFile(path, out, 0o644)
+	} else {
+		_, err = os.Stdout.Write(out)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", path, err)
+		return 1
+	}
+	if *decisionsPath != "" {
+		if err := writeDecisions(*decisionsPath, rv.decisions); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing decisions: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// parseDocuments returns the documents of a YAML stream.
+func parseDocuments(data []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var root yaml.Node
+		err := dec.Decode(&root)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing YAML: %w", err)
+		}
+		docs = append(docs, &root)
+	}
+}
+
+// writeDecisions saves d as a decisions file.
+func writeDecisions(path string, d yamlmin.Decisions) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// review holds the answers of a tui session. They are the Options.Decisions
+// of every analysis, so rejected groups drop out as they are answered.
+type review struct {
+	in        *bufio.Reader
+	out       io.Writer
+	decisions yamlmin.Decisions
+	all       bool // accept the rest without asking
+}
+
+// run asks about every group that would be anchored until none is left
+// undecided. Rejecting a group can free smaller ones nested in it, so the
+// documents are analyzed again after every answer. It reports false if the
+// user quit.
+func (rv *review) run(docs []*yaml.Node, opts yamlmin.Options) bool {
+	accepted, rejected := 0, 0
+	for !rv.all {
+		c, doc, ok := rv.next(docs, opts)
+		if !ok {
+			break
+		}
+		rv.show(c, doc, accepted+rejected+1)
+		switch answer, name := rv.ask(); answer {
+		case 'y':
+			rv.decisions[c.Hash] = yamlmin.GroupDecision{Action: yamlmin.ActionAccept, Name: name}
+			accepted++
+		case 'n':
+			rv.decisions[c.Hash] = yamlmin.GroupDecision{Action: yamlmin.ActionReject}
+			rejected++
+		case 'a':
+			rv.all = true
+		case 'q':
+			return false
+		}
+	}
+	fmt.Fprintf(rv.out, "%d accepted, %d rejected.\n", accepted, rejected)
+	return true
+}
+
+// next returns the first group, over all documents, that would be anchored
+// and has no answer yet.
+func (rv *review) next(docs []*yaml.Node, opts yamlmin.Options) (yamlmin.Candidate, *yaml.Node, bool) {
+	for _, doc := range docs {
+		report, err := yamlmin.Analyze(doc, opts)
+		if err != nil {
+			continue
+		}
+		for _, c := range report.Candidates {
+			if _, ok := rv.decisions[c.Hash]; c.Selected && !ok {
+				return c, doc, true
+			}
+		}
+	}
+	return yamlmin.Candidate{}, nil, false
+}
+
+func (rv *review) show(c yamlmin.Candidate, doc *yaml.Node, n int) {
+	fmt.Fprintf(rv.out, "\n#%d: %d occurrences, about %d bytes each, saving about %d bytes\n",
+		n, c.Occurrences, c.Size, c.EstimatedSavings)
+	for _, p := range c.Paths {
+		fmt.Fprintf(rv.out, "  %s\n", p)
+	}
+	node, err := yamlmin.Query(doc, c.Paths[0])
+	if err != nil {
+		return
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if enc.Encode(node) != nil || enc.Close() != nil {
+		return
+	}
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) > previewLines {
+		lines = append(lines[:previewLines], "...")
+	}
+	for _, line := range lines {
+		fmt.Fprintf(rv.out, "    | %s\n", line)
+	}
+}
+
+// ask reads an answer, repeating the prompt until it is one of y, n, a, or
+// q, and returns it with the anchor name given after a y. End of input
+// counts as q, so nothing is written by accident.
+func (rv *review) ask() (byte, string) {
+	for {
+		fmt.Fprint(rv.out, "Anchor it? [y]es [name], [n]o, [a]ccept the rest, [q]uit without writing: ")
+		line, err := rv.in.ReadString('\n')
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.ToLower(fields[0]) == "y" {
+			if yamlmin.ValidAnchorName(fields[1]) {
+				return 'y', fields[1]
+			}
+			fmt.Fprintln(rv.out, "Anchor names use only letters, digits, '_', and '-'.")
+			continue
+		}
+		if len(fields) == 1 && len(fields[0]) == 1 && strings.Contains("ynaq", strings.ToLower(fields[0])) {
+			return strings.ToLower(fields[0])[0], ""
+		}
+		if err != nil {
+			fmt.Fprintln(rv.out)
+			return 'q', ""
+		}
+	}
+}
@@ -1,68 +1,383 @@
+// Command yamlmin deduplicates YAML with anchors and aliases. It is a
+// command, not a library: Go programs import
+// github.com/glennpratt/yamlmin/pkg/yamlmin, whose functions this command
+// calls with the options its flags build.
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
-	"regexp"
+	"strconv"
+	"strings"
 
+	"github.com/glennpratt/yamlmin/pkg/kube"
 	"github.com/glennpratt/yamlmin/pkg/yamlmin"
 	"gopkg.in/yaml.v3"
 )
 
+// commands maps subcommand names to their entry points, which receive the
+// remaining arguments and return the process exit code.
+var commands = map[string]func(args []string) int{
+	"check-target": checkTargetCmd,
+	"daemon":       daemonCmd,
+	"debug-index":  debugIndexCmd,
+	"enforce":      enforceCmd,
+	"fmt":          fmtCmd,
+	"get":          getCmd,
+	"registry":     registryCmd,
+	"serve":        serveCmd,
+	"split":        splitCmd,
+	"suggest":      suggestCmd,
+	"trend":        trendCmd,
+	"tui":          tuiCmd,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			os.Exit(cmd(os.Args[2:]))
+		}
+	}
+
 	minOccurrences := flag.Int("min-occurrences", 2, "Minimum number of occurrences to create anchor")
 	minSize := flag.Int("min-size", 20, "Minimum structure size (chars) to consider for anchoring")
 	indent := flag.Int("indent", 2, "Indentation level for output")
+	foldWidth := flag.Int("fold-width", 0, "Write single-line strings longer than this folded (>-) and wrapped at spaces; 0 disables")
+	write := flag.Bool("w", false, "Write result to each input file instead of stdout")
+	statsFormat := flag.String("stats", "text", "Stats output to stderr: text, ndjson, or none")
+	summaryJSON := flag.String("summary-json", "", "Write an aggregate JSON summary of a multi-file run to this path")
+	preset := flag.String("preset", "default", "Options preset: "+strings.Join(yamlmin.Presets(), ", "))
+	refMode := flag.String("ref-mode", "", "Spec-aware $ref deduplication instead of anchors: openapi, swagger, or asyncapi")
+	common := flag.String("common", "", "With -ref-mode, hoist fragments shared across the files into this file (rewrites the files)")
+	selectExpr := flag.String("select", "", "Only minify documents matching a selector, e.g. kind==ConfigMap; others pass through")
+	comments := flag.String("comments", "", "Comment handling: strict (comments are content) or keep (move onto aliases); default drops them")
+	concatSafe := flag.Bool("concat-safe", false, "Start every document with --- and end it with a newline, so outputs can be concatenated")
+	uniqueAnchors := flag.Bool("unique-anchors", false, "Prefix anchor names with their document number so they are unique across the stream")
+	maxOutputBytes := flag.Int("max-output-bytes", 0, "Truncate the largest subtrees of documents still larger than this after deduplication")
+	passthrough := flag.Bool("passthrough", false, "Keep the original bytes of documents that are not minified")
+	preserve := flag.Bool("preserve", false, "Keep the input's key order, flow style, and comments (needed for yamlmin: comments and -comments) instead of writing block YAML with sorted keys")
+	parallel := flag.Bool("parallel", false, "Hash candidate structures on all CPUs (output is unchanged)")
+	yamlVersion := flag.String("yaml-version", "", "Resolve plain scalars as YAML 1.1 or 1.2 and quote ones the other version reads differently")
+	sectionAnchors := flag.String("section-anchors", "", "Per top-level key anchor limits, e.g. jobs=5,stages=2")
+	strict := flag.Bool("strict", false, "Fail instead of writing partly deduplicated output when a depth, width, or time limit is reached")
+	decisions := flag.String("decisions", "", "Honor the accept/reject/name decisions in this file, as saved by tui -decisions")
+	anchorNaming := flag.String("anchor-naming", "", "Anchor names: field (after a name or id field, e.g. &frontend-deployment), context (after the key and name, e.g. &nginx-container), or hash (after the content, e.g. &map-4f2a9c); default counters like map1")
+	noNestedAnchors := flag.Bool("no-nested-anchors", false, "Never anchor or alias anything inside an anchored structure")
+	mergeSubsets := flag.Bool("merge-subsets", false, "Move key/value pairs shared by several mappings into a base included with <<")
+	dedupKeys := flag.Bool("dedup-keys", false, "Also anchor and alias repeated mapping keys, such as long annotation keys and digests")
+	sequenceRuns := flag.Bool("sequence-runs", false, "Move runs of items shared by several lists into an aliased sub-list (changes nesting)")
+	maximizeSavings := flag.Bool("maximize-savings", false, "Choose anchors for the most bytes saved overall instead of largest structures first")
+	maxAliasRatio := flag.Float64("max-alias-ratio", 0, "Stop anchoring once aliases would be more than this fraction of a document's nodes; 0 allows any")
+	maxExpansion := flag.Float64("max-expansion", 100, "Refuse input whose aliases expand it more than this many times; 0 allows any")
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] [file ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s check-target --target name [-baseline baseline.json] file ...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s daemon -socket path [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s debug-index [options] [file ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s enforce [-policy policy.yaml] [-baseline baseline.json] file ...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s fmt [-w] [-indent n] [file ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s get path [file ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s registry [-db registry.json] [-min-files n] [file ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s serve [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s split [-by kind|namespace] [-o dir] [file ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s suggest [options] [file ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s trend [-history history.json] file ...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s tui [-w] file\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Finds and replaces duplicate YAML structures with anchors/aliases.\n")
-		fmt.Fprintf(os.Stderr, "Reads from stdin and writes to stdout.\n\n")
+		fmt.Fprintf(os.Stderr, "Reads from stdin and writes to stdout when no files are given.\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
 	}
 
 	flag.Parse()
 
-	data, err := io.ReadAll(os.Stdin)
+	reporter, err := newStatsReporter(*statsFormat, os.Stderr)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	opts, err := yamlmin.Preset(*preset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	// Explicitly set flags override the preset.
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "min-occurrences":
+			opts.MinOccurrences = *minOccurrences
+		case "min-size":
+			opts.MinSize = *minSize
+		case "indent":
+			opts.Indent = *indent
+		case "fold-width":
+			opts.FoldWidth = *foldWidth
+		case "ref-mode":
+			opts.RefMode = yamlmin.RefMode(*refMode)
+		case "select":
+			if opts.Select, err = yamlmin.ParseSelector(*selectExpr); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: -select: %v\n", err)
+				os.Exit(2)
+			}
+		case "comments":
+			opts.Comments = yamlmin.CommentMode(*comments)
+		case "max-output-bytes":
+			opts.MaxOutputBytes = *maxOutputBytes
+		case "passthrough":
+			opts.Passthrough = *passthrough
+		case "concat-safe":
+			opts.ConcatSafe = *concatSafe
+		case "unique-anchors":
+			opts.UniqueAnchors = *uniqueAnchors
+		case "parallel":
+			opts.Parallel = *parallel
+		case "yaml-version":
+			opts.YAMLVersion = yamlmin.YAMLVersion(*yamlVersion)
+		case "section-anchors":
+			if opts.SectionAnchorLimits, err = parseSectionLimits(*sectionAnchors); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: -section-anchors: %v\n", err)
+				os.Exit(2)
+			}
+		case "strict":
+			opts.Strict = *strict
+		case "decisions":
+			if opts.Decisions, err = readDecisions(*decisions); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: -decisions: %v\n", err)
+				os.Exit(2)
+			}
+		case "no-nested-anchors":
+			opts.NoNestedAnchors = *noNestedAnchors
+		case "merge-subsets":
+			opts.MergeSubsets = *mergeSubsets
+		case "sequence-runs":
+			opts.SequenceRuns = *sequenceRuns
+		case "max-alias-ratio":
+			opts.MaxAliasRatio = *maxAliasRatio
+		case "maximize-savings":
+			opts.MaximizeSavings = *maximizeSavings
+		case "dedup-keys":
+			opts.DedupKeys = *dedupKeys
+		case "anchor-naming":
+			opts.AnchorNaming = yamlmin.AnchorNaming(*anchorNaming)
+		}
+	})
+
+	if *common != "" {
+		if err := shareRefs(flag.Args(), *common, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
-	if len(data) == 0 {
+	if flag.NArg() == 0 {
+		if *write {
+			fmt.Fprintf(os.Stderr, "Error: -w requires file arguments\n")
+			os.Exit(2)
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+			os.Exit(1)
+		}
+		if len(data) == 0 {
+			return
+		}
+		if err := checkExpansion(data, *maxExpansion); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: stdin: %v\n", err)
+			os.Exit(1)
+		}
+		if err := run("-", data, os.Stdout, *preset, *preserve, opts, reporter); err != nil {
+			fmt.Fprintf(os.Stderr, "Error processing YAML: %v\n", err)
+			os.Exit(1)
+		}
 		return
 	}
 
-	opts := yamlmin.DefaultOptions()
-	opts.MinOccurrences = *minOccurrences
-	opts.MinSize = *minSize
-	opts.Indent = *indent
+	sum := newSummary()
+	reporter = summaryReporter{reporter, sum}
+
+	failed := false
+	for i, path := range flag.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+			failed = true
+			continue
+		}
+		if err := checkExpansion(data, *maxExpansion); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+			failed = true
+			continue
+		}
 
-	var val interface{}
-	if err := yaml.Unmarshal(data, &val); err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing YAML: %v\n", err)
+		var out bytes.Buffer
+		if err := run(path, data, &out, *preset, *preserve, opts, reporter); err != nil {
+			fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", path, err)
+			failed = true
+			continue
+		}
+
+		if *write {
+			err = os.WriteFile(path, out.Bytes(), 0o644)
+		} else {
+			if i > 0 && !yamlmin.StartsDocument(out.Bytes()) {
+				_, err = io.WriteString(os.Stdout, "---\n")
+			}
+			if err == nil {
+				_, err = os.Stdout.Write(out.Bytes())
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", path, err)
+			failed = true
+		}
+	}
+
+	sum.finish()
+	if flag.NArg() > 1 && *statsFormat == "text" {
+		sum.print(os.Stderr)
+	}
+	if *summaryJSON != "" {
+		if err := sum.writeJSON(*summaryJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing summary: %v\n", err)
+			failed = true
+		}
+	}
+	if failed {
 		os.Exit(1)
 	}
+}
 
-	out, err := yamlmin.MarshalWithOptions(val, opts)
+// run minifies every document in data, writes the stream to w, and reports
+// stats for the input. Unless preserve is set, the documents are normalized
+// first.
+func run(path string, data []byte, w io.Writer, preset string, preserve bool, opts yamlmin.Options, reporter statsReporter) error {
+	rec := fileStats{Path: path, Preset: preset, Before: len(data), Warnings: []string{}}
+
+	if !preserve {
+		var err error
+		if data, err = normalize(data); err != nil {
+			return err
+		}
+	}
+	var out bytes.Buffer
+	total, err := yamlmin.NewDecoder(bytes.NewReader(data), opts).WriteStream(&out, func(doc []byte, res yamlmin.Result) error {
+		rec.Documents = append(rec.Documents, newDocStats(len(rec.Documents), doc, res))
+		return nil
+	})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error processing YAML: %v\n", err)
-		os.Exit(1)
+		return err
+	}
+	rec.Anchors, rec.Aliases, rec.MergePasses = total.Anchors, total.Aliases, total.MergePasses
+	if len(rec.Documents) == 1 {
+		rec.Object = rec.Documents[0].Object
+	}
+	if len(rec.Documents) < 2 {
+		rec.Documents = nil
 	}
 
-	// Count aliases (deduplicated references) in output via regex
-	aliasRe := regexp.MustCompile(`\*(map|list|str)\d+`)
-	aliases := aliasRe.FindAllString(string(out), -1)
+	rec.After = out.Len()
+	if rec.Before > 0 {
+		rec.Reduction = 100.0 * (1.0 - float64(rec.After)/float64(rec.Before))
+	}
+	if rec.After > rec.Before {
+		rec.Warnings = append(rec.Warnings, "output is larger than input")
+	}
 
-	// Print stats to stderr
-	fmt.Fprintf(os.Stderr, "Input: %d bytes, Output: %d bytes, Reduction: %.1f%%, Duplicates: %d\n",
-		len(data), len(out), 100.0*(1.0-float64(len(out))/float64(len(data))), len(aliases))
+	if _, err := w.Write(out.Bytes()); err != nil {
+		return err
+	}
+	return reporter.report(rec)
+}
 
-	if _, err := os.Stdout.Write(out); err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing stdout: %v\n", err)
-		os.Exit(1)
+// normalize rewrites every document in data as plain values encoded again,
+// as yamlmin.MarshalWithOptions would write them: block style, mapping keys
+// sorted, aliases expanded, and comments dropped. JSON input becomes block
+// YAML this way.
+func normalize(data []byte) ([]byte, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing YAML: %w", err)
+		}
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// checkExpansion refuses data whose aliases would expand it more than limit
+// times, as "billion laughs" input does, before any work is spent on it. A
+// limit of 0 allows anything.
+func checkExpansion(data []byte, limit float64) error {
+	if limit <= 0 {
+		return nil
+	}
+	factor, err := yamlmin.ExpansionFactor(data)
+	if err != nil {
+		return err
+	}
+	if factor > limit {
+		return fmt.Errorf("aliases expand the input %.0f times, more than -max-expansion %g allows (use -max-expansion 0 to process it anyway)", factor, limit)
+	}
+	return nil
+}
+
+// newDocStats describes the nth document of a stream, identifying the
+// Kubernetes object it holds, if any, from its minified text.
+func newDocStats(n int, doc []byte, res yamlmin.Result) docStats {
+	d := docStats{Index: n, Before: res.InputBytes, After: res.OutputBytes, Aliases: res.Aliases}
+	if d.Before > 0 {
+		d.Reduction = 100.0 * (1.0 - float64(d.After)/float64(d.Before))
+	}
+	if id, ok := kube.Identify(doc); ok {
+		d.Object = &id
+	}
+	return d
+}
+
+// readDecisions reads a decisions file written by tui.
+func readDecisions(path string) (yamlmin.Decisions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var d yamlmin.Decisions
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return d, nil
+}
+
+// parseSectionLimits parses a comma-separated list of key=limit pairs.
+func parseSectionLimits(s string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		n, err := strconv.Atoi(value)
+		if !ok || key == "" || err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid section anchor limit %q, want key=n", pair)
+		}
+		limits[key] = n
 	}
+	return limits, nil
 }
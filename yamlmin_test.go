@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/glennpratt/yamlmin/pkg/yamlmin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRunPreserve(t *testing.T) {
+	input := `# deployments
+web: {image: nginx, pull: IfNotPresent}
+api: {image: nginx, pull: IfNotPresent}
+---
+{"b": [1, 2], "a": "on"}
+`
+	reporter, err := newStatsReporter("none", nil)
+	require.NoError(t, err)
+
+	// By default documents come out as MarshalWithOptions writes them.
+	var out bytes.Buffer
+	require.NoError(t, run("-", []byte(input), &out, "default", false, yamlmin.DefaultOptions(), reporter))
+	var want bytes.Buffer
+	dec := yaml.NewDecoder(bytes.NewReader([]byte(input)))
+	for n := 0; n < 2; n++ {
+		var v interface{}
+		require.NoError(t, dec.Decode(&v))
+		doc, err := yamlmin.MarshalWithOptions(v, yamlmin.DefaultOptions())
+		require.NoError(t, err)
+		if n > 0 {
+			want.WriteString("---\n")
+		}
+		want.Write(doc)
+	}
+	assert.Equal(t, want.String(), out.String())
+	assert.Equal(t, `api: &map1
+  image: nginx
+  pull: IfNotPresent
+web: *map1
+---
+a: "on"
+b:
+  - 1
+  - 2
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, run("-", []byte(input), &out, "default", true, yamlmin.DefaultOptions(), reporter))
+	assert.Equal(t, `# deployments
+web: &map1 {image: nginx, pull: IfNotPresent}
+api: *map1
+---
+{"b": [1, 2], "a": "on"}
+`, out.String())
+}